@@ -0,0 +1,240 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/apikey"
+)
+
+var ErrAPIKeyNotFound = apikey.ErrKeyNotFound
+
+// APIKeyItem is an API key stored in the partition of its owner in the users table
+type APIKeyItem struct {
+	PK              string   `dynamodbav:"PK"`     // USER#<userID>
+	SK              string   `dynamodbav:"SK"`     // APIKEY#<id>
+	GSI1PK          string   `dynamodbav:"GSI1PK"` // APIKEY#<sha256 of the secret>
+	GSI1SK          string   `dynamodbav:"GSI1SK"` // APIKEY#<id>
+	ID              string   `dynamodbav:"id"`
+	UserID          string   `dynamodbav:"user_id"`
+	Name            string   `dynamodbav:"name"`
+	Prefix          string   `dynamodbav:"prefix"`
+	Hash            string   `dynamodbav:"key_hash"`
+	Scopes          []string `dynamodbav:"scopes,stringset"`
+	AllowedChannels []string `dynamodbav:"allowed_channels,stringset,omitempty"`
+	CreatedAt       string   `dynamodbav:"created_at"`             // ISO8601 string
+	ExpiresAt       string   `dynamodbav:"expires_at,omitempty"`   // ISO8601 string, not a TTL
+	LastUsedAt      string   `dynamodbav:"last_used_at,omitempty"` // ISO8601 string
+	RevokedAt       string   `dynamodbav:"revoked_at,omitempty"`   // ISO8601 string
+}
+
+func (r *UserRepository) CreateAPIKey(ctx context.Context, k *apikey.Key) error {
+	av, err := attributevalue.MarshalMap(toAPIKeyItem(k))
+	if err != nil {
+		return fmt.Errorf("failed to marshal api key: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store api key: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) GetAPIKey(ctx context.Context, userID, id string) (*apikey.Key, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key:       apiKeyKey(userID, id),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	var item APIKeyItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal api key: %w", err)
+	}
+	return toAPIKey(item)
+}
+
+func (r *UserRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*apikey.Key, error) {
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "APIKEY#" + hash},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	var item APIKeyItem
+	if err := attributevalue.UnmarshalMap(result.Items[0], &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal api key: %w", err)
+	}
+	return toAPIKey(item)
+}
+
+func (r *UserRepository) ListAPIKeys(ctx context.Context, userID string) ([]*apikey.Key, error) {
+	var keys []*apikey.Key
+	var lastKey map[string]types.AttributeValue
+	for {
+		result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+				":sk": &types.AttributeValueMemberS{Value: "APIKEY#"},
+			},
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list api keys: %w", err)
+		}
+
+		var items []APIKeyItem
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal api keys: %w", err)
+		}
+		for _, item := range items {
+			key, err := toAPIKey(item)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		lastKey = result.LastEvaluatedKey
+	}
+	return keys, nil
+}
+
+func (r *UserRepository) RevokeAPIKey(ctx context.Context, k *apikey.Key, at time.Time) error {
+	if err := r.setAPIKeyTime(ctx, k, "revoked_at", at); err != nil {
+		return err
+	}
+	k.RevokedAt = &at
+	return nil
+}
+
+func (r *UserRepository) ExpireAPIKey(ctx context.Context, k *apikey.Key, at time.Time) error {
+	if err := r.setAPIKeyTime(ctx, k, "expires_at", at); err != nil {
+		return err
+	}
+	k.ExpiresAt = &at
+	return nil
+}
+
+func (r *UserRepository) TouchAPIKey(ctx context.Context, k *apikey.Key, at time.Time) error {
+	if err := r.setAPIKeyTime(ctx, k, "last_used_at", at); err != nil {
+		return err
+	}
+	k.LastUsedAt = &at
+	return nil
+}
+
+// setAPIKeyTime sets a timestamp attribute of an existing key
+func (r *UserRepository) setAPIKeyTime(ctx context.Context, k *apikey.Key, field string, at time.Time) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:                aws.String(r.tableName),
+		Key:                      apiKeyKey(k.UserID, k.ID),
+		UpdateExpression:         aws.String("SET #field = :at"),
+		ConditionExpression:      aws.String("attribute_exists(PK)"),
+		ExpressionAttributeNames: map[string]string{"#field": field},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":at": &types.AttributeValueMemberS{Value: at.UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrAPIKeyNotFound
+		}
+		return fmt.Errorf("failed to update api key: %w", err)
+	}
+	return nil
+}
+
+func apiKeyKey(userID, id string) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+		"SK": &types.AttributeValueMemberS{Value: "APIKEY#" + id},
+	}
+}
+
+func toAPIKeyItem(k *apikey.Key) APIKeyItem {
+	return APIKeyItem{
+		PK:              "USER#" + k.UserID,
+		SK:              "APIKEY#" + k.ID,
+		GSI1PK:          "APIKEY#" + k.Hash,
+		GSI1SK:          "APIKEY#" + k.ID,
+		ID:              k.ID,
+		UserID:          k.UserID,
+		Name:            k.Name,
+		Prefix:          k.Prefix,
+		Hash:            k.Hash,
+		Scopes:          k.Scopes,
+		AllowedChannels: k.AllowedChannels,
+		CreatedAt:       k.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:       formatOptionalTime(k.ExpiresAt),
+		LastUsedAt:      formatOptionalTime(k.LastUsedAt),
+		RevokedAt:       formatOptionalTime(k.RevokedAt),
+	}
+}
+
+func toAPIKey(item APIKeyItem) (*apikey.Key, error) {
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	key := &apikey.Key{
+		ID:              item.ID,
+		UserID:          item.UserID,
+		Name:            item.Name,
+		Prefix:          item.Prefix,
+		Hash:            item.Hash,
+		Scopes:          item.Scopes,
+		AllowedChannels: item.AllowedChannels,
+		CreatedAt:       createdAt,
+	}
+
+	timestamps := []struct {
+		value string
+		dest  **time.Time
+		name  string
+	}{
+		{item.ExpiresAt, &key.ExpiresAt, "expires_at"},
+		{item.LastUsedAt, &key.LastUsedAt, "last_used_at"},
+		{item.RevokedAt, &key.RevokedAt, "revoked_at"},
+	}
+	for _, ts := range timestamps {
+		parsed, err := parseOptionalTime(ts.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", ts.name, err)
+		}
+		*ts.dest = parsed
+	}
+	return key, nil
+}
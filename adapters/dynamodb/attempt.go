@@ -0,0 +1,136 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/notification"
+)
+
+// AttemptItem is a delivery attempt stored in the partition of the notification's user
+// It has no GSI1 keys so it never shows up in lookups by notification ID
+type AttemptItem struct {
+	PK                string `dynamodbav:"PK"` // USER#<userID>
+	SK                string `dynamodbav:"SK"` // ATTEMPT#<notificationID>#<retry>[#<step>]
+	NotificationID    string `dynamodbav:"notification_id"`
+	Retry             int    `dynamodbav:"retry"`
+	Channel           string `dynamodbav:"channel,omitempty"`
+	Step              int    `dynamodbav:"step,omitempty"`
+	Provider          string `dynamodbav:"provider"`
+	ProviderMessageID string `dynamodbav:"provider_message_id,omitempty"`
+	StartedAt         string `dynamodbav:"started_at"` // ISO8601 string
+	EndedAt           string `dynamodbav:"ended_at"`   // ISO8601 string
+	Error             string `dynamodbav:"error,omitempty"`
+}
+
+func (r *NotificationRepository) CreateAttempt(ctx context.Context, a *notification.Attempt) error {
+	av, err := attributevalue.MarshalMap(toAttemptItem(a))
+	if err != nil {
+		return fmt.Errorf("failed to marshal attempt: %w", err)
+	}
+
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store attempt: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ListAttempts(ctx context.Context, n *notification.Notification) ([]*notification.Attempt, error) {
+	var attempts []*notification.Attempt
+	var lastKey map[string]types.AttributeValue
+	for {
+		result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "USER#" + n.UserID},
+				":sk": &types.AttributeValueMemberS{Value: attemptPrefix(n.ID)},
+			},
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list attempts: %w", err)
+		}
+
+		var items []AttemptItem
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal attempts: %w", err)
+		}
+		for _, item := range items {
+			attempt, err := toAttempt(item, n.UserID)
+			if err != nil {
+				return nil, err
+			}
+			attempts = append(attempts, attempt)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		lastKey = result.LastEvaluatedKey
+	}
+
+	if attempts == nil {
+		attempts = []*notification.Attempt{}
+	}
+	return attempts, nil
+}
+
+func attemptPrefix(notificationID string) string {
+	return "ATTEMPT#" + notificationID + "#"
+}
+
+func toAttemptItem(a *notification.Attempt) AttemptItem {
+	// zero padded so attempts sort by retry, fallback steps sort after the first channel
+	sk := fmt.Sprintf("%s%04d", attemptPrefix(a.NotificationID), a.Retry)
+	if a.Step > 0 {
+		sk += fmt.Sprintf("#%02d", a.Step)
+	}
+	return AttemptItem{
+		PK:                "USER#" + a.UserID,
+		SK:                sk,
+		NotificationID:    a.NotificationID,
+		Retry:             a.Retry,
+		Channel:           a.Channel,
+		Step:              a.Step,
+		Provider:          a.Provider,
+		ProviderMessageID: a.ProviderMessageID,
+		StartedAt:         a.StartedAt.Format(time.RFC3339Nano),
+		EndedAt:           a.EndedAt.Format(time.RFC3339Nano),
+		Error:             a.Error,
+	}
+}
+
+func toAttempt(item AttemptItem, userID string) (*notification.Attempt, error) {
+	startedAt, err := time.Parse(time.RFC3339Nano, item.StartedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse started_at: %w", err)
+	}
+	endedAt, err := time.Parse(time.RFC3339Nano, item.EndedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ended_at: %w", err)
+	}
+
+	return &notification.Attempt{
+		NotificationID:    item.NotificationID,
+		UserID:            userID,
+		Retry:             item.Retry,
+		Channel:           item.Channel,
+		Step:              item.Step,
+		Provider:          item.Provider,
+		ProviderMessageID: item.ProviderMessageID,
+		StartedAt:         startedAt,
+		EndedAt:           endedAt,
+		Error:             item.Error,
+	}, nil
+}
@@ -0,0 +1,197 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/notification"
+)
+
+var ErrBatchNotFound = notification.ErrBatchNotFound
+
+const (
+	// maxBatchWriteItems is the most items a BatchWriteItem request accepts
+	maxBatchWriteItems = 25
+	// batchWriteRetries is how many times the items DynamoDB did not process are sent again
+	batchWriteRetries = 5
+)
+
+// BatchItem is the parent record of the notifications created by a batch,
+// its notifications are found through GSI3
+type BatchItem struct {
+	PK        string `dynamodbav:"PK"` // BATCH#<batchID>
+	SK        string `dynamodbav:"SK"` // BATCH
+	ID        string `dynamodbav:"id"`
+	UserID    string `dynamodbav:"user_id"` // sender
+	Total     int    `dynamodbav:"total"`
+	Unstored  int    `dynamodbav:"unstored,omitempty"` // notifications that could not be stored
+	CreatedAt string `dynamodbav:"created_at"`         // ISO8601 string
+}
+
+func (r *NotificationRepository) CreateBatch(ctx context.Context, b *notification.Batch) error {
+	av, err := attributevalue.MarshalMap(toBatchItem(b))
+	if err != nil {
+		return fmt.Errorf("failed to marshal batch: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store batch: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) GetBatch(ctx context.Context, id string) (*notification.Batch, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "BATCH#" + id},
+			"SK": &types.AttributeValueMemberS{Value: "BATCH"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrBatchNotFound
+	}
+
+	var item BatchItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal batch: %w", err)
+	}
+	return toBatch(item)
+}
+
+// AddUnstored adds count to the notifications of the batch that could not be stored
+func (r *NotificationRepository) AddUnstored(ctx context.Context, id string, count int) error {
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "BATCH#" + id},
+			"SK": &types.AttributeValueMemberS{Value: "BATCH"},
+		},
+		UpdateExpression:    aws.String("ADD unstored :count"),
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":count": &types.AttributeValueMemberN{Value: strconv.Itoa(count)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update batch: %w", err)
+	}
+	return nil
+}
+
+// CreateMany writes the notifications with a single BatchWriteItem
+func (r *NotificationRepository) CreateMany(ctx context.Context, notifications []*notification.Notification) error {
+	if len(notifications) > maxBatchWriteItems {
+		return fmt.Errorf("%w: at most %d notifications per request", ErrCreatingNotification, maxBatchWriteItems)
+	}
+	requests := make([]types.WriteRequest, len(notifications))
+	for i, n := range notifications {
+		av, err := attributevalue.MarshalMap(toItem(n))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrCreatingNotification, err)
+		}
+		requests[i] = types.WriteRequest{PutRequest: &types.PutRequest{Item: av}}
+	}
+	if err := r.batchWrite(ctx, requests); err != nil {
+		return fmt.Errorf("%w: %v", ErrStoringNotification, err)
+	}
+	return nil
+}
+
+// batchWrite sends up to 25 write requests with BatchWriteItem, sending the
+// unprocessed ones again with a growing backoff
+func (r *NotificationRepository) batchWrite(ctx context.Context, requests []types.WriteRequest) error {
+	backoff := 50 * time.Millisecond
+	for retry := 0; len(requests) > 0; retry++ {
+		if retry > batchWriteRetries {
+			return fmt.Errorf("%d items were not processed", len(requests))
+		}
+		if retry > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		output, err := r.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]types.WriteRequest{r.tableName: requests},
+		})
+		if err != nil {
+			return err
+		}
+		requests = output.UnprocessedItems[r.tableName]
+	}
+	return nil
+}
+
+// CountBatch reads the status of every notification of the batch from GSI3
+func (r *NotificationRepository) CountBatch(ctx context.Context, id string) (map[notification.Status]int, error) {
+	counts := make(map[notification.Status]int)
+	input := &dynamodb.QueryInput{
+		TableName:                aws.String(r.tableName),
+		IndexName:                aws.String("GSI3"),
+		KeyConditionExpression:   aws.String("GSI3PK = :pk"),
+		ProjectionExpression:     aws.String("#status"),
+		ExpressionAttributeNames: map[string]string{"#status": "status"},
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "BATCH#" + id},
+		},
+	}
+	paginator := dynamodb.NewQueryPaginator(r.client, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query batch notifications: %w", err)
+		}
+		var items []struct {
+			Status string `dynamodbav:"status"`
+		}
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal batch notifications: %w", err)
+		}
+		for _, item := range items {
+			counts[notification.Status(item.Status)]++
+		}
+	}
+	return counts, nil
+}
+
+func toBatchItem(b *notification.Batch) BatchItem {
+	return BatchItem{
+		PK:        "BATCH#" + b.ID,
+		SK:        "BATCH",
+		ID:        b.ID,
+		UserID:    b.UserID,
+		Total:     b.Total,
+		CreatedAt: b.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toBatch(item BatchItem) (*notification.Batch, error) {
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	return &notification.Batch{
+		ID:        item.ID,
+		UserID:    item.UserID,
+		Total:     item.Total,
+		Unstored:  item.Unstored,
+		CreatedAt: createdAt,
+	}, nil
+}
@@ -0,0 +1,75 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"serverless-notification/domain/notification"
+)
+
+func TestToItem_BatchNotificationIsInBatchIndex(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)
+	notif := &notification.Notification{
+		ID:          "n1",
+		UserID:      "usr_123",
+		ChannelName: "email",
+		CreatedAt:   createdAt,
+		UpdatedAt:   createdAt,
+		Status:      notification.StatusPending,
+		BatchID:     "b1",
+	}
+
+	// Act
+	item := toItem(notif)
+	got, err := toEntity(item)
+
+	// Assert
+	if item.GSI3PK != "BATCH#b1" || item.GSI3SK != "n1" {
+		t.Errorf("GSI3: expected BATCH#b1 / n1, got %s / %s", item.GSI3PK, item.GSI3SK)
+	}
+	if err != nil {
+		t.Fatalf("toEntity: %v", err)
+	}
+	if got.BatchID != "b1" {
+		t.Errorf("BatchID: expected b1, got %s", got.BatchID)
+	}
+}
+
+func TestToItem_SingleNotificationIsNotInBatchIndex(t *testing.T) {
+	// Arrange
+	notif := &notification.Notification{ID: "n1", UserID: "usr_123", CreatedAt: time.Now(), UpdatedAt: time.Now()}
+
+	// Act
+	item := toItem(notif)
+
+	// Assert
+	if item.GSI3PK != "" || item.GSI3SK != "" {
+		t.Errorf("expected no GSI3 keys, got %s / %s", item.GSI3PK, item.GSI3SK)
+	}
+}
+
+func TestToBatchItemAndBack(t *testing.T) {
+	// Arrange
+	batch := &notification.Batch{
+		ID:        "b1",
+		UserID:    "usr_ops",
+		Total:     1000,
+		CreatedAt: time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC),
+	}
+
+	// Act
+	item := toBatchItem(batch)
+	got, err := toBatch(item)
+
+	// Assert
+	if item.PK != "BATCH#b1" || item.SK != "BATCH" {
+		t.Errorf("keys: expected BATCH#b1 / BATCH, got %s / %s", item.PK, item.SK)
+	}
+	if err != nil {
+		t.Fatalf("toBatch: %v", err)
+	}
+	if got.ID != batch.ID || got.UserID != batch.UserID || got.Total != batch.Total || !got.CreatedAt.Equal(batch.CreatedAt) {
+		t.Errorf("expected %+v, got %+v", batch, got)
+	}
+}
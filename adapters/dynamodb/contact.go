@@ -0,0 +1,242 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/contact"
+)
+
+var ErrContactNotFound = contact.ErrContactNotFound
+
+const (
+	contactEmail  = "EMAIL"
+	contactPhone  = "PHONE"
+	contactDevice = "DEVICE"
+)
+
+// ContactItem is an email, phone or push device of a user, stored in the user's partition
+// Only the attributes of its contact_type are set
+type ContactItem struct {
+	PK          string `dynamodbav:"PK"` // USER#<userID>
+	SK          string `dynamodbav:"SK"` // CONTACT#<type>#<address, number or device id>
+	ContactType string `dynamodbav:"contact_type"`
+	CreatedAt   string `dynamodbav:"created_at"`           // ISO8601 string
+	UpdatedAt   string `dynamodbav:"updated_at,omitempty"` // ISO8601 string
+
+	Address       string `dynamodbav:"address,omitempty"`
+	Verified      bool   `dynamodbav:"verified,omitempty"`
+	VerifiedAt    string `dynamodbav:"verified_at,omitempty"` // ISO8601 string
+	CodeHash      string `dynamodbav:"code_hash,omitempty"`
+	CodeExpiresAt string `dynamodbav:"code_expires_at,omitempty"` // ISO8601 string
+	CodeAttempts  int    `dynamodbav:"code_attempts,omitempty"`
+
+	Number  string `dynamodbav:"number,omitempty"`
+	Carrier string `dynamodbav:"carrier,omitempty"`
+
+	DeviceID string `dynamodbav:"device_id,omitempty"`
+	Token    string `dynamodbav:"token,omitempty"`
+	Platform string `dynamodbav:"platform,omitempty"`
+}
+
+func (r *UserRepository) GetProfile(ctx context.Context, userID string) (*contact.Profile, error) {
+	profile := &contact.Profile{Emails: []*contact.Email{}, Phones: []*contact.Phone{}, Devices: []*contact.Device{}}
+	var lastKey map[string]types.AttributeValue
+	for {
+		result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(r.tableName),
+			KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":pk": &types.AttributeValueMemberS{Value: "USER#" + userID},
+				":sk": &types.AttributeValueMemberS{Value: "CONTACT#"},
+			},
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get contacts: %w", err)
+		}
+
+		var items []ContactItem
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal contacts: %w", err)
+		}
+		for _, item := range items {
+			if err := addToProfile(profile, item); err != nil {
+				return nil, err
+			}
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		lastKey = result.LastEvaluatedKey
+	}
+
+	sort.SliceStable(profile.Emails, func(i, j int) bool {
+		return profile.Emails[i].CreatedAt.Before(profile.Emails[j].CreatedAt)
+	})
+	sort.SliceStable(profile.Phones, func(i, j int) bool {
+		return profile.Phones[i].CreatedAt.Before(profile.Phones[j].CreatedAt)
+	})
+	return profile, nil
+}
+
+func (r *UserRepository) PutEmail(ctx context.Context, userID string, e *contact.Email) error {
+	return r.putContact(ctx, toEmailItem(userID, e))
+}
+
+func (r *UserRepository) DeleteEmail(ctx context.Context, userID, address string) error {
+	return r.deleteContact(ctx, userID, contactEmail, address)
+}
+
+func (r *UserRepository) PutPhone(ctx context.Context, userID string, p *contact.Phone) error {
+	return r.putContact(ctx, toPhoneItem(userID, p))
+}
+
+func (r *UserRepository) DeletePhone(ctx context.Context, userID, number string) error {
+	return r.deleteContact(ctx, userID, contactPhone, number)
+}
+
+func (r *UserRepository) PutDevice(ctx context.Context, userID string, d *contact.Device) error {
+	return r.putContact(ctx, toDeviceItem(userID, d))
+}
+
+func (r *UserRepository) DeleteDevice(ctx context.Context, userID, id string) error {
+	return r.deleteContact(ctx, userID, contactDevice, id)
+}
+
+func (r *UserRepository) putContact(ctx context.Context, item ContactItem) error {
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return fmt.Errorf("failed to marshal contact: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store contact: %w", err)
+	}
+	return nil
+}
+
+func (r *UserRepository) deleteContact(ctx context.Context, userID, contactType, id string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: contactSK(contactType, id)},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrContactNotFound
+		}
+		return fmt.Errorf("failed to delete contact: %w", err)
+	}
+	return nil
+}
+
+func contactSK(contactType, id string) string {
+	return "CONTACT#" + contactType + "#" + id
+}
+
+func toEmailItem(userID string, e *contact.Email) ContactItem {
+	return ContactItem{
+		PK:            "USER#" + userID,
+		SK:            contactSK(contactEmail, e.Address),
+		ContactType:   contactEmail,
+		CreatedAt:     e.CreatedAt.Format(time.RFC3339),
+		Address:       e.Address,
+		Verified:      e.Verified,
+		VerifiedAt:    formatOptionalTime(e.VerifiedAt),
+		CodeHash:      e.CodeHash,
+		CodeExpiresAt: formatOptionalTime(e.CodeExpiresAt),
+		CodeAttempts:  e.CodeAttempts,
+	}
+}
+
+func toPhoneItem(userID string, p *contact.Phone) ContactItem {
+	return ContactItem{
+		PK:          "USER#" + userID,
+		SK:          contactSK(contactPhone, p.Number),
+		ContactType: contactPhone,
+		CreatedAt:   p.CreatedAt.Format(time.RFC3339),
+		Number:      p.Number,
+		Carrier:     p.Carrier,
+	}
+}
+
+func toDeviceItem(userID string, d *contact.Device) ContactItem {
+	return ContactItem{
+		PK:          "USER#" + userID,
+		SK:          contactSK(contactDevice, d.ID),
+		ContactType: contactDevice,
+		CreatedAt:   d.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:   d.UpdatedAt.Format(time.RFC3339),
+		DeviceID:    d.ID,
+		Token:       d.Token,
+		Platform:    d.Platform,
+	}
+}
+
+// addToProfile converts item and appends it to the list of its contact type
+func addToProfile(profile *contact.Profile, item ContactItem) error {
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to parse created_at: %w", err)
+	}
+
+	switch item.ContactType {
+	case contactEmail:
+		verifiedAt, err := parseOptionalTime(item.VerifiedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse verified_at: %w", err)
+		}
+		codeExpiresAt, err := parseOptionalTime(item.CodeExpiresAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse code_expires_at: %w", err)
+		}
+		profile.Emails = append(profile.Emails, &contact.Email{
+			Address:       item.Address,
+			Verified:      item.Verified,
+			CreatedAt:     createdAt,
+			VerifiedAt:    verifiedAt,
+			CodeHash:      item.CodeHash,
+			CodeExpiresAt: codeExpiresAt,
+			CodeAttempts:  item.CodeAttempts,
+		})
+	case contactPhone:
+		profile.Phones = append(profile.Phones, &contact.Phone{
+			Number:    item.Number,
+			Carrier:   item.Carrier,
+			CreatedAt: createdAt,
+		})
+	case contactDevice:
+		updatedAt, err := time.Parse(time.RFC3339, item.UpdatedAt)
+		if err != nil {
+			return fmt.Errorf("failed to parse updated_at: %w", err)
+		}
+		profile.Devices = append(profile.Devices, &contact.Device{
+			ID:        item.DeviceID,
+			Token:     item.Token,
+			Platform:  item.Platform,
+			CreatedAt: createdAt,
+			UpdatedAt: updatedAt,
+		})
+	default:
+		return fmt.Errorf("unknown contact type %q in %s", item.ContactType, strings.TrimPrefix(item.PK, "USER#"))
+	}
+	return nil
+}
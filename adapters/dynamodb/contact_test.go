@@ -0,0 +1,57 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"serverless-notification/domain/contact"
+)
+
+func TestContactItemsToProfile(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 2, 15, 30, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(15 * time.Minute)
+	email := &contact.Email{Address: "user@example.com", CreatedAt: createdAt, CodeHash: "deadbeef", CodeExpiresAt: &expiresAt, CodeAttempts: 2}
+	phone := &contact.Phone{Number: "+1234567890", Carrier: "att", CreatedAt: createdAt}
+	device := &contact.Device{ID: "d1", Token: "token-abcdef", Platform: "ios", CreatedAt: createdAt, UpdatedAt: createdAt}
+	items := []ContactItem{toEmailItem("usr_123", email), toPhoneItem("usr_123", phone), toDeviceItem("usr_123", device)}
+
+	// Act
+	profile := &contact.Profile{}
+	for _, item := range items {
+		if err := addToProfile(profile, item); err != nil {
+			t.Fatalf("addToProfile: %v", err)
+		}
+	}
+
+	// Assert
+	if items[0].PK != "USER#usr_123" || items[0].SK != "CONTACT#EMAIL#user@example.com" {
+		t.Fatalf("unexpected email keys %s %s", items[0].PK, items[0].SK)
+	}
+	if items[1].SK != "CONTACT#PHONE#+1234567890" || items[2].SK != "CONTACT#DEVICE#d1" {
+		t.Fatalf("unexpected keys %s %s", items[1].SK, items[2].SK)
+	}
+	if len(profile.Emails) != 1 || len(profile.Phones) != 1 || len(profile.Devices) != 1 {
+		t.Fatalf("unexpected profile: %+v", profile)
+	}
+	got := profile.Emails[0]
+	if got.Address != email.Address || got.CodeHash != email.CodeHash || got.CodeAttempts != 2 || !got.CodeExpiresAt.Equal(expiresAt) {
+		t.Fatalf("unexpected email: %+v", got)
+	}
+	if profile.Phones[0].Carrier != "att" || profile.Devices[0].Token != "token-abcdef" || profile.Devices[0].Platform != "ios" {
+		t.Fatalf("unexpected phone or device: %+v %+v", profile.Phones[0], profile.Devices[0])
+	}
+}
+
+func TestAddToProfile_UnknownContactType(t *testing.T) {
+	// Arrange
+	item := ContactItem{PK: "USER#usr_123", SK: "CONTACT#FAX#1", ContactType: "FAX", CreatedAt: "2024-11-02T15:30:00Z"}
+
+	// Act
+	err := addToProfile(&contact.Profile{}, item)
+
+	// Assert
+	if err == nil {
+		t.Fatal("expected an error for an unknown contact type")
+	}
+}
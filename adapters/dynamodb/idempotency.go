@@ -0,0 +1,163 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/notification"
+)
+
+// idempotency keys are remembered for a day, then removed by the table TTL
+const idempotencyRetention = 24 * time.Hour
+
+// IdempotencyItem is the record of an Idempotency-Key, stored in the partition of its caller
+type IdempotencyItem struct {
+	PK          string `dynamodbav:"PK"` // USER#<userID>
+	SK          string `dynamodbav:"SK"` // IDEMPOTENCY#<key>
+	Key         string `dynamodbav:"idempotency_key"`
+	UserID      string `dynamodbav:"user_id"`
+	RequestHash string `dynamodbav:"request_hash"`
+	Response    string `dynamodbav:"response,omitempty"` // JSON encoded Notification, empty while in progress
+	CreatedAt   string `dynamodbav:"created_at"`         // ISO8601 string
+	ExpiresAt   int64  `dynamodbav:"expires_at"`         // TTL, epoch seconds
+	LockedUntil int64  `dynamodbav:"locked_until"`       // epoch seconds, an in progress record is abandoned after it
+}
+
+func (r *NotificationRepository) ReserveIdempotencyKey(ctx context.Context, record *notification.IdempotencyRecord) (*notification.IdempotencyRecord, error) {
+	item, err := toIdempotencyItem(record)
+	if err != nil {
+		return nil, err
+	}
+	av, err := attributevalue.MarshalMap(item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal idempotency record: %w", err)
+	}
+
+	// TTL deletes are lazy, so an expired record is overwritten as if it did not exist,
+	// and so is a reservation abandoned by a request that crashed before completing it
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+		ConditionExpression: aws.String("attribute_not_exists(PK) OR expires_at < :now OR " +
+			"(attribute_not_exists(response) AND (attribute_not_exists(locked_until) OR locked_until <= :now))"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":now": &types.AttributeValueMemberN{Value: strconv.FormatInt(record.CreatedAt.Unix(), 10)},
+		},
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if !errors.As(err, &conditionErr) {
+			return nil, fmt.Errorf("failed to store idempotency record: %w", err)
+		}
+		var existing IdempotencyItem
+		if err := attributevalue.UnmarshalMap(conditionErr.Item, &existing); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal idempotency record: %w", err)
+		}
+		existingRecord, err := toIdempotencyRecord(existing)
+		if err != nil {
+			return nil, err
+		}
+		return existingRecord, notification.ErrDuplicateNotification
+	}
+	return nil, nil
+}
+
+func (r *NotificationRepository) CompleteIdempotencyKey(ctx context.Context, record *notification.IdempotencyRecord) error {
+	response, err := json.Marshal(record.Response)
+	if err != nil {
+		return fmt.Errorf("failed to marshal idempotent response: %w", err)
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 idempotencyKey(record),
+		UpdateExpression:    aws.String("SET response = :response"),
+		ConditionExpression: aws.String("request_hash = :hash"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":response": &types.AttributeValueMemberS{Value: string(response)},
+			":hash":     &types.AttributeValueMemberS{Value: record.RequestHash},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency record: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ReleaseIdempotencyKey(ctx context.Context, record *notification.IdempotencyRecord) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName:           aws.String(r.tableName),
+		Key:                 idempotencyKey(record),
+		ConditionExpression: aws.String("request_hash = :hash AND attribute_not_exists(response)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":hash": &types.AttributeValueMemberS{Value: record.RequestHash},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to release idempotency record: %w", err)
+	}
+	return nil
+}
+
+func idempotencyKey(record *notification.IdempotencyRecord) map[string]types.AttributeValue {
+	return map[string]types.AttributeValue{
+		"PK": &types.AttributeValueMemberS{Value: "USER#" + record.UserID},
+		"SK": &types.AttributeValueMemberS{Value: "IDEMPOTENCY#" + record.Key},
+	}
+}
+
+func toIdempotencyItem(record *notification.IdempotencyRecord) (IdempotencyItem, error) {
+	item := IdempotencyItem{
+		PK:          "USER#" + record.UserID,
+		SK:          "IDEMPOTENCY#" + record.Key,
+		Key:         record.Key,
+		UserID:      record.UserID,
+		RequestHash: record.RequestHash,
+		CreatedAt:   record.CreatedAt.Format(time.RFC3339),
+		ExpiresAt:   record.CreatedAt.Add(idempotencyRetention).Unix(),
+		LockedUntil: record.LockedUntil.Unix(),
+	}
+	if record.Response != nil {
+		response, err := json.Marshal(record.Response)
+		if err != nil {
+			return IdempotencyItem{}, fmt.Errorf("failed to marshal idempotent response: %w", err)
+		}
+		item.Response = string(response)
+	}
+	return item, nil
+}
+
+func toIdempotencyRecord(item IdempotencyItem) (*notification.IdempotencyRecord, error) {
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	record := &notification.IdempotencyRecord{
+		Key:         item.Key,
+		UserID:      item.UserID,
+		RequestHash: item.RequestHash,
+		CreatedAt:   createdAt,
+		LockedUntil: time.Unix(item.LockedUntil, 0),
+	}
+	if item.Response != "" {
+		record.Response = &notification.Notification{}
+		if err := json.Unmarshal([]byte(item.Response), record.Response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal idempotent response: %w", err)
+		}
+	}
+	return record, nil
+}
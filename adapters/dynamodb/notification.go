@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,27 +24,71 @@ var (
 	ErrCreatingNotification = errors.New("failed to create notification")
 	ErrStoringNotification  = errors.New("failed to store notification in dynamodb")
 	ErrPagination           = errors.New("failed to paginate notifications")
-	ErrNotificationNotFound = errors.New("notification not found")
+	ErrNotificationNotFound = notification.ErrNotificationNotFound
 )
 
+// notDeleted matches items that were not soft deleted
+// Items written before deleted_at was omitted when empty store it as ""
+const notDeleted = "(attribute_not_exists(deleted_at) OR deleted_at = :empty)"
+
+// dueShards is how many GSI2 partitions the scheduled notifications are spread over,
+// so their writes do not all go to one partition
+const dueShards = 4
+
 type NotificationRepository struct {
 	client    *dynamodb.Client
 	tableName string
 }
 
 type NotificationItem struct {
-	PK          string `dynamodbav:"PK"`     // USER#<userID>
-	SK          string `dynamodbav:"SK"`     // NOTIF#<ISO8601_timestamp>#<ulid>
-	GSI1PK      string `dynamodbav:"GSI1PK"` // NOTIF#<id>
-	GSI1SK      string `dynamodbav:"GSI1SK"` // <ISO8601_timestamp>#<ulid>
-	ID          string `dynamodbav:"id"`
-	UserID      string `dynamodbav:"user_id"`
-	Title       string `dynamodbav:"title"`
-	Content     string `dynamodbav:"content"`
-	ChannelName string `dynamodbav:"channel_name"`
-	CreatedAt   string `dynamodbav:"created_at"` // ISO8601 string
-	UpdatedAt   string `dynamodbav:"updated_at"` // ISO8601 string
-	DeletedAt   string `dynamodbav:"deleted_at"` // ISO8601 string
+	PK          string            `dynamodbav:"PK"`               // USER#<userID>
+	SK          string            `dynamodbav:"SK"`               // NOTIF#<ISO8601_timestamp>#<ulid>
+	GSI1PK      string            `dynamodbav:"GSI1PK"`           // NOTIF#<id>
+	GSI1SK      string            `dynamodbav:"GSI1SK"`           // <ISO8601_timestamp>#<ulid>
+	GSI2PK      string            `dynamodbav:"GSI2PK,omitempty"` // DUE#<shard>, only while scheduled
+	GSI2SK      string            `dynamodbav:"GSI2SK,omitempty"` // <scheduled_at>#<id>, only while scheduled
+	GSI3PK      string            `dynamodbav:"GSI3PK,omitempty"` // BATCH#<batchID>, only for batch notifications
+	GSI3SK      string            `dynamodbav:"GSI3SK,omitempty"` // <id>
+	ID          string            `dynamodbav:"id"`
+	UserID      string            `dynamodbav:"user_id"`
+	Title       string            `dynamodbav:"title"`
+	Content     string            `dynamodbav:"content"`
+	ChannelName string            `dynamodbav:"channel_name"`
+	Fallback    []string          `dynamodbav:"fallback,omitempty"` // a list, the order matters
+	Category    string            `dynamodbav:"category,omitempty"`
+	Priority    string            `dynamodbav:"priority,omitempty"`
+	Meta        map[string]string `dynamodbav:"meta,omitempty"`
+	CreatedAt   string            `dynamodbav:"created_at"`           // ISO8601 string
+	UpdatedAt   string            `dynamodbav:"updated_at"`           // ISO8601 string
+	DeletedAt   string            `dynamodbav:"deleted_at,omitempty"` // ISO8601 string
+
+	ScheduledAt   string `dynamodbav:"scheduled_at,omitempty"` // ISO8601 string, UTC
+	TimeZone      string `dynamodbav:"time_zone,omitempty"`
+	RetryOffset   int    `dynamodbav:"retry_offset,omitempty"`
+	DeferredUntil string `dynamodbav:"deferred_until,omitempty"` // ISO8601 string, set by a quiet hours deferral
+
+	Status            string `dynamodbav:"status"`
+	QueuedAt          string `dynamodbav:"queued_at,omitempty"`     // ISO8601 string
+	SendingAt         string `dynamodbav:"sending_at,omitempty"`    // ISO8601 string
+	DeliveredAt       string `dynamodbav:"delivered_at,omitempty"`  // ISO8601 string
+	FailedAt          string `dynamodbav:"failed_at,omitempty"`     // ISO8601 string
+	CancelledAt       string `dynamodbav:"cancelled_at,omitempty"`  // ISO8601 string
+	SuppressedAt      string `dynamodbav:"suppressed_at,omitempty"` // ISO8601 string
+	FailureReason     string `dynamodbav:"failure_reason,omitempty"`
+	SuppressionReason string `dynamodbav:"suppression_reason,omitempty"`
+
+	DeliveredChannel string            `dynamodbav:"delivered_channel,omitempty"`
+	ChannelPath      []ChannelStepItem `dynamodbav:"channel_path,omitempty"`
+
+	BatchID  string `dynamodbav:"batch_id,omitempty"`
+	SenderID string `dynamodbav:"sender_id,omitempty"`
+	Topic    string `dynamodbav:"topic,omitempty"`
+}
+
+type ChannelStepItem struct {
+	Channel string `dynamodbav:"channel"`
+	At      string `dynamodbav:"at"` // ISO8601 string
+	Error   string `dynamodbav:"error,omitempty"`
 }
 
 // Constructor
@@ -109,13 +155,15 @@ func (r *NotificationRepository) List(ctx context.Context, query notification.Li
 	}
 	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
 		TableName:              aws.String(r.tableName),
-		KeyConditionExpression: aws.String("PK = :pk"),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sk)"),
 		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":pk": &types.AttributeValueMemberS{Value: "USER#" + query.UserID},
+			":pk":    &types.AttributeValueMemberS{Value: "USER#" + query.UserID},
+			":sk":    &types.AttributeValueMemberS{Value: "NOTIF#"},
+			":empty": &types.AttributeValueMemberS{Value: ""},
 		},
 		Limit:             aws.Int32(int32(query.Limit)),
 		ExclusiveStartKey: lastKey,
-		FilterExpression:  aws.String("attribute_not_exists(deleted_at)"),
+		FilterExpression:  aws.String(notDeleted),
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list notifications: %w", err)
@@ -150,22 +198,22 @@ func (r *NotificationRepository) List(ctx context.Context, query notification.Li
 	}, nil
 }
 
-func (r *NotificationRepository) Update(ctx context.Context, id string, updates map[string]interface{}) error {
-	existing, err := r.GetByID(ctx, id)
-	if err != nil {
-		return err
-	}
-
+func (r *NotificationRepository) Update(ctx context.Context, n *notification.Notification, updates map[string]interface{}) error {
 	var setParts []string
-	expressionValues := make(map[string]types.AttributeValue)
+	expressionNames := map[string]string{"#status": "status"}
+	expressionValues := map[string]types.AttributeValue{
+		":status": &types.AttributeValueMemberS{Value: string(n.Status)},
+	}
 
-	blacklist := []string{"id", "user_id", "created_at", "channel_name"}
+	blacklist := []string{"id", "user_id", "created_at", "channel_name", "status"}
 
 	for field, value := range updates {
 		if slices.Contains(blacklist, field) {
 			return fmt.Errorf("field %s is immutable and cannot be updated", field)
 		}
-		setParts = append(setParts, fmt.Sprintf("%s = :%s", field, field))
+		// attribute names go through placeholders, some of them are reserved words
+		setParts = append(setParts, fmt.Sprintf("#%s = :%s", field, field))
+		expressionNames["#"+field] = field
 		av, err := attributevalue.Marshal(value)
 		if err != nil {
 			return fmt.Errorf("failed to marshal update value: %w", err)
@@ -178,21 +226,25 @@ func (r *NotificationRepository) Update(ctx context.Context, id string, updates
 
 	updateExpression := "SET " + strings.Join(setParts, ", ")
 
-	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
 		TableName: aws.String(r.tableName),
 		Key: map[string]types.AttributeValue{
-			"PK": &types.AttributeValueMemberS{Value: "USER#" + existing.UserID},
-			"SK": &types.AttributeValueMemberS{Value: "NOTIF#" + existing.CreatedAt.Format(time.RFC3339) + "#" + existing.ID},
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + n.UserID},
+			"SK": &types.AttributeValueMemberS{Value: "NOTIF#" + n.CreatedAt.Format(time.RFC3339) + "#" + n.ID},
 		},
 		UpdateExpression:          aws.String(updateExpression),
-		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ConditionExpression:       aws.String("attribute_exists(PK) AND #status = :status"),
+		ExpressionAttributeNames:  expressionNames,
 		ExpressionAttributeValues: expressionValues,
 	})
 	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s is no longer %s", notification.ErrStatusChanged, n.ID, n.Status)
+		}
 		return fmt.Errorf("failed to update notification: %w", err)
 	}
 	return nil
-
 }
 
 func (r *NotificationRepository) Delete(ctx context.Context, id string) error {
@@ -203,6 +255,7 @@ func (r *NotificationRepository) Delete(ctx context.Context, id string) error {
 
 	expressionValues := map[string]types.AttributeValue{
 		":deleted_at": &types.AttributeValueMemberS{Value: time.Now().Format(time.RFC3339)},
+		":empty":      &types.AttributeValueMemberS{Value: ""},
 	}
 
 	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
@@ -213,7 +266,7 @@ func (r *NotificationRepository) Delete(ctx context.Context, id string) error {
 		},
 		UpdateExpression:          aws.String("SET deleted_at = :deleted_at"),
 		ExpressionAttributeValues: expressionValues,
-		ConditionExpression:       aws.String("attribute_not_exists(deleted_at)"),
+		ConditionExpression:       aws.String(notDeleted),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to delete notification: %w", err)
@@ -221,20 +274,229 @@ func (r *NotificationRepository) Delete(ctx context.Context, id string) error {
 	return nil
 }
 
+func (r *NotificationRepository) UpdateStatus(ctx context.Context, n *notification.Notification, change notification.StatusChange) error {
+	at := change.At.Format(time.RFC3339)
+	setParts := []string{"#status = :to", "updated_at = :at"}
+	expressionValues := map[string]types.AttributeValue{
+		":to":   &types.AttributeValueMemberS{Value: string(change.To)},
+		":from": &types.AttributeValueMemberS{Value: string(n.Status)},
+		":at":   &types.AttributeValueMemberS{Value: at},
+	}
+	if field := statusTimestampField(change.To); field != "" {
+		setParts = append(setParts, field+" = :at")
+	}
+	if change.Reason != "" {
+		setParts = append(setParts, statusReasonField(change.To)+" = :reason")
+		expressionValues[":reason"] = &types.AttributeValueMemberS{Value: change.Reason}
+	}
+	// Going back to scheduled puts the item in the due index again
+	if change.To == notification.StatusScheduled {
+		scheduledAt := change.ScheduledAt.UTC().Format(time.RFC3339)
+		setParts = append(setParts, "scheduled_at = :scheduled_at", "retry_offset = :retry_offset", "GSI2PK = :due_shard", "GSI2SK = :due_key")
+		expressionValues[":scheduled_at"] = &types.AttributeValueMemberS{Value: scheduledAt}
+		expressionValues[":retry_offset"] = &types.AttributeValueMemberN{Value: strconv.Itoa(change.RetryOffset)}
+		expressionValues[":due_shard"] = &types.AttributeValueMemberS{Value: dueShard(n.ID)}
+		expressionValues[":due_key"] = &types.AttributeValueMemberS{Value: scheduledAt + "#" + n.ID}
+		if change.Deferred {
+			setParts = append(setParts, "deferred_until = :scheduled_at")
+		}
+	}
+
+	// Items written before the status existed have no status attribute and are pending
+	condition := "#status = :from"
+	if n.Status == notification.StatusPending {
+		condition = "(#status = :from OR attribute_not_exists(#status))"
+	}
+
+	updateExpression := "SET " + strings.Join(setParts, ", ")
+	// Leaving scheduled takes the item out of the due index
+	if n.Status == notification.StatusScheduled {
+		updateExpression += " REMOVE GSI2PK, GSI2SK"
+	}
+
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + n.UserID},
+			"SK": &types.AttributeValueMemberS{Value: "NOTIF#" + n.CreatedAt.Format(time.RFC3339) + "#" + n.ID},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(PK) AND " + condition),
+		ExpressionAttributeNames:  map[string]string{"#status": "status"},
+		ExpressionAttributeValues: expressionValues,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s is no longer %s", notification.ErrInvalidTransition, n.ID, n.Status)
+		}
+		return fmt.Errorf("failed to update notification status: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) AppendChannelPath(ctx context.Context, n *notification.Notification, deliveredChannel string, steps []notification.ChannelStep) error {
+	stepValues, err := attributevalue.Marshal(toChannelStepItems(steps))
+	if err != nil {
+		return fmt.Errorf("failed to marshal channel path: %w", err)
+	}
+	updateExpression := "SET channel_path = list_append(if_not_exists(channel_path, :empty_list), :steps)"
+	expressionValues := map[string]types.AttributeValue{
+		":steps":      stepValues,
+		":empty_list": &types.AttributeValueMemberL{Value: []types.AttributeValue{}},
+	}
+	if deliveredChannel != "" {
+		updateExpression += ", delivered_channel = :channel"
+		expressionValues[":channel"] = &types.AttributeValueMemberS{Value: deliveredChannel}
+	}
+
+	_, err = r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + n.UserID},
+			"SK": &types.AttributeValueMemberS{Value: "NOTIF#" + n.CreatedAt.Format(time.RFC3339) + "#" + n.ID},
+		},
+		UpdateExpression:          aws.String(updateExpression),
+		ConditionExpression:       aws.String("attribute_exists(PK)"),
+		ExpressionAttributeValues: expressionValues,
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrNotificationNotFound
+		}
+		return fmt.Errorf("failed to update channel path: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ListDue(ctx context.Context, now time.Time) ([]*notification.Notification, error) {
+	now = now.UTC()
+	var due []*notification.Notification
+	// Each shard holds every scheduled notification of its IDs sorted by when it is due,
+	// so one query per shard finds them however overdue they are
+	for shard := 0; shard < dueShards; shard++ {
+		var lastKey map[string]types.AttributeValue
+		for {
+			result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+				TableName:              aws.String(r.tableName),
+				IndexName:              aws.String("GSI2"),
+				KeyConditionExpression: aws.String("GSI2PK = :shard AND GSI2SK <= :now"),
+				ExpressionAttributeValues: map[string]types.AttributeValue{
+					":shard": &types.AttributeValueMemberS{Value: "DUE#" + strconv.Itoa(shard)},
+					// '~' sorts after any ID, so every item due at now is included
+					":now": &types.AttributeValueMemberS{Value: now.Format(time.RFC3339) + "#~"},
+				},
+				ExclusiveStartKey: lastKey,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to query due notifications: %w", err)
+			}
+
+			var items []NotificationItem
+			if err := attributevalue.UnmarshalListOfMaps(result.Items, &items); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal notifications: %w", err)
+			}
+			for _, item := range items {
+				if item.DeletedAt != "" {
+					continue
+				}
+				entity, err := toEntity(item)
+				if err != nil {
+					return nil, fmt.Errorf("failed to convert notification: %w", err)
+				}
+				due = append(due, entity)
+			}
+
+			if result.LastEvaluatedKey == nil {
+				break
+			}
+			lastKey = result.LastEvaluatedKey
+		}
+	}
+	return due, nil
+}
+
+// dueShard returns the GSI2 partition of the notification id
+func dueShard(id string) string {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return "DUE#" + strconv.Itoa(int(h.Sum32()%dueShards))
+}
+
+// statusTimestampField returns the attribute holding when the status was reached
+func statusTimestampField(status notification.Status) string {
+	switch status {
+	case notification.StatusQueued:
+		return "queued_at"
+	case notification.StatusSending:
+		return "sending_at"
+	case notification.StatusDelivered:
+		return "delivered_at"
+	case notification.StatusFailed:
+		return "failed_at"
+	case notification.StatusCancelled:
+		return "cancelled_at"
+	case notification.StatusSuppressed:
+		return "suppressed_at"
+	}
+	return ""
+}
+
+// statusReasonField returns the attribute holding why the status was reached
+func statusReasonField(status notification.Status) string {
+	if status == notification.StatusSuppressed {
+		return "suppression_reason"
+	}
+	return "failure_reason"
+}
+
 func toItem(n *notification.Notification) NotificationItem {
-	return NotificationItem{
-		PK:          "USER#" + n.UserID,
-		SK:          "NOTIF#" + n.CreatedAt.Format(time.RFC3339) + "#" + n.ID,
-		GSI1PK:      "NOTIF#" + n.ID,
-		GSI1SK:      "NOTIF#" + n.ID,
-		ID:          n.ID,
-		UserID:      n.UserID,
-		Title:       n.Title,
-		Content:     n.Content,
-		ChannelName: n.ChannelName,
-		CreatedAt:   n.CreatedAt.Format(time.RFC3339),
-		UpdatedAt:   n.UpdatedAt.Format(time.RFC3339),
+	item := NotificationItem{
+		PK:            "USER#" + n.UserID,
+		SK:            "NOTIF#" + n.CreatedAt.Format(time.RFC3339) + "#" + n.ID,
+		GSI1PK:        "NOTIF#" + n.ID,
+		GSI1SK:        "NOTIF#" + n.ID,
+		ID:            n.ID,
+		UserID:        n.UserID,
+		Title:         n.Title,
+		Content:       n.Content,
+		ChannelName:   n.ChannelName,
+		Fallback:      n.Fallback,
+		Category:      n.Category,
+		Priority:      n.Priority,
+		Meta:          n.Meta,
+		CreatedAt:     n.CreatedAt.Format(time.RFC3339),
+		UpdatedAt:     n.UpdatedAt.Format(time.RFC3339),
+		ScheduledAt:   formatOptionalTime(n.ScheduledAt),
+		TimeZone:      n.TimeZone,
+		RetryOffset:   n.RetryOffset,
+		DeferredUntil: formatOptionalTime(n.DeferredUntil),
+
+		Status:            string(n.Status),
+		QueuedAt:          formatOptionalTime(n.QueuedAt),
+		SendingAt:         formatOptionalTime(n.SendingAt),
+		DeliveredAt:       formatOptionalTime(n.DeliveredAt),
+		FailedAt:          formatOptionalTime(n.FailedAt),
+		CancelledAt:       formatOptionalTime(n.CancelledAt),
+		SuppressedAt:      formatOptionalTime(n.SuppressedAt),
+		FailureReason:     n.FailureReason,
+		SuppressionReason: n.SuppressionReason,
+		DeliveredChannel:  n.DeliveredChannel,
+		ChannelPath:       toChannelStepItems(n.ChannelPath),
+		BatchID:           n.BatchID,
+		SenderID:          n.SenderID,
+		Topic:             n.Topic,
+	}
+	if n.BatchID != "" {
+		item.GSI3PK = "BATCH#" + n.BatchID
+		item.GSI3SK = n.ID
 	}
+	if n.Status == notification.StatusScheduled && n.ScheduledAt != nil {
+		item.GSI2PK = dueShard(n.ID)
+		item.GSI2SK = item.ScheduledAt + "#" + n.ID
+	}
+	return item
 }
 
 func toEntities(items []NotificationItem) ([]*notification.Notification, error) {
@@ -259,15 +521,94 @@ func toEntity(item NotificationItem) (*notification.Notification, error) {
 		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
 	}
 
-	return &notification.Notification{
-		ID:          item.ID,
-		UserID:      item.UserID,
-		Title:       item.Title,
-		Content:     item.Content,
-		ChannelName: item.ChannelName,
-		CreatedAt:   createdAt,
-		UpdatedAt:   updatedAt,
-	}, nil
+	status := notification.Status(item.Status)
+	if status == "" {
+		status = notification.StatusPending
+	}
+
+	n := &notification.Notification{
+		ID:                item.ID,
+		UserID:            item.UserID,
+		Title:             item.Title,
+		Content:           item.Content,
+		ChannelName:       item.ChannelName,
+		Fallback:          item.Fallback,
+		Category:          item.Category,
+		Priority:          item.Priority,
+		Meta:              item.Meta,
+		CreatedAt:         createdAt,
+		TimeZone:          item.TimeZone,
+		RetryOffset:       item.RetryOffset,
+		UpdatedAt:         updatedAt,
+		Status:            status,
+		FailureReason:     item.FailureReason,
+		SuppressionReason: item.SuppressionReason,
+		DeliveredChannel:  item.DeliveredChannel,
+		BatchID:           item.BatchID,
+		SenderID:          item.SenderID,
+		Topic:             item.Topic,
+	}
+
+	timestamps := []struct {
+		value string
+		dest  **time.Time
+		name  string
+	}{
+		{item.ScheduledAt, &n.ScheduledAt, "scheduled_at"},
+		{item.DeferredUntil, &n.DeferredUntil, "deferred_until"},
+		{item.QueuedAt, &n.QueuedAt, "queued_at"},
+		{item.SendingAt, &n.SendingAt, "sending_at"},
+		{item.DeliveredAt, &n.DeliveredAt, "delivered_at"},
+		{item.FailedAt, &n.FailedAt, "failed_at"},
+		{item.CancelledAt, &n.CancelledAt, "cancelled_at"},
+		{item.SuppressedAt, &n.SuppressedAt, "suppressed_at"},
+	}
+	for _, ts := range timestamps {
+		parsed, err := parseOptionalTime(ts.value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", ts.name, err)
+		}
+		*ts.dest = parsed
+	}
+
+	for _, step := range item.ChannelPath {
+		at, err := time.Parse(time.RFC3339Nano, step.At)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse channel_path: %w", err)
+		}
+		n.ChannelPath = append(n.ChannelPath, notification.ChannelStep{Channel: step.Channel, At: at, Error: step.Error})
+	}
+
+	return n, nil
+}
+
+func toChannelStepItems(steps []notification.ChannelStep) []ChannelStepItem {
+	if len(steps) == 0 {
+		return nil
+	}
+	items := make([]ChannelStepItem, len(steps))
+	for i, step := range steps {
+		items[i] = ChannelStepItem{Channel: step.Channel, At: step.At.Format(time.RFC3339Nano), Error: step.Error}
+	}
+	return items
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func parseOptionalTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
 }
 
 // Encode: DynamoDB map -> string
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/aws/aws-lambda-go/events"
+
 	"serverless-notification/domain/notification"
 )
 
@@ -205,3 +207,333 @@ func TestToEntities(t *testing.T) {
 	}
 }
 
+func TestToItemAndBack_Status(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)
+	failedAt := time.Date(2024, 11, 3, 15, 31, 0, 0, time.UTC)
+	notif := &notification.Notification{
+		ID:            "01HQ8XA2B3C4D5E6F7G8H9",
+		UserID:        "usr_123",
+		ChannelName:   "sms",
+		CreatedAt:     createdAt,
+		UpdatedAt:     failedAt,
+		Status:        notification.StatusFailed,
+		FailedAt:      &failedAt,
+		FailureReason: "carrier rejected",
+	}
+
+	// Act
+	item := toItem(notif)
+	entity, err := toEntity(item)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if item.Status != "failed" || item.FailedAt != "2024-11-03T15:31:00Z" || item.QueuedAt != "" {
+		t.Errorf("unexpected status attributes: %+v", item)
+	}
+	if entity.Status != notification.StatusFailed || entity.FailureReason != "carrier rejected" {
+		t.Errorf("Status: expected failed with reason, got %s %q", entity.Status, entity.FailureReason)
+	}
+	if entity.FailedAt == nil || !entity.FailedAt.Equal(failedAt) {
+		t.Errorf("FailedAt: expected %v, got %v", failedAt, entity.FailedAt)
+	}
+	if entity.QueuedAt != nil {
+		t.Errorf("QueuedAt: expected nil, got %v", entity.QueuedAt)
+	}
+}
+
+func TestToItemAndBack_Suppressed(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)
+	notif := &notification.Notification{
+		ID:                "01HQ8XA2B3C4D5E6F7G8H9",
+		UserID:            "usr_123",
+		ChannelName:       "sms",
+		Category:          "marketing",
+		CreatedAt:         createdAt,
+		UpdatedAt:         createdAt,
+		Status:            notification.StatusSuppressed,
+		SuppressedAt:      &createdAt,
+		SuppressionReason: "user opted out of marketing on sms",
+	}
+
+	// Act
+	item := toItem(notif)
+	entity, err := toEntity(item)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if item.Category != "marketing" || item.SuppressedAt != "2024-11-03T15:30:00Z" || item.FailureReason != "" {
+		t.Errorf("unexpected suppression attributes: %+v", item)
+	}
+	if entity.Category != "marketing" || entity.SuppressionReason != notif.SuppressionReason {
+		t.Errorf("expected category and reason, got %q %q", entity.Category, entity.SuppressionReason)
+	}
+	if entity.SuppressedAt == nil || !entity.SuppressedAt.Equal(createdAt) {
+		t.Errorf("SuppressedAt: expected %v, got %v", createdAt, entity.SuppressedAt)
+	}
+}
+
+func TestToItemAndBack_FallbackChain(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)
+	failedAt := createdAt.Add(time.Second)
+	notif := &notification.Notification{
+		ID:               "01HQ8XA2B3C4D5E6F7G8H9",
+		UserID:           "usr_123",
+		ChannelName:      "push",
+		Fallback:         []string{"sms", "email"},
+		CreatedAt:        createdAt,
+		UpdatedAt:        createdAt,
+		Status:           notification.StatusDelivered,
+		DeliveredChannel: "sms",
+		ChannelPath: []notification.ChannelStep{
+			{Channel: "push", At: failedAt, Error: "bad token"},
+			{Channel: "sms", At: failedAt.Add(time.Second)},
+		},
+	}
+
+	// Act
+	item := toItem(notif)
+	entity, err := toEntity(item)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entity.Fallback) != 2 || entity.Fallback[0] != "sms" || entity.Fallback[1] != "email" {
+		t.Errorf("Fallback: expected [sms email], got %v", entity.Fallback)
+	}
+	if entity.DeliveredChannel != "sms" || len(entity.ChannelPath) != 2 {
+		t.Fatalf("expected the path through sms, got %q %+v", entity.DeliveredChannel, entity.ChannelPath)
+	}
+	if step := entity.ChannelPath[0]; step.Channel != "push" || step.Error != "bad token" || !step.At.Equal(failedAt) {
+		t.Errorf("unexpected first step: %+v", step)
+	}
+}
+
+func TestToEntity_MissingStatusIsPending(t *testing.T) {
+	// Arrange - item written before the status existed
+	item := NotificationItem{
+		ID:        "01HQ8XA2B3C4D5E6F7G8H9",
+		CreatedAt: "2024-11-03T15:30:00Z",
+		UpdatedAt: "2024-11-03T15:30:00Z",
+	}
+
+	// Act
+	entity, err := toEntity(item)
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entity.Status != notification.StatusPending {
+		t.Errorf("Status: expected pending, got %s", entity.Status)
+	}
+}
+
+func TestToAttemptItem(t *testing.T) {
+	// Arrange
+	startedAt := time.Date(2024, 11, 3, 15, 30, 0, 500, time.UTC)
+	endedAt := time.Date(2024, 11, 3, 15, 30, 1, 0, time.UTC)
+	attempt := &notification.Attempt{
+		NotificationID:    "01HQ8XA2B3C4D5E6F7G8H9",
+		UserID:            "usr_123",
+		Retry:             2,
+		Provider:          "smtp",
+		ProviderMessageID: "msg-1",
+		StartedAt:         startedAt,
+		EndedAt:           endedAt,
+		Error:             "timeout",
+	}
+
+	// Act
+	item := toAttemptItem(attempt)
+	entity, err := toAttempt(item, "usr_123")
+
+	// Assert - Keys
+	if item.PK != "USER#usr_123" {
+		t.Errorf("PK: expected USER#usr_123, got %s", item.PK)
+	}
+	expectedSK := "ATTEMPT#01HQ8XA2B3C4D5E6F7G8H9#0002"
+	if item.SK != expectedSK {
+		t.Errorf("SK: expected %s, got %s", expectedSK, item.SK)
+	}
+
+	// Assert - Round trip
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !entity.StartedAt.Equal(startedAt) || !entity.EndedAt.Equal(endedAt) {
+		t.Errorf("Timestamps: expected %v-%v, got %v-%v", startedAt, endedAt, entity.StartedAt, entity.EndedAt)
+	}
+	if entity.Retry != 2 || entity.Provider != "smtp" || entity.ProviderMessageID != "msg-1" || entity.Error != "timeout" {
+		t.Errorf("unexpected attempt: %+v", entity)
+	}
+}
+
+func TestToAttemptItem_FallbackStepSortsAfterFirstChannel(t *testing.T) {
+	// Arrange
+	at := time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)
+	first := &notification.Attempt{NotificationID: "n1", UserID: "usr_123", Retry: 0, Channel: "push", StartedAt: at, EndedAt: at}
+	fallback := &notification.Attempt{NotificationID: "n1", UserID: "usr_123", Retry: 0, Channel: "sms", Step: 1, StartedAt: at, EndedAt: at}
+	retry := &notification.Attempt{NotificationID: "n1", UserID: "usr_123", Retry: 1, Channel: "push", StartedAt: at, EndedAt: at}
+
+	// Act
+	firstItem, fallbackItem, retryItem := toAttemptItem(first), toAttemptItem(fallback), toAttemptItem(retry)
+	entity, err := toAttempt(fallbackItem, "usr_123")
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if fallbackItem.SK != "ATTEMPT#n1#0000#01" {
+		t.Errorf("SK: expected ATTEMPT#n1#0000#01, got %s", fallbackItem.SK)
+	}
+	if !(firstItem.SK < fallbackItem.SK && fallbackItem.SK < retryItem.SK) {
+		t.Errorf("expected %s < %s < %s", firstItem.SK, fallbackItem.SK, retryItem.SK)
+	}
+	if entity.Channel != "sms" || entity.Step != 1 {
+		t.Errorf("unexpected attempt: %+v", entity)
+	}
+}
+
+func TestOutboxRecordFromStream(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)
+	notif := &notification.Notification{ID: "01HQ8XA2B3C4D5E6F7G8H9", UserID: "usr_123", CreatedAt: createdAt}
+	message := &notification.DispatchMessage{NotificationID: notif.ID, UserID: notif.UserID, ChannelName: "email", Title: "Hola"}
+	item, err := toOutboxItem(notif, message)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	image := map[string]events.DynamoDBAttributeValue{
+		"PK":              events.NewStringAttribute(item.PK),
+		"SK":              events.NewStringAttribute(item.SK),
+		"notification_id": events.NewStringAttribute(item.NotificationID),
+		"user_id":         events.NewStringAttribute(item.UserID),
+		"message":         events.NewStringAttribute(item.Message),
+		"outbox_status":   events.NewStringAttribute(item.OutboxStatus),
+		"created_at":      events.NewStringAttribute(item.CreatedAt),
+	}
+
+	// Act
+	record, ok, err := OutboxRecordFromStream(image)
+
+	// Assert
+	if err != nil || !ok {
+		t.Fatalf("expected pending outbox record, got ok=%v err=%v", ok, err)
+	}
+	if item.SK != "OUTBOX#01HQ8XA2B3C4D5E6F7G8H9" {
+		t.Errorf("SK: expected OUTBOX#01HQ8XA2B3C4D5E6F7G8H9, got %s", item.SK)
+	}
+	if record.NotificationID != notif.ID || record.Message.Title != "Hola" || !record.CreatedAt.Equal(createdAt) {
+		t.Errorf("unexpected record: %+v", record)
+	}
+}
+
+func TestOutboxRecordFromStream_IgnoresNotifications(t *testing.T) {
+	// Arrange - a notification item goes through the same stream
+	image := map[string]events.DynamoDBAttributeValue{
+		"PK": events.NewStringAttribute("USER#usr_123"),
+		"SK": events.NewStringAttribute("NOTIF#2024-11-03T15:30:00Z#01HQ8XA2B3C4D5E6F7G8H9"),
+	}
+
+	// Act
+	_, ok, err := OutboxRecordFromStream(image)
+
+	// Assert
+	if err != nil || ok {
+		t.Fatalf("expected item to be ignored, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestToItem_ScheduledIsInDueIndex(t *testing.T) {
+	// Arrange
+	scheduledAt := time.Date(2024, 11, 3, 18, 30, 0, 0, time.UTC)
+	notif := &notification.Notification{
+		ID:          "01HQ8XA2B3C4D5E6F7G8H9",
+		UserID:      "usr_123",
+		ChannelName: "email",
+		Meta:        map[string]string{"to": "user@example.com"},
+		CreatedAt:   time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC),
+		UpdatedAt:   time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC),
+		Status:      notification.StatusScheduled,
+		ScheduledAt: &scheduledAt,
+		TimeZone:    "America/Argentina/Buenos_Aires",
+	}
+
+	// Act
+	item := toItem(notif)
+	entity, err := toEntity(item)
+
+	// Assert - Due index keys
+	if item.GSI2PK != "DUE#1" {
+		t.Errorf("GSI2PK: expected DUE#1, got %s", item.GSI2PK)
+	}
+	if item.GSI2SK != "2024-11-03T18:30:00Z#01HQ8XA2B3C4D5E6F7G8H9" {
+		t.Errorf("GSI2SK: expected 2024-11-03T18:30:00Z#01HQ8XA2B3C4D5E6F7G8H9, got %s", item.GSI2SK)
+	}
+
+	// Assert - Round trip
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if entity.ScheduledAt == nil || !entity.ScheduledAt.Equal(scheduledAt) || entity.TimeZone != notif.TimeZone {
+		t.Errorf("unexpected schedule: %v %s", entity.ScheduledAt, entity.TimeZone)
+	}
+	if entity.Meta["to"] != "user@example.com" {
+		t.Errorf("Meta: expected to user@example.com, got %v", entity.Meta)
+	}
+}
+
+func TestToItem_NotScheduledIsNotInDueIndex(t *testing.T) {
+	notif := &notification.Notification{ID: "01HQ8XA2B3C4D5E6F7G8H9", Status: notification.StatusPending}
+
+	item := toItem(notif)
+
+	if item.GSI2PK != "" || item.GSI2SK != "" {
+		t.Errorf("expected no GSI2 keys, got %s %s", item.GSI2PK, item.GSI2SK)
+	}
+}
+
+func TestToIdempotencyItemAndBack(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 2, 15, 30, 0, 0, time.UTC)
+	record := &notification.IdempotencyRecord{
+		Key:         "abc",
+		UserID:      "usr_123",
+		RequestHash: "hash",
+		CreatedAt:   createdAt,
+		LockedUntil: createdAt.Add(time.Minute),
+		Response:    &notification.Notification{ID: "n1", UserID: "usr_123", Status: notification.StatusQueued, CreatedAt: createdAt},
+	}
+
+	// Act
+	item, err := toIdempotencyItem(record)
+	if err != nil {
+		t.Fatalf("toIdempotencyItem: %v", err)
+	}
+	got, err := toIdempotencyRecord(item)
+	if err != nil {
+		t.Fatalf("toIdempotencyRecord: %v", err)
+	}
+
+	// Assert
+	if item.PK != "USER#usr_123" || item.SK != "IDEMPOTENCY#abc" {
+		t.Fatalf("unexpected keys %s %s", item.PK, item.SK)
+	}
+	if item.ExpiresAt != createdAt.Add(24*time.Hour).Unix() {
+		t.Fatalf("expected a 24h TTL, got %d", item.ExpiresAt)
+	}
+	if got.RequestHash != "hash" || got.Response == nil || got.Response.ID != "n1" || got.Response.Status != notification.StatusQueued {
+		t.Fatalf("unexpected record: %+v", got)
+	}
+	if !got.LockedUntil.Equal(createdAt.Add(time.Minute)) {
+		t.Fatalf("expected the lock expiry to round trip, got %v", got.LockedUntil)
+	}
+}
@@ -0,0 +1,209 @@
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/notification"
+)
+
+const (
+	outboxPending    = "pending"
+	outboxDispatched = "dispatched"
+
+	// dispatched records are kept for a week, then removed by the table TTL
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// OutboxItem is a dispatch record stored next to its notification
+type OutboxItem struct {
+	PK             string `dynamodbav:"PK"` // USER#<userID>
+	SK             string `dynamodbav:"SK"` // OUTBOX#<notificationID>
+	NotificationID string `dynamodbav:"notification_id"`
+	UserID         string `dynamodbav:"user_id"`
+	Message        string `dynamodbav:"message"` // JSON encoded DispatchMessage
+	OutboxStatus   string `dynamodbav:"outbox_status"`
+	CreatedAt      string `dynamodbav:"created_at"` // ISO8601 string
+	DispatchedAt   string `dynamodbav:"dispatched_at,omitempty"`
+	ExpiresAt      int64  `dynamodbav:"expires_at,omitempty"` // TTL, epoch seconds
+}
+
+func (r *NotificationRepository) CreateWithDispatch(ctx context.Context, n *notification.Notification, message *notification.DispatchMessage) error {
+	notificationAV, err := attributevalue.MarshalMap(toItem(n))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingNotification, err)
+	}
+	outboxItem, err := toOutboxItem(n, message)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingNotification, err)
+	}
+	outboxAV, err := attributevalue.MarshalMap(outboxItem)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCreatingNotification, err)
+	}
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(r.tableName),
+				Item:                notificationAV,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(r.tableName),
+				Item:                outboxAV,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrStoringNotification, err)
+	}
+	return nil
+}
+
+// ListPending scans the table, it is meant for the local poller only
+// In AWS the relay reads new outbox items from DynamoDB Streams
+func (r *NotificationRepository) ListPending(ctx context.Context, limit int) ([]*notification.OutboxRecord, error) {
+	var records []*notification.OutboxRecord
+	var lastKey map[string]types.AttributeValue
+	for {
+		result, err := r.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(r.tableName),
+			FilterExpression: aws.String("begins_with(SK, :sk) AND outbox_status = :pending"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sk":      &types.AttributeValueMemberS{Value: "OUTBOX#"},
+				":pending": &types.AttributeValueMemberS{Value: outboxPending},
+			},
+			ExclusiveStartKey: lastKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan outbox: %w", err)
+		}
+
+		var items []OutboxItem
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal outbox: %w", err)
+		}
+		for _, item := range items {
+			record, err := toOutboxRecord(item)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, record)
+		}
+
+		if result.LastEvaluatedKey == nil {
+			break
+		}
+		lastKey = result.LastEvaluatedKey
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt.Before(records[j].CreatedAt)
+	})
+	if len(records) > limit {
+		records = records[:limit]
+	}
+	return records, nil
+}
+
+func (r *NotificationRepository) MarkDispatched(ctx context.Context, record *notification.OutboxRecord) error {
+	now := time.Now()
+	_, err := r.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + record.UserID},
+			"SK": &types.AttributeValueMemberS{Value: "OUTBOX#" + record.NotificationID},
+		},
+		UpdateExpression:    aws.String("SET outbox_status = :dispatched, dispatched_at = :at, expires_at = :expires"),
+		ConditionExpression: aws.String("outbox_status = :pending"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":dispatched": &types.AttributeValueMemberS{Value: outboxDispatched},
+			":pending":    &types.AttributeValueMemberS{Value: outboxPending},
+			":at":         &types.AttributeValueMemberS{Value: now.Format(time.RFC3339)},
+			":expires":    &types.AttributeValueMemberN{Value: strconv.FormatInt(now.Add(outboxRetention).Unix(), 10)},
+		},
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return nil
+		}
+		return fmt.Errorf("failed to mark outbox record: %w", err)
+	}
+	return nil
+}
+
+// OutboxRecordFromStream extracts a pending outbox record from a DynamoDB Streams image
+// ok is false when the image is not a pending outbox item
+func OutboxRecordFromStream(image map[string]events.DynamoDBAttributeValue) (record *notification.OutboxRecord, ok bool, err error) {
+	str := func(name string) string {
+		av, found := image[name]
+		if !found || av.DataType() != events.DataTypeString {
+			return ""
+		}
+		return av.String()
+	}
+
+	if !strings.HasPrefix(str("SK"), "OUTBOX#") || str("outbox_status") != outboxPending {
+		return nil, false, nil
+	}
+
+	record, err = toOutboxRecord(OutboxItem{
+		NotificationID: str("notification_id"),
+		UserID:         str("user_id"),
+		Message:        str("message"),
+		OutboxStatus:   str("outbox_status"),
+		CreatedAt:      str("created_at"),
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return record, true, nil
+}
+
+func toOutboxItem(n *notification.Notification, message *notification.DispatchMessage) (OutboxItem, error) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return OutboxItem{}, fmt.Errorf("failed to marshal dispatch message: %w", err)
+	}
+	return OutboxItem{
+		PK:             "USER#" + n.UserID,
+		SK:             "OUTBOX#" + n.ID,
+		NotificationID: n.ID,
+		UserID:         n.UserID,
+		Message:        string(body),
+		OutboxStatus:   outboxPending,
+		CreatedAt:      n.CreatedAt.Format(time.RFC3339),
+	}, nil
+}
+
+func toOutboxRecord(item OutboxItem) (*notification.OutboxRecord, error) {
+	var message notification.DispatchMessage
+	if err := json.Unmarshal([]byte(item.Message), &message); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dispatch message: %w", err)
+	}
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	return &notification.OutboxRecord{
+		NotificationID: item.NotificationID,
+		UserID:         item.UserID,
+		Message:        message,
+		CreatedAt:      createdAt,
+	}, nil
+}
@@ -0,0 +1,104 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/preference"
+)
+
+// PreferencesItem holds every delivery setting of a user in a single item,
+// it is read on every send so it is kept small
+type PreferencesItem struct {
+	PK         string          `dynamodbav:"PK"` // USER#<userID>
+	SK         string          `dynamodbav:"SK"` // PREFERENCES
+	OptOuts    []OptOutItem    `dynamodbav:"opt_outs"`
+	QuietHours *QuietHoursItem `dynamodbav:"quiet_hours,omitempty"`
+	UpdatedAt  string          `dynamodbav:"updated_at"` // ISO8601 string
+}
+
+type QuietHoursItem struct {
+	Start    string   `dynamodbav:"start"` // HH:MM
+	End      string   `dynamodbav:"end"`   // HH:MM
+	TimeZone string   `dynamodbav:"time_zone"`
+	Channels []string `dynamodbav:"channels,omitempty,stringset"`
+}
+
+type OptOutItem struct {
+	Channel  string `dynamodbav:"channel,omitempty"`
+	Category string `dynamodbav:"category,omitempty"`
+}
+
+func (r *UserRepository) GetPreferences(ctx context.Context, userID string) (*preference.Preferences, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + userID},
+			"SK": &types.AttributeValueMemberS{Value: "PREFERENCES"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if result.Item == nil {
+		return &preference.Preferences{OptOuts: []preference.OptOut{}}, nil
+	}
+
+	var item PreferencesItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal preferences: %w", err)
+	}
+	return toPreferences(item)
+}
+
+func (r *UserRepository) PutPreferences(ctx context.Context, userID string, p *preference.Preferences) error {
+	av, err := attributevalue.MarshalMap(toPreferencesItem(userID, p))
+	if err != nil {
+		return fmt.Errorf("failed to marshal preferences: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store preferences: %w", err)
+	}
+	return nil
+}
+
+func toPreferencesItem(userID string, p *preference.Preferences) PreferencesItem {
+	optOuts := make([]OptOutItem, len(p.OptOuts))
+	for i, o := range p.OptOuts {
+		optOuts[i] = OptOutItem{Channel: o.Channel, Category: o.Category}
+	}
+	item := PreferencesItem{
+		PK:        "USER#" + userID,
+		SK:        "PREFERENCES",
+		OptOuts:   optOuts,
+		UpdatedAt: formatOptionalTime(p.UpdatedAt),
+	}
+	if q := p.QuietHours; q != nil {
+		item.QuietHours = &QuietHoursItem{Start: q.Start, End: q.End, TimeZone: q.TimeZone, Channels: q.Channels}
+	}
+	return item
+}
+
+func toPreferences(item PreferencesItem) (*preference.Preferences, error) {
+	updatedAt, err := parseOptionalTime(item.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+	p := &preference.Preferences{OptOuts: make([]preference.OptOut, len(item.OptOuts)), UpdatedAt: updatedAt}
+	for i, o := range item.OptOuts {
+		p.OptOuts[i] = preference.OptOut{Channel: o.Channel, Category: o.Category}
+	}
+	if q := item.QuietHours; q != nil {
+		p.QuietHours = &preference.QuietHours{Start: q.Start, End: q.End, TimeZone: q.TimeZone, Channels: q.Channels}
+	}
+	return p, nil
+}
@@ -0,0 +1,39 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"serverless-notification/domain/preference"
+)
+
+func TestToPreferencesItemAndBack(t *testing.T) {
+	// Arrange
+	updatedAt := time.Date(2024, 11, 2, 15, 30, 0, 0, time.UTC)
+	p := &preference.Preferences{
+		OptOuts:    []preference.OptOut{{Channel: "sms", Category: "marketing"}, {Channel: "push"}},
+		QuietHours: &preference.QuietHours{Start: "22:00", End: "07:00", TimeZone: "America/Argentina/Buenos_Aires", Channels: []string{"sms"}},
+		UpdatedAt:  &updatedAt,
+	}
+
+	// Act
+	item := toPreferencesItem("usr_123", p)
+	got, err := toPreferences(item)
+	if err != nil {
+		t.Fatalf("toPreferences: %v", err)
+	}
+
+	// Assert
+	if item.PK != "USER#usr_123" || item.SK != "PREFERENCES" {
+		t.Fatalf("unexpected keys %s %s", item.PK, item.SK)
+	}
+	if len(got.OptOuts) != 2 || got.OptOuts[0] != p.OptOuts[0] || got.OptOuts[1] != p.OptOuts[1] {
+		t.Fatalf("unexpected opt-outs: %v", got.OptOuts)
+	}
+	if q := got.QuietHours; q == nil || q.Start != "22:00" || q.End != "07:00" || q.TimeZone != p.QuietHours.TimeZone || len(q.Channels) != 1 {
+		t.Fatalf("unexpected quiet hours: %+v", got.QuietHours)
+	}
+	if got.UpdatedAt == nil || !got.UpdatedAt.Equal(updatedAt) {
+		t.Fatalf("unexpected updated_at: %v", got.UpdatedAt)
+	}
+}
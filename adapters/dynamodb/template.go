@@ -0,0 +1,99 @@
+package dynamodb
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/notification"
+)
+
+// TemplateItem is an email template, every template is also in GSI1 under TEMPLATES
+// so the email channel can load them all without a scan
+type TemplateItem struct {
+	PK        string `dynamodbav:"PK"`     // TEMPLATE#<name>
+	SK        string `dynamodbav:"SK"`     // METADATA
+	GSI1PK    string `dynamodbav:"GSI1PK"` // TEMPLATES
+	GSI1SK    string `dynamodbav:"GSI1SK"` // TEMPLATE#<name>
+	Name      string `dynamodbav:"name"`
+	Text      string `dynamodbav:"text"`
+	HTML      string `dynamodbav:"html,omitempty"`
+	UpdatedAt string `dynamodbav:"updated_at"` // ISO8601 string
+}
+
+// PutTemplate stores a template, replacing any template with the same name
+func (r *NotificationRepository) PutTemplate(ctx context.Context, t *notification.Template) error {
+	av, err := attributevalue.MarshalMap(toTemplateItem(t))
+	if err != nil {
+		return fmt.Errorf("failed to marshal template: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store template: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ListTemplates(ctx context.Context) ([]*notification.Template, error) {
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "TEMPLATES"},
+		},
+	})
+	var templates []*notification.Template
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list templates: %w", err)
+		}
+		var items []TemplateItem
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal templates: %w", err)
+		}
+		for _, item := range items {
+			t, err := toTemplate(item)
+			if err != nil {
+				return nil, err
+			}
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+func toTemplateItem(t *notification.Template) TemplateItem {
+	return TemplateItem{
+		PK:        "TEMPLATE#" + t.Name,
+		SK:        "METADATA",
+		GSI1PK:    "TEMPLATES",
+		GSI1SK:    "TEMPLATE#" + t.Name,
+		Name:      t.Name,
+		Text:      t.Text,
+		HTML:      t.HTML,
+		UpdatedAt: t.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func toTemplate(item TemplateItem) (*notification.Template, error) {
+	updatedAt, err := time.Parse(time.RFC3339, item.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse updated_at: %w", err)
+	}
+	return &notification.Template{
+		Name:      item.Name,
+		Text:      item.Text,
+		HTML:      item.HTML,
+		UpdatedAt: updatedAt,
+	}, nil
+}
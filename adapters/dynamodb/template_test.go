@@ -0,0 +1,36 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"serverless-notification/domain/notification"
+)
+
+func TestToTemplateItemAndBack(t *testing.T) {
+	// Arrange
+	original := &notification.Template{
+		Name:      "receipt",
+		Text:      "Thanks for your order, {{.Content}}",
+		HTML:      "<p>Thanks for your order, {{.Content}}</p>",
+		UpdatedAt: time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC),
+	}
+
+	// Act
+	item := toTemplateItem(original)
+	got, err := toTemplate(item)
+
+	// Assert
+	if item.PK != "TEMPLATE#receipt" || item.SK != "METADATA" {
+		t.Errorf("keys: expected TEMPLATE#receipt / METADATA, got %s / %s", item.PK, item.SK)
+	}
+	if item.GSI1PK != "TEMPLATES" || item.GSI1SK != "TEMPLATE#receipt" {
+		t.Errorf("GSI1: expected TEMPLATES / TEMPLATE#receipt, got %s / %s", item.GSI1PK, item.GSI1SK)
+	}
+	if err != nil {
+		t.Fatalf("toTemplate: %v", err)
+	}
+	if *got != *original {
+		t.Errorf("expected %+v, got %+v", original, got)
+	}
+}
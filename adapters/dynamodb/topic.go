@@ -0,0 +1,260 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/topic"
+)
+
+var (
+	ErrTopicNotFound = topic.ErrTopicNotFound
+	ErrTopicExists   = topic.ErrTopicExists
+	ErrNotSubscribed = topic.ErrNotSubscribed
+)
+
+// TopicItem is the metadata of a topic, its subscriptions share the partition
+// Every topic is also in GSI1 under TOPICS so they can be listed without a scan
+type TopicItem struct {
+	PK          string `dynamodbav:"PK"`     // TOPIC#<topicID>
+	SK          string `dynamodbav:"SK"`     // METADATA
+	GSI1PK      string `dynamodbav:"GSI1PK"` // TOPICS
+	GSI1SK      string `dynamodbav:"GSI1SK"` // TOPIC#<topicID>
+	ID          string `dynamodbav:"id"`
+	Name        string `dynamodbav:"name"`
+	Description string `dynamodbav:"description,omitempty"`
+	OwnerID     string `dynamodbav:"owner_id"`
+	CreatedAt   string `dynamodbav:"created_at"` // ISO8601 string
+}
+
+type SubscriptionItem struct {
+	PK        string `dynamodbav:"PK"` // TOPIC#<topicID>
+	SK        string `dynamodbav:"SK"` // SUB#<userID>
+	TopicID   string `dynamodbav:"topic_id"`
+	UserID    string `dynamodbav:"user_id"`
+	CreatedAt string `dynamodbav:"created_at"` // ISO8601 string
+}
+
+func (r *NotificationRepository) CreateTopic(ctx context.Context, t *topic.Topic) error {
+	av, err := attributevalue.MarshalMap(toTopicItem(t))
+	if err != nil {
+		return fmt.Errorf("failed to marshal topic: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(r.tableName),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(PK)"),
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return fmt.Errorf("%w: %s", ErrTopicExists, t.ID)
+		}
+		return fmt.Errorf("failed to store topic: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) GetTopic(ctx context.Context, id string) (*topic.Topic, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TOPIC#" + id},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topic: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrTopicNotFound
+	}
+
+	var item TopicItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal topic: %w", err)
+	}
+	return toTopic(item)
+}
+
+func (r *NotificationRepository) ListTopics(ctx context.Context) ([]*topic.Topic, error) {
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :pk"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk": &types.AttributeValueMemberS{Value: "TOPICS"},
+		},
+	})
+	topics := []*topic.Topic{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list topics: %w", err)
+		}
+		var items []TopicItem
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal topics: %w", err)
+		}
+		for _, item := range items {
+			t, err := toTopic(item)
+			if err != nil {
+				return nil, err
+			}
+			topics = append(topics, t)
+		}
+	}
+	return topics, nil
+}
+
+// DeleteTopic deletes the subscriptions of the topic and then the topic, 25 items per request
+func (r *NotificationRepository) DeleteTopic(ctx context.Context, id string) error {
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sub)"),
+		ProjectionExpression:   aws.String("PK, SK"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":  &types.AttributeValueMemberS{Value: "TOPIC#" + id},
+			":sub": &types.AttributeValueMemberS{Value: "SUB#"},
+		},
+	})
+	var requests []types.WriteRequest
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list subscriptions: %w", err)
+		}
+		for _, key := range page.Items {
+			requests = append(requests, types.WriteRequest{DeleteRequest: &types.DeleteRequest{Key: key}})
+		}
+	}
+	for start := 0; start < len(requests); start += maxBatchWriteItems {
+		end := min(start+maxBatchWriteItems, len(requests))
+		if err := r.batchWrite(ctx, requests[start:end]); err != nil {
+			return fmt.Errorf("failed to delete subscriptions: %w", err)
+		}
+	}
+
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TOPIC#" + id},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrTopicNotFound
+		}
+		return fmt.Errorf("failed to delete topic: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) PutSubscription(ctx context.Context, s *topic.Subscription) error {
+	av, err := attributevalue.MarshalMap(toSubscriptionItem(s))
+	if err != nil {
+		return fmt.Errorf("failed to marshal subscription: %w", err)
+	}
+	_, err = r.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(r.tableName),
+		Item:      av,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) DeleteSubscription(ctx context.Context, topicID, userID string) error {
+	_, err := r.client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "TOPIC#" + topicID},
+			"SK": &types.AttributeValueMemberS{Value: "SUB#" + userID},
+		},
+		ConditionExpression: aws.String("attribute_exists(PK)"),
+	})
+	if err != nil {
+		var conditionErr *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionErr) {
+			return ErrNotSubscribed
+		}
+		return fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *NotificationRepository) ListSubscribers(ctx context.Context, topicID string) ([]string, error) {
+	paginator := dynamodb.NewQueryPaginator(r.client, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		KeyConditionExpression: aws.String("PK = :pk AND begins_with(SK, :sub)"),
+		ProjectionExpression:   aws.String("user_id"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":pk":  &types.AttributeValueMemberS{Value: "TOPIC#" + topicID},
+			":sub": &types.AttributeValueMemberS{Value: "SUB#"},
+		},
+	})
+	var userIDs []string
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list subscribers: %w", err)
+		}
+		var items []SubscriptionItem
+		if err := attributevalue.UnmarshalListOfMaps(page.Items, &items); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal subscriptions: %w", err)
+		}
+		for _, item := range items {
+			userIDs = append(userIDs, item.UserID)
+		}
+	}
+	return userIDs, nil
+}
+
+func toTopicItem(t *topic.Topic) TopicItem {
+	return TopicItem{
+		PK:          "TOPIC#" + t.ID,
+		SK:          "METADATA",
+		GSI1PK:      "TOPICS",
+		GSI1SK:      "TOPIC#" + t.ID,
+		ID:          t.ID,
+		Name:        t.Name,
+		Description: t.Description,
+		OwnerID:     t.OwnerID,
+		CreatedAt:   t.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toTopic(item TopicItem) (*topic.Topic, error) {
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	return &topic.Topic{
+		ID:          item.ID,
+		Name:        item.Name,
+		Description: item.Description,
+		OwnerID:     item.OwnerID,
+		CreatedAt:   createdAt,
+	}, nil
+}
+
+func toSubscriptionItem(s *topic.Subscription) SubscriptionItem {
+	return SubscriptionItem{
+		PK:        "TOPIC#" + s.TopicID,
+		SK:        "SUB#" + s.UserID,
+		TopicID:   s.TopicID,
+		UserID:    s.UserID,
+		CreatedAt: s.CreatedAt.Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,53 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"serverless-notification/domain/topic"
+)
+
+func TestToTopicItemAndBack(t *testing.T) {
+	// Arrange
+	original := &topic.Topic{
+		ID:          "order-updates",
+		Name:        "Order updates",
+		Description: "Shipping and delivery of orders",
+		OwnerID:     "usr_ops",
+		CreatedAt:   time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC),
+	}
+
+	// Act
+	item := toTopicItem(original)
+	got, err := toTopic(item)
+
+	// Assert
+	if item.PK != "TOPIC#order-updates" || item.SK != "METADATA" {
+		t.Errorf("keys: expected TOPIC#order-updates / METADATA, got %s / %s", item.PK, item.SK)
+	}
+	if item.GSI1PK != "TOPICS" || item.GSI1SK != "TOPIC#order-updates" {
+		t.Errorf("GSI1: expected TOPICS / TOPIC#order-updates, got %s / %s", item.GSI1PK, item.GSI1SK)
+	}
+	if err != nil {
+		t.Fatalf("toTopic: %v", err)
+	}
+	if *got != *original {
+		t.Errorf("expected %+v, got %+v", original, got)
+	}
+}
+
+func TestToSubscriptionItem(t *testing.T) {
+	// Arrange
+	subscription := &topic.Subscription{TopicID: "status-page", UserID: "usr_1", CreatedAt: time.Now()}
+
+	// Act
+	item := toSubscriptionItem(subscription)
+
+	// Assert
+	if item.PK != "TOPIC#status-page" || item.SK != "SUB#usr_1" {
+		t.Errorf("keys: expected TOPIC#status-page / SUB#usr_1, got %s / %s", item.PK, item.SK)
+	}
+	if item.UserID != "usr_1" || item.TopicID != "status-page" {
+		t.Errorf("unexpected item %+v", item)
+	}
+}
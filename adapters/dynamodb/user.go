@@ -0,0 +1,163 @@
+package dynamodb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"serverless-notification/domain/user"
+)
+
+var ErrUserNotFound = user.ErrUserNotFound
+
+type UserRepository struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+type UserItem struct {
+	PK           string `dynamodbav:"PK"`     // USER#<userID>
+	SK           string `dynamodbav:"SK"`     // METADATA
+	GSI1PK       string `dynamodbav:"GSI1PK"` // EMAIL#<email>
+	GSI1SK       string `dynamodbav:"GSI1SK"` // USER#<userID>
+	ID           string `dynamodbav:"id"`
+	Email        string `dynamodbav:"email"`
+	PasswordHash string `dynamodbav:"password_hash"`
+	Role         string `dynamodbav:"role,omitempty"`
+	CreatedAt    string `dynamodbav:"created_at"` // ISO8601 string
+}
+
+// EmailItem reserves an email so two users cannot sign up with it,
+// a GSI cannot enforce uniqueness so it is written in the same transaction as the user
+type EmailItem struct {
+	PK     string `dynamodbav:"PK"` // EMAIL#<email>
+	SK     string `dynamodbav:"SK"` // EMAIL
+	UserID string `dynamodbav:"user_id"`
+}
+
+// Constructor
+func NewUserRepository(client *dynamodb.Client, tableName string) *UserRepository {
+	return &UserRepository{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+func (r *UserRepository) Create(ctx context.Context, u *user.User) error {
+	userAV, err := attributevalue.MarshalMap(toUserItem(u))
+	if err != nil {
+		return fmt.Errorf("failed to marshal user: %w", err)
+	}
+	emailAV, err := attributevalue.MarshalMap(EmailItem{PK: "EMAIL#" + u.Email, SK: "EMAIL", UserID: u.ID})
+	if err != nil {
+		return fmt.Errorf("failed to marshal email: %w", err)
+	}
+
+	_, err = r.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: []types.TransactWriteItem{
+			{Put: &types.Put{
+				TableName:           aws.String(r.tableName),
+				Item:                userAV,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+			{Put: &types.Put{
+				TableName:           aws.String(r.tableName),
+				Item:                emailAV,
+				ConditionExpression: aws.String("attribute_not_exists(PK)"),
+			}},
+		},
+	})
+	if err != nil {
+		var canceled *types.TransactionCanceledException
+		if errors.As(err, &canceled) && emailConditionFailed(canceled) {
+			return user.ErrEmailTaken
+		}
+		return fmt.Errorf("failed to store user: %w", err)
+	}
+	return nil
+}
+
+// emailConditionFailed reports whether the transaction was cancelled by the email reservation
+func emailConditionFailed(canceled *types.TransactionCanceledException) bool {
+	reasons := canceled.CancellationReasons
+	return len(reasons) > 1 && aws.ToString(reasons[1].Code) == "ConditionalCheckFailed"
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*user.User, error) {
+	result, err := r.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(r.tableName),
+		Key: map[string]types.AttributeValue{
+			"PK": &types.AttributeValueMemberS{Value: "USER#" + id},
+			"SK": &types.AttributeValueMemberS{Value: "METADATA"},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	if result.Item == nil {
+		return nil, ErrUserNotFound
+	}
+
+	var item UserItem
+	if err := attributevalue.UnmarshalMap(result.Item, &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	return toUser(item)
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	result, err := r.client.Query(ctx, &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String("GSI1"),
+		KeyConditionExpression: aws.String("GSI1PK = :email"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":email": &types.AttributeValueMemberS{Value: "EMAIL#" + email},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+	if len(result.Items) == 0 {
+		return nil, ErrUserNotFound
+	}
+
+	var item UserItem
+	if err := attributevalue.UnmarshalMap(result.Items[0], &item); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user: %w", err)
+	}
+	return toUser(item)
+}
+
+func toUserItem(u *user.User) UserItem {
+	return UserItem{
+		PK:           "USER#" + u.ID,
+		SK:           "METADATA",
+		GSI1PK:       "EMAIL#" + u.Email,
+		GSI1SK:       "USER#" + u.ID,
+		ID:           u.ID,
+		Email:        u.Email,
+		PasswordHash: u.PasswordHash,
+		Role:         u.Role,
+		CreatedAt:    u.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func toUser(item UserItem) (*user.User, error) {
+	createdAt, err := time.Parse(time.RFC3339, item.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse created_at: %w", err)
+	}
+	return &user.User{
+		ID:           item.ID,
+		Email:        item.Email,
+		PasswordHash: item.PasswordHash,
+		Role:         item.Role,
+		CreatedAt:    createdAt,
+	}, nil
+}
@@ -0,0 +1,68 @@
+package dynamodb
+
+import (
+	"testing"
+	"time"
+
+	"serverless-notification/domain/apikey"
+	"serverless-notification/domain/user"
+)
+
+func TestToUserItemAndBack(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 2, 15, 30, 0, 0, time.UTC)
+	u := &user.User{ID: "usr_123", Email: "user@example.com", PasswordHash: "$2a$10$hash", CreatedAt: createdAt}
+
+	// Act
+	item := toUserItem(u)
+	got, err := toUser(item)
+	if err != nil {
+		t.Fatalf("toUser: %v", err)
+	}
+
+	// Assert
+	if item.PK != "USER#usr_123" || item.SK != "METADATA" {
+		t.Fatalf("unexpected keys %s %s", item.PK, item.SK)
+	}
+	if item.GSI1PK != "EMAIL#user@example.com" || item.GSI1SK != "USER#usr_123" {
+		t.Fatalf("unexpected GSI1 keys %s %s", item.GSI1PK, item.GSI1SK)
+	}
+	if got.ID != u.ID || got.Email != u.Email || got.PasswordHash != u.PasswordHash || !got.CreatedAt.Equal(createdAt) {
+		t.Fatalf("unexpected user: %+v", got)
+	}
+}
+
+func TestToAPIKeyItemAndBack(t *testing.T) {
+	// Arrange
+	createdAt := time.Date(2024, 11, 2, 15, 30, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+	k := &apikey.Key{
+		ID:              "k1",
+		UserID:          "usr_123",
+		Name:            "billing",
+		Prefix:          "nk_abcdef",
+		Hash:            "deadbeef",
+		Scopes:          []string{"notifications:write"},
+		AllowedChannels: []string{"email"},
+		CreatedAt:       createdAt,
+		ExpiresAt:       &expiresAt,
+	}
+
+	// Act
+	item := toAPIKeyItem(k)
+	got, err := toAPIKey(item)
+	if err != nil {
+		t.Fatalf("toAPIKey: %v", err)
+	}
+
+	// Assert
+	if item.PK != "USER#usr_123" || item.SK != "APIKEY#k1" || item.GSI1PK != "APIKEY#deadbeef" {
+		t.Fatalf("unexpected keys %s %s %s", item.PK, item.SK, item.GSI1PK)
+	}
+	if got.ExpiresAt == nil || !got.ExpiresAt.Equal(expiresAt) || got.LastUsedAt != nil || got.RevokedAt != nil {
+		t.Fatalf("unexpected timestamps: %+v", got)
+	}
+	if len(got.Scopes) != 1 || got.Scopes[0] != "notifications:write" || got.AllowedChannels[0] != "email" {
+		t.Fatalf("unexpected scopes or channels: %+v", got)
+	}
+}
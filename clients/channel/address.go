@@ -0,0 +1,165 @@
+package channels
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"serverless-notification/domain/notification"
+	"strings"
+)
+
+const (
+	// maxRecipients bounds to, cc and bcc together, providers reject bigger messages
+	maxRecipients = 50
+	maxReplyTo    = 5
+)
+
+// recipientFields are the meta fields with recipients, in the order they are validated
+var recipientFields = []string{"to", "cc", "bcc"}
+
+// envelope is who an email is from and to
+type envelope struct {
+	From    *mail.Address
+	To      []*mail.Address
+	Cc      []*mail.Address
+	Bcc     []*mail.Address // only in the SMTP envelope, never in the headers
+	ReplyTo []*mail.Address
+}
+
+// Recipients returns the address of everyone the email is delivered to, without duplicates
+func (e *envelope) Recipients() []string {
+	seen := map[string]bool{}
+	var recipients []string
+	for _, list := range [][]*mail.Address{e.To, e.Cc, e.Bcc} {
+		for _, a := range list {
+			if key := strings.ToLower(a.Address); !seen[key] {
+				seen[key] = true
+				recipients = append(recipients, a.Address)
+			}
+		}
+	}
+	return recipients
+}
+
+// parseAddresses parses a comma-separated list, "a@example.com, Bea <b@example.com>",
+// or a JSON array of addresses, `["a@example.com", "Bea <b@example.com>"]`
+func parseAddresses(value string) ([]*mail.Address, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, "[") {
+		return mail.ParseAddressList(value)
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(value), &list); err != nil {
+		return nil, fmt.Errorf("invalid address list: %w", err)
+	}
+	addresses := make([]*mail.Address, 0, len(list))
+	for _, s := range list {
+		a, err := mail.ParseAddress(s)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, a)
+	}
+	return addresses, nil
+}
+
+// AllowSenderDomains lets meta["from"] override the sender with an address of
+// one of domains, they must be verified with the transport (SPF, DKIM)
+func (c *EmailChannel) AllowSenderDomains(domains ...string) {
+	if c.senderDomains == nil {
+		c.senderDomains = map[string]bool{}
+	}
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			c.senderDomains[d] = true
+		}
+	}
+}
+
+func (c *EmailChannel) allowedSender(a *mail.Address) bool {
+	at := strings.LastIndex(a.Address, "@")
+	return at >= 0 && c.senderDomains[strings.ToLower(a.Address[at+1:])]
+}
+
+// validateAddresses adds a field error for every address field that cannot be sent
+func (c *EmailChannel) validateAddresses(meta map[string]string, errs *notification.ValidationError) {
+	recipients := 0
+	for _, field := range recipientFields {
+		addresses, err := parseAddresses(meta[field])
+		switch {
+		case err != nil:
+			errs.Add(field, "invalid email address")
+		case field == "to" && len(addresses) == 0:
+			errs.Add("to", "to field with valid email is required")
+		}
+		recipients += len(addresses)
+	}
+	if recipients > maxRecipients {
+		errs.Add("to", fmt.Sprintf("at most %d recipients are allowed across to, cc and bcc", maxRecipients))
+	}
+
+	if replyTo, err := parseAddresses(meta["reply_to"]); err != nil {
+		errs.Add("reply_to", "invalid email address")
+	} else if len(replyTo) > maxReplyTo {
+		errs.Add("reply_to", fmt.Sprintf("at most %d reply_to addresses are allowed", maxReplyTo))
+	}
+
+	if from := meta["from"]; from != "" {
+		if a, err := mail.ParseAddress(from); err != nil {
+			errs.Add("from", "invalid email address")
+		} else if !c.allowedSender(a) {
+			errs.Add("from", "from must be an address of an allowed sender domain")
+		}
+	}
+}
+
+// parseEnvelope reads the addresses of meta, the sender defaults to the one of the channel
+func (c *EmailChannel) parseEnvelope(meta map[string]string) (*envelope, error) {
+	if err := c.Validate(meta); err != nil {
+		return nil, err
+	}
+	e := &envelope{}
+	from := c.from
+	if meta["from"] != "" {
+		from = meta["from"]
+	}
+	var err error
+	if e.From, err = mail.ParseAddress(from); err != nil {
+		return nil, fmt.Errorf("invalid sender: %w", err)
+	}
+	// Validate already parsed these
+	e.To, _ = parseAddresses(meta["to"])
+	e.Cc, _ = parseAddresses(meta["cc"])
+	e.Bcc, _ = parseAddresses(meta["bcc"])
+	e.ReplyTo, _ = parseAddresses(meta["reply_to"])
+	if len(e.To) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	return e, nil
+}
+
+// redactAddresses masks the local part of every address of value, names are dropped
+// "john@example.com" becomes "j***@example.com"
+func redactAddresses(value string) string {
+	addresses, err := parseAddresses(value)
+	if err != nil || len(addresses) == 0 {
+		return redactAddress(value)
+	}
+	redacted := make([]string, len(addresses))
+	for i, a := range addresses {
+		redacted[i] = redactAddress(a.Address)
+	}
+	return strings.Join(redacted, ", ")
+}
+
+func redactAddress(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return maskMiddle(address, 0, 0)
+	}
+	return maskMiddle(address[:at], 1, 0) + address[at:]
+}
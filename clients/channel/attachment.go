@@ -0,0 +1,324 @@
+package channels
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"serverless-notification/domain/notification"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	maxAttachments = 10
+	// maxAttachmentSize bounds every file and maxAttachmentsSize all of them,
+	// most mail servers reject messages over 25 MiB once base64 encoded
+	maxAttachmentSize  = 10 << 20
+	maxAttachmentsSize = 18 << 20
+	// maxInlineContentSize bounds base64 content, the meta travels in the SQS message (256 KiB)
+	// and maxInlineContentsSize the encoded content of all of them, leaving room for the rest
+	maxInlineContentSize  = 128 << 10
+	maxInlineContentsSize = 192 << 10
+	fetchTimeout          = 30 * time.Second
+)
+
+var contentIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._@-]{0,127}$`)
+
+// Attachment is an entry of meta["attachments"], a JSON array
+// Exactly one of URL, Key and Content is the source of the file
+type Attachment struct {
+	Filename    string `json:"filename,omitempty" example:"invoice.pdf"` // defaults to the last element of the URL or key
+	URL         string `json:"url,omitempty" example:"https://files.example.com/invoice.pdf"`
+	Key         string `json:"key,omitempty" example:"usr_123/invoices/0042.pdf"` // object key in the attachment store, under <user_id>/
+	Content     string `json:"content,omitempty"`                                 // base64
+	ContentType string `json:"content_type,omitempty" example:"application/pdf"`  // sniffed when empty
+	// ContentID makes the file an inline image, the HTML template references it as cid:<content_id>
+	ContentID string `json:"content_id,omitempty" example:"logo"`
+}
+
+// AttachmentStore reads the attachments referenced by key, see clients.S3Client
+type AttachmentStore interface {
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// mailAttachment is an attachment with its content loaded
+type mailAttachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Data        []byte
+}
+
+// parseAttachments decodes meta["attachments"], nil when there are none
+func parseAttachments(meta map[string]string) ([]Attachment, error) {
+	raw := meta["attachments"]
+	if raw == "" {
+		return nil, nil
+	}
+	var attachments []Attachment
+	if err := json.Unmarshal([]byte(raw), &attachments); err != nil {
+		return nil, fmt.Errorf("invalid attachments json: %w", err)
+	}
+	for i, a := range attachments {
+		if a.Filename != "" {
+			continue
+		}
+		source := a.Key
+		if u, err := url.Parse(a.URL); err == nil && a.URL != "" {
+			source = u.Path
+		}
+		if name := path.Base(source); name != "." && name != "/" {
+			attachments[i].Filename = name
+		}
+	}
+	return attachments, nil
+}
+
+// redactAttachments masks the URLs of raw and drops the inline content, a signed URL
+// or the file itself must not come back in reads. JSON it cannot parse is dropped
+func redactAttachments(raw string) (string, bool) {
+	var attachments []Attachment
+	if err := json.Unmarshal([]byte(raw), &attachments); err != nil {
+		return "", false
+	}
+	for i, a := range attachments {
+		if a.URL != "" {
+			attachments[i].URL = redactURL(a.URL)
+		}
+		attachments[i].Content = ""
+	}
+	redacted, err := json.Marshal(attachments)
+	if err != nil {
+		return "", false
+	}
+	return string(redacted), true
+}
+
+// validateAttachments adds a field error for every attachment that cannot be sent
+func (c *EmailChannel) validateAttachments(meta map[string]string, errs *notification.ValidationError) {
+	attachments, err := parseAttachments(meta)
+	if err != nil {
+		errs.Add("attachments", err.Error())
+		return
+	}
+	if len(attachments) > maxAttachments {
+		errs.Add("attachments", fmt.Sprintf("at most %d attachments are allowed", maxAttachments))
+		return
+	}
+	contentIDs := map[string]bool{}
+	inlineSize := 0
+	for i, a := range attachments {
+		field := fmt.Sprintf("attachments[%d]", i)
+		sources := 0
+		for _, s := range []string{a.URL, a.Key, a.Content} {
+			if s != "" {
+				sources++
+			}
+		}
+		if sources != 1 {
+			errs.Add(field, "exactly one of url, key and content is required")
+			continue
+		}
+		if a.Filename == "" {
+			errs.Add(field+".filename", "filename is required")
+		} else if strings.ContainsAny(a.Filename, "\r\n") {
+			errs.Add(field+".filename", "invalid filename")
+		}
+		switch {
+		case a.URL != "":
+			if u, err := url.Parse(a.URL); err != nil || u.Scheme != "https" || u.Host == "" {
+				errs.Add(field+".url", "url must be an https URL")
+			}
+		case a.Key != "":
+			if c.attachments == nil {
+				errs.Add(field+".key", "attachments by key are not enabled")
+			}
+		default:
+			inlineSize += len(a.Content)
+			tooBig := fmt.Sprintf("content must be at most %d KiB, use a url or key", maxInlineContentSize>>10)
+			if len(a.Content) > base64.StdEncoding.EncodedLen(maxInlineContentSize) {
+				errs.Add(field+".content", tooBig)
+			} else if data, err := base64.StdEncoding.DecodeString(a.Content); err != nil {
+				errs.Add(field+".content", "content must be base64")
+			} else if len(data) > maxInlineContentSize {
+				errs.Add(field+".content", tooBig)
+			}
+		}
+		if a.ContentType != "" {
+			if _, _, err := mime.ParseMediaType(a.ContentType); err != nil {
+				errs.Add(field+".content_type", "invalid content type")
+			}
+		}
+		if a.ContentID != "" {
+			if !contentIDPattern.MatchString(a.ContentID) {
+				errs.Add(field+".content_id", "content_id may only contain letters, digits and ._@-")
+			} else if contentIDs[a.ContentID] {
+				errs.Add(field+".content_id", "duplicated content_id")
+			}
+			contentIDs[a.ContentID] = true
+		}
+	}
+	if inlineSize > maxInlineContentsSize {
+		errs.Add("attachments", fmt.Sprintf("content must be at most %d KiB in total once encoded, use a url or key", maxInlineContentsSize>>10))
+	}
+}
+
+// ValidateOwner rejects keys outside the prefix of userID, <user_id>/, so a notification
+// can only attach the files of its owner (see notification.Notification.Owner) from the shared store
+func (c *EmailChannel) ValidateOwner(userID string, meta map[string]string) error {
+	attachments, err := parseAttachments(meta)
+	if err != nil {
+		// Validate reports it
+		return nil
+	}
+	errs := &notification.ValidationError{}
+	for i, a := range attachments {
+		if a.Key != "" && !ownsKey(userID, a.Key) {
+			errs.Add(fmt.Sprintf("attachments[%d].key", i), "key must start with <user_id>/ of the user sending it")
+		}
+	}
+	return errs.ErrOrNil()
+}
+
+// ownsKey reports whether key is under the prefix of userID
+func ownsKey(userID, key string) bool {
+	return userID != "" && strings.HasPrefix(key, userID+"/")
+}
+
+// loadAttachments fetches every attachment of meta for a notification owned by userID,
+// failing if any is missing, too big or not owned by userID
+func (c *EmailChannel) loadAttachments(ctx context.Context, userID string, meta map[string]string) ([]mailAttachment, error) {
+	attachments, err := parseAttachments(meta)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make([]mailAttachment, 0, len(attachments))
+	total := 0
+	for _, a := range attachments {
+		var data []byte
+		switch {
+		case a.URL != "":
+			data, err = c.fetch(ctx, a.URL)
+		case a.Key != "":
+			data, err = c.open(ctx, userID, a.Key)
+		default:
+			data, err = base64.StdEncoding.DecodeString(a.Content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load attachment %s: %w", a.Filename, err)
+		}
+		total += len(data)
+		if total > maxAttachmentsSize {
+			return nil, fmt.Errorf("attachments exceed %d MiB", maxAttachmentsSize>>20)
+		}
+		loaded = append(loaded, mailAttachment{
+			Filename:    a.Filename,
+			ContentType: contentType(a, data),
+			ContentID:   a.ContentID,
+			Data:        data,
+		})
+	}
+	return loaded, nil
+}
+
+func (c *EmailChannel) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	client := c.httpClient
+	if client == nil {
+		client = publicHTTPClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return readLimited(resp.Body)
+}
+
+func (c *EmailChannel) open(ctx context.Context, userID, key string) ([]byte, error) {
+	if c.attachments == nil {
+		return nil, errors.New("attachments by key are not enabled")
+	}
+	if !ownsKey(userID, key) {
+		return nil, fmt.Errorf("key %s is not owned by %s", key, userID)
+	}
+	body, err := c.attachments.Open(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return readLimited(body)
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxAttachmentSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxAttachmentSize {
+		return nil, fmt.Errorf("larger than %d MiB", maxAttachmentSize>>20)
+	}
+	return data, nil
+}
+
+// contentType is the declared type, or else the sniffed one, or else the one of the extension
+func contentType(a Attachment, data []byte) string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	sniffed := http.DetectContentType(data)
+	if sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain") {
+		if byExtension := mime.TypeByExtension(path.Ext(a.Filename)); byExtension != "" {
+			return byExtension
+		}
+	}
+	return sniffed
+}
+
+// publicHTTPClient fetches attachment URLs, refusing to connect to private
+// addresses so a notification cannot read from inside the network
+var publicHTTPClient = &http.Client{
+	Timeout: fetchTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 10 * time.Second,
+			Control: func(network, address string, conn syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+					return fmt.Errorf("refusing to connect to %s", host)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "https" {
+			return errors.New("redirect to a non-https URL")
+		}
+		if len(via) >= 5 {
+			return errors.New("too many redirects")
+		}
+		return nil
+	},
+}
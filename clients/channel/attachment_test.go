@@ -0,0 +1,286 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"serverless-notification/domain/notification"
+	"strings"
+	"testing"
+)
+
+var (
+	pngData = append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 32)...)
+	pdfData = []byte("%PDF-1.4\n%fake invoice\n")
+)
+
+// fakeAttachmentStore serves objects by key
+type fakeAttachmentStore map[string][]byte
+
+func (s fakeAttachmentStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, errors.New("object not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func attachmentsMeta(t *testing.T, attachments ...Attachment) map[string]string {
+	t.Helper()
+	raw, err := json.Marshal(attachments)
+	if err != nil {
+		t.Fatalf("marshal attachments: %v", err)
+	}
+	return map[string]string{"to": "user@example.com", "attachments": string(raw)}
+}
+
+func TestEmailValidate_Attachments(t *testing.T) {
+	c := &EmailChannel{}
+	tests := []struct {
+		name       string
+		attachment Attachment
+		field      string
+	}{
+		{"no source", Attachment{Filename: "a.pdf"}, "attachments[0]"},
+		{"two sources", Attachment{URL: "https://example.com/a.pdf", Content: "YQ=="}, "attachments[0]"},
+		{"plain http", Attachment{URL: "http://example.com/a.pdf"}, "attachments[0].url"},
+		{"key without store", Attachment{Key: "invoices/a.pdf"}, "attachments[0].key"},
+		{"content without filename", Attachment{Content: "YQ=="}, "attachments[0].filename"},
+		{"not base64", Attachment{Filename: "a.txt", Content: "not base64!"}, "attachments[0].content"},
+		{"content too big", Attachment{Filename: "a.bin", Content: base64.StdEncoding.EncodeToString(make([]byte, maxInlineContentSize+1))}, "attachments[0].content"},
+		{"bad content id", Attachment{URL: "https://example.com/logo.png", ContentID: "<logo>"}, "attachments[0].content_id"},
+	}
+
+	for _, tt := range tests {
+		err := c.Validate(attachmentsMeta(t, tt.attachment))
+
+		var validationErr *notification.ValidationError
+		if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != tt.field {
+			t.Fatalf("%s: expected validation error on %s, got %v", tt.name, tt.field, err)
+		}
+	}
+}
+
+func TestEmailValidate_AttachmentsOK(t *testing.T) {
+	c := &EmailChannel{}
+	c.EnableAttachmentStore(fakeAttachmentStore{})
+	meta := attachmentsMeta(t,
+		Attachment{URL: "https://files.example.com/reports/may.pdf"},
+		Attachment{Key: "usr_123/invoices/0042.pdf"},
+		Attachment{Filename: "logo.png", Content: base64.StdEncoding.EncodeToString(pngData), ContentID: "logo"},
+	)
+
+	if err := c.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestEmailValidate_InlineContentTotal(t *testing.T) {
+	c := &EmailChannel{}
+	full := base64.StdEncoding.EncodeToString(make([]byte, maxInlineContentSize))
+	meta := attachmentsMeta(t, Attachment{Filename: "a.bin", Content: full}, Attachment{Filename: "b.bin", Content: full})
+
+	err := c.Validate(meta)
+
+	var validationErr *notification.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "attachments" {
+		t.Fatalf("expected validation error on attachments, got %v", err)
+	}
+	if raw, _ := json.Marshal(notification.DispatchMessage{Meta: attachmentsMeta(t, Attachment{Filename: "a.bin", Content: full})}); len(raw) > 256<<10 {
+		t.Fatalf("expected the largest accepted content to fit in an SQS message, got %d bytes", len(raw))
+	}
+}
+
+func TestEmailValidateOwner_ForeignKey(t *testing.T) {
+	c := &EmailChannel{}
+	c.EnableAttachmentStore(fakeAttachmentStore{})
+	meta := attachmentsMeta(t,
+		Attachment{Key: "usr_123/invoices/0042.pdf"},
+		Attachment{Key: "usr_999/invoices/0042.pdf"},
+		Attachment{Key: "usr_1234/invoices/0042.pdf"},
+	)
+
+	err := c.ValidateOwner("usr_123", meta)
+
+	var validationErr *notification.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 2 ||
+		validationErr.Fields[0].Field != "attachments[1].key" || validationErr.Fields[1].Field != "attachments[2].key" {
+		t.Fatalf("expected the keys of other users to be rejected, got %v", err)
+	}
+}
+
+func TestEmailValidate_TooManyAttachments(t *testing.T) {
+	c := &EmailChannel{}
+	var attachments []Attachment
+	for i := 0; i <= maxAttachments; i++ {
+		attachments = append(attachments, Attachment{URL: "https://example.com/a.pdf"})
+	}
+
+	if err := c.Validate(attachmentsMeta(t, attachments...)); err == nil {
+		t.Fatal("expected error for too many attachments")
+	}
+}
+
+func TestEmailSend_MixedWithInlineImage(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pdfData)
+	}))
+	defer server.Close()
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+	c.EnableAttachmentStore(fakeAttachmentStore{"usr_123/exports/report.csv": []byte("id,total\n1,10\n")})
+	c.httpClient = server.Client()
+	meta := attachmentsMeta(t,
+		Attachment{URL: server.URL + "/invoices/0042"},
+		Attachment{Key: "usr_123/exports/report.csv"},
+		Attachment{Filename: "logo.png", Content: base64.StdEncoding.EncodeToString(pngData), ContentID: "logo"},
+	)
+	meta["template"] = "titled"
+
+	if _, err := c.Send(context.Background(), notification.Message{UserID: "usr_123", Title: "Factura", Content: "Adjunta", Meta: meta}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	parsed := readMessage(t, transport.msg)
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if mediaType != "multipart/mixed" {
+		t.Fatalf("expected multipart/mixed, got %q", mediaType)
+	}
+	parts := readRawParts(t, parsed.Body, params["boundary"])
+	if len(parts) != 3 {
+		t.Fatalf("expected the body and 2 attachments, got %d parts", len(parts))
+	}
+
+	related, relatedParams, _ := mime.ParseMediaType(parts[0].Header.Get("Content-Type"))
+	if related != "multipart/related" {
+		t.Fatalf("expected multipart/related body, got %q", related)
+	}
+	relatedParts := readRawParts(t, bytes.NewReader(parts[0].body), relatedParams["boundary"])
+	if len(relatedParts) != 2 || !strings.HasPrefix(relatedParts[0].Header.Get("Content-Type"), "multipart/alternative") {
+		t.Fatalf("expected the alternatives and the inline image, got %d parts", len(relatedParts))
+	}
+	logo := relatedParts[1]
+	if logo.Header.Get("Content-ID") != "<logo>" || logo.Header.Get("Content-Type") != "image/png; name=logo.png" || !strings.HasPrefix(logo.Header.Get("Content-Disposition"), "inline") {
+		t.Fatalf("unexpected inline image headers: %v", logo.Header)
+	}
+	if !bytes.Equal(logo.decoded(t), pngData) {
+		t.Fatal("expected the inline image content")
+	}
+	if !strings.Contains(transport.msg, "cid:logo") {
+		t.Fatal("expected the html to reference the inline image")
+	}
+
+	invoice := parts[1]
+	if invoice.Header.Get("Content-Type") != "application/pdf; name=0042" || invoice.Header.Get("Content-Disposition") != "attachment; filename=0042" {
+		t.Fatalf("expected the sniffed pdf attachment, got %v", invoice.Header)
+	}
+	if !bytes.Equal(invoice.decoded(t), pdfData) {
+		t.Fatal("expected the fetched content")
+	}
+	report := parts[2]
+	if report.Header.Get("Content-Type") != "text/csv; charset=utf-8; name=report.csv" {
+		t.Fatalf("expected the type of the extension for plain text, got %v", report.Header)
+	}
+}
+
+func TestEmailSend_InlineImageWithoutHTMLIsAttached(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+	meta := attachmentsMeta(t, Attachment{Filename: "logo.png", Content: base64.StdEncoding.EncodeToString(pngData), ContentID: "logo"})
+
+	if _, err := c.Send(context.Background(), notification.Message{Title: "Hola", Content: "Mundo", Meta: meta}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	parsed := readMessage(t, transport.msg)
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	parts := readRawParts(t, parsed.Body, params["boundary"])
+	if mediaType != "multipart/mixed" || len(parts) != 2 || !strings.HasPrefix(parts[1].Header.Get("Content-Disposition"), "attachment") {
+		t.Fatalf("expected the image as an attachment, got %s with %d parts", mediaType, len(parts))
+	}
+}
+
+func TestEmailSend_AttachmentTooBig(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+	c.EnableAttachmentStore(fakeAttachmentStore{"usr_123/big.bin": make([]byte, maxAttachmentSize+1)})
+
+	_, err := c.Send(context.Background(), notification.Message{UserID: "usr_123", Title: "Hola", Meta: attachmentsMeta(t, Attachment{Key: "usr_123/big.bin"})})
+	if err == nil || !strings.Contains(err.Error(), "big.bin") {
+		t.Fatalf("expected the attachment to be rejected, got %v", err)
+	}
+	if transport.msg != "" {
+		t.Fatal("expected nothing to be sent")
+	}
+}
+
+func TestEmailSend_ForeignKeyIsNotOpened(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+	c.EnableAttachmentStore(fakeAttachmentStore{"usr_999/secret.pdf": pdfData})
+
+	_, err := c.Send(context.Background(), notification.Message{UserID: "usr_123", Title: "Hola", Meta: attachmentsMeta(t, Attachment{Key: "usr_999/secret.pdf"})})
+	if err == nil || !strings.Contains(err.Error(), "not owned") {
+		t.Fatalf("expected the key of another user to be refused, got %v", err)
+	}
+	if transport.msg != "" {
+		t.Fatal("expected nothing to be sent")
+	}
+
+	// Sent by usr_999 to usr_123, a broadcast, the file is the sender's
+	if _, err := c.Send(context.Background(), notification.Message{UserID: "usr_123", SenderID: "usr_999", Title: "Hola", Meta: attachmentsMeta(t, Attachment{Key: "usr_999/secret.pdf"})}); err != nil {
+		t.Fatalf("expected the key of the sender to be opened, got %v", err)
+	}
+}
+
+func TestPublicHTTPClient_RefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(pdfData)
+	}))
+	defer server.Close()
+	c := &EmailChannel{}
+
+	if _, err := c.fetch(context.Background(), server.URL); err == nil || !strings.Contains(err.Error(), "refusing") {
+		t.Fatalf("expected loopback to be refused, got %v", err)
+	}
+}
+
+type rawPart struct {
+	Header textproto.MIMEHeader
+	body   []byte
+}
+
+// readRawParts reads the parts of a multipart body without decoding them
+func readRawParts(t *testing.T, body io.Reader, boundary string) []rawPart {
+	t.Helper()
+	var parts []rawPart
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("NextRawPart: %v", err)
+		}
+		content, _ := io.ReadAll(part)
+		parts = append(parts, rawPart{Header: part.Header, body: content})
+	}
+}
+
+func (p rawPart) decoded(t *testing.T) []byte {
+	t.Helper()
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(p.body), "\r\n", ""))
+	if err != nil {
+		t.Fatalf("decode base64: %v", err)
+	}
+	return data
+}
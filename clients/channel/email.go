@@ -1,47 +1,73 @@
 package channels
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"html/template"
-	"net/mail"
-	"notification/models/channel"
-	"os"
+	"net/http"
+	"serverless-notification/domain/notification"
 	"sync"
+	"time"
 )
 
 // ValidEmailMeta represents the required metadata for email notifications
+// to, cc, bcc and reply_to take one address, a comma-separated list or a JSON array
 type ValidEmailMeta struct {
-	To       string `json:"to" example:"user@example.com"`
-	Subject  string `json:"subject,omitempty" example:"Welcome to our platform"`
+	To       string `json:"to" example:"user@example.com, Bea <bea@example.com>"`
+	Cc       string `json:"cc,omitempty" example:"[\"billing@example.com\"]"`
+	Bcc      string `json:"bcc,omitempty" example:"audit@example.com"`
+	ReplyTo  string `json:"reply_to,omitempty" example:"support@example.com"`
+	From     string `json:"from,omitempty" example:"Billing <billing@example.com>"` // only from an allowed sender domain
+	Subject  string `json:"subject,omitempty" example:"Welcome to our platform"`    // defaults to the title
 	Template string `json:"template,omitempty" example:"titled"`
+	// Attachments is a JSON array of Attachment
+	Attachments string `json:"attachments,omitempty" example:"[{\"url\":\"https://files.example.com/invoice.pdf\"}]"`
 }
 
+// ErrNoTransport is returned for emails that must not be printed when no transport is configured
+var ErrNoTransport = errors.New("email transport is not configured")
+
+// EmailTransport delivers a complete message to the recipients, see clients/smtp
+type EmailTransport interface {
+	Send(ctx context.Context, from string, to []string, msg []byte) error
+	Close() error
+}
+
+// EmailChannel renders the email templates and hands the message to its transport
+// The zero value has no transport and prints the emails to stdout, except verification codes
 type EmailChannel struct {
-	templates map[string]*template.Template
-	once      sync.Once
+	templates     *TemplateRegistry
+	once          sync.Once
+	from          string
+	transport     EmailTransport
+	attachments   AttachmentStore
+	httpClient    *http.Client    // fetches attachment URLs, publicHTTPClient when nil
+	senderDomains map[string]bool // domains meta["from"] may use
 }
 
-func (c *EmailChannel) initTemplates() {
-	c.once.Do(func() {
-		c.templates = make(map[string]*template.Template)
-		c.templates["titled"] = template.Must(template.ParseFiles("email/titled.html.tmpl"))
-		c.templates["plain"] = template.Must(template.ParseFiles("email/plain.txt.tmpl"))
-	})
+// NewEmailChannel creates an email channel sending from the address from through transport
+func NewEmailChannel(from string, transport EmailTransport) *EmailChannel {
+	return &EmailChannel{from: from, transport: transport}
 }
 
-func (c *EmailChannel) getTemplate(templateName string) *template.Template {
-	c.initTemplates()
-	name := templateName
-	if name == "" {
-		name = "plain"
-	}
-	tmpl, ok := c.templates[name]
-	if !ok {
-		tmpl = c.templates["plain"]
-	}
-	return tmpl
+// EnableAttachmentStore lets attachments reference files by key in store
+func (c *EmailChannel) EnableAttachmentStore(store AttachmentStore) {
+	c.attachments = store
+}
+
+// UseTemplates replaces the built-in templates with the ones of registry
+func (c *EmailChannel) UseTemplates(registry *TemplateRegistry) {
+	c.templates = registry
+}
+
+// registry returns the templates of the channel, the built-in ones unless UseTemplates was called
+func (c *EmailChannel) registry() *TemplateRegistry {
+	c.once.Do(func() {
+		if c.templates == nil {
+			c.templates = NewTemplateRegistry()
+		}
+	})
+	return c.templates
 }
 
 func (c *EmailChannel) Name() string {
@@ -49,36 +75,132 @@ func (c *EmailChannel) Name() string {
 }
 
 func (c *EmailChannel) Validate(meta map[string]string) error {
-	to, ok := meta["to"]
-	if !ok || to == "" {
-		return fmt.Errorf("to field with valid email is required")
+	errs := &notification.ValidationError{}
+	c.validateAddresses(meta, errs)
+	if _, err := c.registry().get(meta["template"]); err != nil {
+		errs.Add("template", err.Error())
 	}
-	if _, err := mail.ParseAddress(to); err != nil {
-		return fmt.Errorf("invalid email address")
+	c.validateAttachments(meta, errs)
+	return errs.ErrOrNil()
+}
+
+// RedactMeta masks the local part of the recipients, "john@example.com" becomes "j***@example.com",
+// and the attachment URLs, inline attachment content is left out
+func (c *EmailChannel) RedactMeta(meta map[string]string) map[string]string {
+	redacted := copyMeta(meta)
+	for _, field := range append(recipientFields, "reply_to") {
+		if value, ok := redacted[field]; ok {
+			redacted[field] = redactAddresses(value)
+		}
 	}
-	return nil
+	if value, ok := redacted["attachments"]; ok {
+		if attachments, ok := redactAttachments(value); ok {
+			redacted["attachments"] = attachments
+		} else {
+			delete(redacted, "attachments")
+		}
+	}
+	return redacted
 }
 
-func (c *EmailChannel) Send(ctx context.Context, msg channel.Message) error {
-	tmpl := c.getTemplate(msg.Meta["template"])
-	var body bytes.Buffer
-	if err := tmpl.Execute(&body, msg); err != nil {
-		return err
+// Send renders the template named by meta["template"], the subject defaults to the title
+// Attachments are only loaded when there is a transport
+func (c *EmailChannel) Send(ctx context.Context, msg notification.Message) (notification.Receipt, error) {
+	receipt := notification.Receipt{Provider: "stdout"}
+	var addresses *envelope
+	var attachments []mailAttachment
+	if c.transport != nil {
+		var err error
+		if addresses, err = c.parseEnvelope(msg.Meta); err != nil {
+			return receipt, err
+		}
+		if attachments, err = c.loadAttachments(ctx, msg.Owner(), msg.Meta); err != nil {
+			return receipt, err
+		}
+	}
+	data := templateData{Message: msg}
+	for _, a := range attachments {
+		if a.ContentID != "" {
+			data.Inline = append(data.Inline, a.ContentID)
+		}
+	}
+	tmpl, err := c.registry().get(msg.Meta["template"])
+	if err != nil {
+		return receipt, err
+	}
+	text, html, err := tmpl.render(data)
+	if err != nil {
+		return receipt, err
 	}
 
-	from := os.Getenv("EMAIL_FROM")
-	to := msg.Meta["to"]
 	subject := msg.Meta["subject"]
+	if subject == "" {
+		subject = msg.Title
+	}
+	if c.transport == nil {
+		body := text
+		if html != "" {
+			body = html
+		}
+		return receipt, c.sender(ctx, c.from, msg.Meta["to"], subject, body)
+	}
+
+	receipt.Provider = "smtp"
+	message := &emailMessage{
+		Subject:     subject,
+		Text:        text,
+		HTML:        html,
+		Attachments: attachments,
+	}
+	err = c.deliver(ctx, addresses, message)
+	receipt.ProviderMessageID = message.MessageID
+	return receipt, err
+}
 
-	return c.sender(ctx, from, to, subject, body.String())
+// SendVerificationCode emails a contact verification code to address
+// It fails without a transport, printing the code would leak it to the logs
+func (c *EmailChannel) SendVerificationCode(ctx context.Context, address, code string) error {
+	if c.transport == nil {
+		return ErrNoTransport
+	}
+	subject := "Verify your email address"
+	_, err := c.Send(ctx, notification.Message{
+		Title:   subject,
+		Content: fmt.Sprintf("Your verification code is %s, it expires in 15 minutes.", code),
+		Meta:    map[string]string{"to": address, "subject": subject},
+	})
+	return err
 }
 
-func (c *EmailChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+func (c *EmailChannel) Prepare(ctx context.Context, msg *notification.Message) error {
 	return nil
 }
 
+// Close closes the connection of the transport, the next Send opens a new one
+func (c *EmailChannel) Close() error {
+	if c.transport == nil {
+		return nil
+	}
+	return c.transport.Close()
+}
+
 // separated from Send for testing purposes
 func (c *EmailChannel) sender(ctx context.Context, from, to, subject, body string) error {
-	fmt.Println(from, to, subject, body) // Implement actual email sending with external library or with net/smtp
+	fmt.Println(from, to, subject, body)
 	return nil
 }
+
+// deliver addresses the message and hands it to the transport, it sets the Message-ID
+func (c *EmailChannel) deliver(ctx context.Context, addresses *envelope, message *emailMessage) error {
+	message.From = addresses.From
+	message.To = addresses.To
+	message.Cc = addresses.Cc
+	message.ReplyTo = addresses.ReplyTo
+	message.Date = time.Now()
+	message.MessageID = newMessageID(addresses.From)
+	raw, err := message.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	return c.transport.Send(ctx, addresses.From.Address, addresses.Recipients(), raw)
+}
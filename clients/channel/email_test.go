@@ -2,9 +2,15 @@ package channels
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
-	"notification/models/channel"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
 	"os"
+	"serverless-notification/domain/notification"
 	"strings"
 	"testing"
 )
@@ -25,17 +31,17 @@ func TestEmailValidate_Invalid(t *testing.T) {
 
 func TestEmailSend_TitledTemplate(t *testing.T) {
 	c := &EmailChannel{}
-	msg := channel.Message{Title: "Hola", Content: "Mundo", Meta: map[string]string{"template": "titled", "to": "user@example.com", "subject": "s"}}
+	msg := notification.Message{Title: "Hola", Content: "Mundo", Meta: map[string]string{"template": "titled", "to": "user@example.com", "subject": "s"}}
 	// capture output by calling Send; we assert no error and basic markers in body via template execution
-	if err := c.Send(context.Background(), msg); err != nil {
+	if _, err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send: %v", err)
 	}
 }
 
 func TestEmailSend_DefaultTemplate(t *testing.T) {
 	c := &EmailChannel{}
-	msg := channel.Message{Title: "Hola", Content: "Texto plano", Meta: map[string]string{"to": "user@example.com"}}
-	if err := c.Send(context.Background(), msg); err != nil {
+	msg := notification.Message{Title: "Hola", Content: "Texto plano", Meta: map[string]string{"to": "user@example.com"}}
+	if _, err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send: %v", err)
 	}
 	// quick sanity check rendering uses content
@@ -70,3 +76,309 @@ func TestEmailSender_OK(t *testing.T) {
 		t.Fatalf("unexpected sender output: %q", got)
 	}
 }
+
+func TestEmailValidate_MissingToNamesField(t *testing.T) {
+	c := &EmailChannel{}
+	err := c.Validate(map[string]string{})
+
+	var validationErr *notification.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "to" {
+		t.Fatalf("expected validation error on field 'to', got %v", err)
+	}
+}
+
+func TestEmailRedactMeta_MasksLocalPart(t *testing.T) {
+	c := &EmailChannel{}
+	tests := []struct {
+		to   string
+		want string
+	}{
+		{"john@example.com", "j***@example.com"},
+		{"a@example.com", "*@example.com"},
+		{"not-an-address", "**************"},
+	}
+
+	for _, tt := range tests {
+		redacted := c.RedactMeta(map[string]string{"to": tt.to, "subject": "Hola"})
+		if redacted["to"] != tt.want {
+			t.Fatalf("expected %q, got %q", tt.want, redacted["to"])
+		}
+		if redacted["subject"] != "Hola" {
+			t.Fatalf("expected subject unchanged, got %q", redacted["subject"])
+		}
+	}
+}
+
+func TestEmailSendVerificationCode_IncludesCode(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+
+	if err := c.SendVerificationCode(context.Background(), "user@example.com", "123456"); err != nil {
+		t.Fatalf("SendVerificationCode: %v", err)
+	}
+	if len(transport.to) != 1 || transport.to[0] != "user@example.com" || !strings.Contains(transport.msg, "123456") {
+		t.Fatalf("expected the code sent to user@example.com, got %v %q", transport.to, transport.msg)
+	}
+}
+
+func TestEmailSendVerificationCode_NoTransportFails(t *testing.T) {
+	c := &EmailChannel{}
+
+	if err := c.SendVerificationCode(context.Background(), "user@example.com", "123456"); !errors.Is(err, ErrNoTransport) {
+		t.Fatalf("expected ErrNoTransport, got %v", err)
+	}
+}
+
+type fakeTransport struct {
+	from   string
+	to     []string
+	msg    string
+	closes int
+}
+
+func (t *fakeTransport) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	t.from, t.to, t.msg = from, to, string(msg)
+	return nil
+}
+
+func (t *fakeTransport) Close() error {
+	t.closes++
+	return nil
+}
+
+func TestEmailSend_MultipartAlternative(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("Notifications <noreply@example.com>", transport)
+	msg := notification.Message{Title: "Hola", Content: "Mundo <3", Meta: map[string]string{"template": "titled", "to": "user@example.com", "subject": "Hola\r\nBcc: evil@example.com"}}
+
+	receipt, err := c.Send(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if receipt.Provider != "smtp" || receipt.ProviderMessageID == "" {
+		t.Fatalf("expected smtp receipt with the Message-ID, got %+v", receipt)
+	}
+	if transport.from != "noreply@example.com" || len(transport.to) != 1 || transport.to[0] != "user@example.com" {
+		t.Fatalf("unexpected envelope: %q %v", transport.from, transport.to)
+	}
+
+	parsed := readMessage(t, transport.msg)
+	if got := parsed.Header.Get("Subject"); got != "Hola Bcc: evil@example.com" {
+		t.Fatalf("expected the subject on a single header, got %q", got)
+	}
+	if got := parsed.Header.Get("Message-ID"); got != "<"+receipt.ProviderMessageID+">" || !strings.HasSuffix(got, "@example.com>") {
+		t.Fatalf("expected Message-ID on the sender domain, got %q", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("expected multipart/alternative, got %q", parsed.Header.Get("Content-Type"))
+	}
+	parts := readParts(t, parsed.Body, params["boundary"])
+	if len(parts) != 2 {
+		t.Fatalf("expected text and html parts, got %d", len(parts))
+	}
+	if parts[0].contentType != "text/plain; charset=UTF-8" || !strings.Contains(parts[0].body, "Hola") || !strings.Contains(parts[0].body, "Mundo <3") {
+		t.Fatalf("unexpected text part: %+v", parts[0])
+	}
+	if parts[1].contentType != "text/html; charset=UTF-8" || !strings.Contains(parts[1].body, "<p>Mundo &lt;3</p>") {
+		t.Fatalf("unexpected html part: %+v", parts[1])
+	}
+
+	if err := c.Close(); err != nil || transport.closes != 1 {
+		t.Fatalf("expected Close to close the transport, got %v and %d closes", err, transport.closes)
+	}
+}
+
+func TestEmailSend_PlainSubjectFallsBackToTitle(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+	long := strings.Repeat("ñandú ", 20)
+	msg := notification.Message{Title: "Añadimos " + long, Content: "Línea " + strings.Repeat("larga ", 30), Meta: map[string]string{"to": "user@example.com"}}
+
+	if _, err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	headers, body, _ := strings.Cut(transport.msg, "\r\n\r\n")
+	if !strings.Contains(headers, "?=\r\n =?") {
+		t.Fatalf("expected the long subject to be folded, got %q", headers)
+	}
+	for _, line := range strings.Split(body, "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("expected quoted-printable lines of at most 76 characters, got %q", line)
+		}
+	}
+	parsed := readMessage(t, transport.msg)
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != strings.TrimSpace("Añadimos "+long) {
+		t.Fatalf("expected the title as RFC 2047 subject, got %q (%v)", subject, err)
+	}
+	if parsed.Header.Get("Content-Type") != "text/plain; charset=UTF-8" || parsed.Header.Get("Content-Transfer-Encoding") != "quoted-printable" {
+		t.Fatalf("expected a quoted-printable text/plain message, got %v", parsed.Header)
+	}
+	decoded, _ := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	if string(decoded) != msg.Content {
+		t.Fatalf("expected the content as body, got %q", decoded)
+	}
+}
+
+func readMessage(t *testing.T, raw string) *mail.Message {
+	t.Helper()
+	parsed, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	return parsed
+}
+
+type mimePart struct {
+	contentType string
+	body        string
+}
+
+// readParts decodes the parts of a multipart body, quoted-printable is decoded by the reader
+func readParts(t *testing.T, body io.Reader, boundary string) []mimePart {
+	t.Helper()
+	var parts []mimePart
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("NextPart: %v", err)
+		}
+		content, _ := io.ReadAll(part)
+		parts = append(parts, mimePart{contentType: part.Header.Get("Content-Type"), body: string(content)})
+	}
+}
+
+func TestEmailValidate_RecipientLists(t *testing.T) {
+	c := &EmailChannel{}
+	meta := map[string]string{
+		"to":       "a@example.com, \"Bea, B.\" <b@example.com>",
+		"cc":       `["c@example.com", "Dan <d@example.com>"]`,
+		"bcc":      "audit@example.com",
+		"reply_to": "support@example.com",
+	}
+
+	if err := c.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestEmailValidate_RecipientErrors(t *testing.T) {
+	var tooMany []string
+	for i := 0; i < maxRecipients; i++ {
+		tooMany = append(tooMany, fmt.Sprintf("user%d@example.com", i))
+	}
+	c := &EmailChannel{}
+	c.AllowSenderDomains("example.com")
+	tests := []struct {
+		name  string
+		meta  map[string]string
+		field string
+	}{
+		{"empty list", map[string]string{"to": "[]"}, "to"},
+		{"invalid cc", map[string]string{"to": "a@example.com", "cc": "a@example.com, nope"}, "cc"},
+		{"invalid json", map[string]string{"to": "a@example.com", "bcc": `["a@example.com"`}, "bcc"},
+		{"too many", map[string]string{"to": strings.Join(tooMany, ","), "bcc": "audit@example.com"}, "to"},
+		{"invalid reply_to", map[string]string{"to": "a@example.com", "reply_to": "support"}, "reply_to"},
+		{"unverified from", map[string]string{"to": "a@example.com", "from": "ceo@example.org"}, "from"},
+	}
+
+	for _, tt := range tests {
+		err := c.Validate(tt.meta)
+
+		var validationErr *notification.ValidationError
+		if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != tt.field {
+			t.Fatalf("%s: expected validation error on %s, got %v", tt.name, tt.field, err)
+		}
+	}
+}
+
+func TestEmailValidate_FromOverrideDisabled(t *testing.T) {
+	c := &EmailChannel{}
+
+	if err := c.Validate(map[string]string{"to": "a@example.com", "from": "billing@example.com"}); err == nil {
+		t.Fatal("expected from to be rejected without allowed sender domains")
+	}
+}
+
+func TestEmailSend_CcBccReplyToAndFrom(t *testing.T) {
+	transport := &fakeTransport{}
+	c := NewEmailChannel("noreply@example.com", transport)
+	c.AllowSenderDomains("Billing.Example.com")
+	meta := map[string]string{
+		"to":       "a@example.com, Bea <b@example.com>",
+		"cc":       `["c@example.com", "A@example.com"]`,
+		"bcc":      "audit@example.com",
+		"reply_to": "support@example.com",
+		"from":     "Billing <invoices@billing.example.com>",
+	}
+
+	if _, err := c.Send(context.Background(), notification.Message{Title: "Factura", Content: "Adjunta", Meta: meta}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if transport.from != "invoices@billing.example.com" {
+		t.Fatalf("expected the overridden envelope sender, got %q", transport.from)
+	}
+	want := []string{"a@example.com", "b@example.com", "c@example.com", "audit@example.com"}
+	if strings.Join(transport.to, " ") != strings.Join(want, " ") {
+		t.Fatalf("expected every recipient once, got %v", transport.to)
+	}
+	parsed := readMessage(t, transport.msg)
+	if got := parsed.Header.Get("To"); got != "<a@example.com>, \"Bea\" <b@example.com>" {
+		t.Fatalf("unexpected To: %q", got)
+	}
+	if got := parsed.Header.Get("Cc"); got != "<c@example.com>, <A@example.com>" {
+		t.Fatalf("unexpected Cc: %q", got)
+	}
+	if got := parsed.Header.Get("Reply-To"); got != "<support@example.com>" {
+		t.Fatalf("unexpected Reply-To: %q", got)
+	}
+	if got := parsed.Header.Get("From"); got != "\"Billing\" <invoices@billing.example.com>" {
+		t.Fatalf("unexpected From: %q", got)
+	}
+	if strings.Contains(transport.msg, "audit@example.com") {
+		t.Fatal("expected bcc recipients to stay out of the message")
+	}
+}
+
+func TestEmailRedactMeta_MasksRecipientLists(t *testing.T) {
+	c := &EmailChannel{}
+
+	redacted := c.RedactMeta(map[string]string{
+		"to":  "john@example.com, Ana <ana@example.com>",
+		"cc":  `["bob@example.com"]`,
+		"bcc": "audit@example.com",
+	})
+
+	if redacted["to"] != "j***@example.com, a**@example.com" || redacted["cc"] != "b**@example.com" || redacted["bcc"] != "a****@example.com" {
+		t.Fatalf("expected every recipient to be masked, got %v", redacted)
+	}
+}
+
+func TestEmailRedactMeta_MasksAttachments(t *testing.T) {
+	c := &EmailChannel{}
+	meta := map[string]string{
+		"to":          "john@example.com",
+		"attachments": `[{"url":"https://files.example.com/invoice.pdf?sig=secret"},{"filename":"logo.png","content":"aGVsbG8=","content_id":"logo"},{"key":"usr_123/a.pdf"}]`,
+	}
+
+	redacted := c.RedactMeta(meta)
+
+	want := `[{"url":"https://files.example.com/***"},{"filename":"logo.png","content_id":"logo"},{"key":"usr_123/a.pdf"}]`
+	if redacted["attachments"] != want {
+		t.Fatalf("expected %s, got %s", want, redacted["attachments"])
+	}
+	if !strings.Contains(meta["attachments"], "sig=secret") {
+		t.Fatal("expected stored meta to stay unredacted")
+	}
+
+	if redacted := c.RedactMeta(map[string]string{"attachments": "not json"}); redacted["attachments"] != "" {
+		t.Fatalf("expected unparseable attachments to be dropped, got %q", redacted["attachments"])
+	}
+}
@@ -0,0 +1,207 @@
+package channels
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// emailMessage holds what is needed to write an RFC 5322 message
+type emailMessage struct {
+	From        *mail.Address
+	To          []*mail.Address
+	Cc          []*mail.Address
+	ReplyTo     []*mail.Address
+	Subject     string
+	Date        time.Time
+	MessageID   string // without the angle brackets
+	Text        string
+	HTML        string // optional, sent as an alternative to Text
+	Attachments []mailAttachment
+}
+
+// newMessageID returns a unique Message-ID on the domain of the sender
+func newMessageID(from *mail.Address) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	domain := "localhost"
+	if at := strings.LastIndex(from.Address, "@"); at >= 0 {
+		domain = from.Address[at+1:]
+	}
+	return hex.EncodeToString(b) + "@" + domain
+}
+
+// Bytes writes the message, its body nests as
+//
+//	multipart/mixed          when there are attachments
+//	  multipart/related      when the HTML has inline images
+//	    multipart/alternative when there is HTML
+//	      text/plain
+//	      text/html
+//	    inline images
+//	  attachments
+//
+// Text parts are quoted-printable so long lines and non-ASCII text survive any relay
+func (m *emailMessage) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	writeHeader(&buf, "From", m.From.String())
+	writeHeader(&buf, "To", joinAddresses(m.To))
+	if len(m.Cc) > 0 {
+		writeHeader(&buf, "Cc", joinAddresses(m.Cc))
+	}
+	if len(m.ReplyTo) > 0 {
+		writeHeader(&buf, "Reply-To", joinAddresses(m.ReplyTo))
+	}
+	writeHeader(&buf, "Subject", encodeHeader(m.Subject))
+	writeHeader(&buf, "Date", m.Date.Format(time.RFC1123Z))
+	writeHeader(&buf, "Message-ID", "<"+m.MessageID+">")
+	writeHeader(&buf, "MIME-Version", "1.0")
+
+	body := m.body()
+	for _, name := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		writeHeader(&buf, name, body.header[name][0])
+	}
+	buf.WriteString("\r\n")
+	if err := body.write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *emailMessage) body() mimeEntity {
+	body := textEntity("text/plain", m.Text)
+	var inline, attached []mimeEntity
+	for _, a := range m.Attachments {
+		// Without HTML nothing can reference an inline image, it is attached instead
+		if a.ContentID != "" && m.HTML != "" {
+			inline = append(inline, attachmentEntity(a, "inline"))
+		} else {
+			attached = append(attached, attachmentEntity(a, "attachment"))
+		}
+	}
+	if m.HTML != "" {
+		// The last alternative is the preferred one
+		body = multipartEntity("alternative", body, textEntity("text/html", m.HTML))
+	}
+	if len(inline) > 0 {
+		body = multipartEntity("related", append([]mimeEntity{body}, inline...)...)
+	}
+	if len(attached) > 0 {
+		body = multipartEntity("mixed", append([]mimeEntity{body}, attached...)...)
+	}
+	return body
+}
+
+// mimeEntity is a part of the message, its header is written by its parent
+type mimeEntity struct {
+	header textproto.MIMEHeader
+	write  func(w io.Writer) error
+}
+
+func textEntity(mediaType, body string) mimeEntity {
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mediaType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		write: func(w io.Writer) error {
+			qp := quotedprintable.NewWriter(w)
+			if _, err := qp.Write([]byte(body)); err != nil {
+				return err
+			}
+			return qp.Close()
+		},
+	}
+}
+
+func multipartEntity(subtype string, parts ...mimeEntity) mimeEntity {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType("multipart/"+subtype, map[string]string{"boundary": boundary})},
+			"Content-Transfer-Encoding": {"7bit"},
+		},
+		write: func(w io.Writer) error {
+			mw := multipart.NewWriter(w)
+			if err := mw.SetBoundary(boundary); err != nil {
+				return err
+			}
+			for _, part := range parts {
+				pw, err := mw.CreatePart(part.header)
+				if err != nil {
+					return err
+				}
+				if err := part.write(pw); err != nil {
+					return err
+				}
+			}
+			return mw.Close()
+		},
+	}
+}
+
+// attachmentEntity writes a as base64, disposition is attachment or inline
+func attachmentEntity(a mailAttachment, disposition string) mimeEntity {
+	header := textproto.MIMEHeader{
+		"Content-Type":              {attachmentContentType(a)},
+		"Content-Disposition":       {mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if a.ContentID != "" {
+		header["Content-ID"] = []string{"<" + a.ContentID + ">"}
+	}
+	return mimeEntity{
+		header: header,
+		write: func(w io.Writer) error {
+			encoded := base64.StdEncoding.EncodeToString(a.Data)
+			for len(encoded) > 0 {
+				n := min(76, len(encoded))
+				if _, err := io.WriteString(w, encoded[:n]+"\r\n"); err != nil {
+					return err
+				}
+				encoded = encoded[n:]
+			}
+			return nil
+		},
+	}
+}
+
+// attachmentContentType names the file in the content type, an invalid one becomes generic
+func attachmentContentType(a mailAttachment) string {
+	mediaType, params, err := mime.ParseMediaType(a.ContentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = a.Filename
+	return mime.FormatMediaType(mediaType, params)
+}
+
+func writeHeader(buf *bytes.Buffer, name, value string) {
+	fmt.Fprintf(buf, "%s: %s\r\n", name, value)
+}
+
+// encodeHeader encodes non-ASCII text as RFC 2047 words, folding between them
+// Line breaks are dropped, they would start a new header
+func encodeHeader(value string) string {
+	value = strings.Join(strings.Fields(value), " ")
+	return strings.ReplaceAll(mime.QEncoding.Encode("UTF-8", value), "?= =?", "?=\r\n =?")
+}
+
+// joinAddresses folds a line per address so long lists stay under the line length limit
+func joinAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, len(addresses))
+	for i, a := range addresses {
+		formatted[i] = a.String()
+	}
+	return strings.Join(formatted, ",\r\n ")
+}
@@ -4,7 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"notification/models/channel"
+	"serverless-notification/domain/notification"
 )
 
 type PushChannel struct {
@@ -29,11 +29,12 @@ func (c *PushChannel) Name() string {
 	return "push"
 }
 
-func (c *PushChannel) Send(ctx context.Context, msg channel.Message) error {
+func (c *PushChannel) Send(ctx context.Context, msg notification.Message) (notification.Receipt, error) {
+	receipt := notification.Receipt{Provider: "stdout"}
 	data := map[string]string{}
 	if s := msg.Meta["data"]; s != "" {
 		if err := json.Unmarshal([]byte(s), &data); err != nil {
-			return fmt.Errorf("invalid data json: %w", err)
+			return receipt, fmt.Errorf("invalid data json: %w", err)
 		}
 	}
 	payload := pushPayload{
@@ -45,17 +46,31 @@ func (c *PushChannel) Send(ctx context.Context, msg channel.Message) error {
 
 	b, _ := json.Marshal(payload)
 	fmt.Println(string(b)) // Replace with actual push notification sending logic
-	return nil
+	return receipt, nil
+}
+
+// RedactMeta hides the device token but its last 4 characters
+func (c *PushChannel) RedactMeta(meta map[string]string) map[string]string {
+	redacted := copyMeta(meta)
+	if token, ok := redacted["token"]; ok {
+		last := ""
+		if len(token) > 4 {
+			last = token[len(token)-4:]
+		}
+		redacted["token"] = "****" + last
+	}
+	return redacted
 }
 
 func (c *PushChannel) Validate(meta map[string]string) error {
+	errs := &notification.ValidationError{}
 	token := meta["token"]
 	if token == "" || len(token) < 10 || len(token) > 4096 {
-		return fmt.Errorf("invalid token")
+		errs.Add("token", "invalid token")
 	}
-	return nil
+	return errs.ErrOrNil()
 }
 
-func (c *PushChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+func (c *PushChannel) Prepare(ctx context.Context, msg *notification.Message) error {
 	return nil
 }
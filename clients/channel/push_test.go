@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"notification/models/channel"
 	"os"
+	"serverless-notification/domain/notification"
 	"strings"
 	"testing"
 )
@@ -54,7 +54,7 @@ func TestPushValidate_TokenTooLong(t *testing.T) {
 
 func TestPushSend_OK(t *testing.T) {
 	c := &PushChannel{}
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Test Notification",
 		Content: "This is a test push",
 		Meta: map[string]string{
@@ -70,7 +70,7 @@ func TestPushSend_OK(t *testing.T) {
 	os.Stdout = w
 	defer func() { os.Stdout = old }()
 
-	if err := c.Send(context.Background(), msg); err != nil {
+	if _, err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
 
@@ -92,7 +92,7 @@ func TestPushSend_OK(t *testing.T) {
 func TestPushSend_WithData(t *testing.T) {
 	c := &PushChannel{}
 	dataJSON := `{"message_id":"123","type":"alert"}`
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Alert",
 		Content: "New alert received",
 		Meta: map[string]string{
@@ -109,7 +109,7 @@ func TestPushSend_WithData(t *testing.T) {
 	os.Stdout = w
 	defer func() { os.Stdout = old }()
 
-	if err := c.Send(context.Background(), msg); err != nil {
+	if _, err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
 
@@ -141,7 +141,7 @@ func TestPushSend_WithData(t *testing.T) {
 
 func TestPushSend_InvalidDataJSON(t *testing.T) {
 	c := &PushChannel{}
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Test",
 		Content: "Test content",
 		Meta: map[string]string{
@@ -150,14 +150,14 @@ func TestPushSend_InvalidDataJSON(t *testing.T) {
 		},
 	}
 
-	if err := c.Send(context.Background(), msg); err == nil {
+	if _, err := c.Send(context.Background(), msg); err == nil {
 		t.Fatal("expected error for invalid JSON in data")
 	}
 }
 
 func TestPushSend_EmptyData(t *testing.T) {
 	c := &PushChannel{}
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Test",
 		Content: "Test content",
 		Meta: map[string]string{
@@ -174,7 +174,7 @@ func TestPushSend_EmptyData(t *testing.T) {
 	os.Stdout = w
 	defer func() { os.Stdout = old }()
 
-	if err := c.Send(context.Background(), msg); err != nil {
+	if _, err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
 
@@ -198,3 +198,16 @@ func TestPushName(t *testing.T) {
 		t.Fatalf("expected name 'push', got %q", c.Name())
 	}
 }
+
+func TestPushRedactMeta_KeepsLastCharacters(t *testing.T) {
+	c := &PushChannel{}
+
+	redacted := c.RedactMeta(map[string]string{"token": "device_token_xyz123", "platform": "android"})
+
+	if redacted["token"] != "****z123" {
+		t.Fatalf("expected masked token, got %q", redacted["token"])
+	}
+	if redacted["platform"] != "android" {
+		t.Fatalf("expected platform unchanged, got %q", redacted["platform"])
+	}
+}
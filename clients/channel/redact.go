@@ -0,0 +1,37 @@
+package channels
+
+import (
+	"net/url"
+	"strings"
+)
+
+// maskMiddle replaces every rune of s with '*' except the first keepStart and last keepEnd
+func maskMiddle(s string, keepStart, keepEnd int) string {
+	runes := []rune(s)
+	if len(runes) <= keepStart+keepEnd {
+		return strings.Repeat("*", len(runes))
+	}
+	for i := keepStart; i < len(runes)-keepEnd; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+// redactURL keeps the scheme and host of rawURL and masks the rest,
+// "https://files.example.com/invoice.pdf?sig=abc" becomes "https://files.example.com/***"
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return strings.Repeat("*", len([]rune(rawURL)))
+	}
+	return u.Scheme + "://" + u.Host + "/***"
+}
+
+// copyMeta returns a copy of meta so a redaction never changes the stored value
+func copyMeta(meta map[string]string) map[string]string {
+	copied := make(map[string]string, len(meta))
+	for k, v := range meta {
+		copied[k] = v
+	}
+	return copied
+}
@@ -2,11 +2,12 @@ package channels
 
 import (
 	"context"
-	"fmt"
-	"notification/models/channel"
 	"regexp"
+	"serverless-notification/domain/notification"
 )
 
+var phoneRegexp = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+
 type SMSChannel struct{}
 
 // ValidSMSMeta represents the required metadata for SMS notifications
@@ -19,21 +20,31 @@ func (c *SMSChannel) Name() string {
 	return "sms"
 }
 
-func (c *SMSChannel) Send(ctx context.Context, msg channel.Message) error {
-	return nil
+func (c *SMSChannel) Send(ctx context.Context, msg notification.Message) (notification.Receipt, error) {
+	return notification.Receipt{Provider: "noop"}, nil
 }
 
 func (c *SMSChannel) Validate(meta map[string]string) error {
-	if phone, ok := meta["phone"]; !ok || !regexp.MustCompile(`^\+[1-9]\d{1,14}$`).MatchString(phone) {
-		return fmt.Errorf("phone field with valid phone number is required")
+	errs := &notification.ValidationError{}
+	if phone, ok := meta["phone"]; !ok || !phoneRegexp.MatchString(phone) {
+		errs.Add("phone", "phone field with valid phone number is required")
 	}
 	if carrier, ok := meta["carrier"]; !ok || carrier == "" {
-		return fmt.Errorf("carrier field is required")
+		errs.Add("carrier", "carrier field is required")
 	}
-	return nil
+	return errs.ErrOrNil()
+}
+
+// RedactMeta masks the middle digits of the phone number, "+1234567890" becomes "+12*****890"
+func (c *SMSChannel) RedactMeta(meta map[string]string) map[string]string {
+	redacted := copyMeta(meta)
+	if phone, ok := redacted["phone"]; ok {
+		redacted["phone"] = maskMiddle(phone, 3, 3)
+	}
+	return redacted
 }
 
-func (c *SMSChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+func (c *SMSChannel) Prepare(ctx context.Context, msg *notification.Message) error {
 	if len(msg.Content) > 160 {
 		msg.Content = msg.Content[:160]
 	}
@@ -2,7 +2,8 @@ package channels
 
 import (
 	"context"
-	"notification/models/channel"
+	"errors"
+	"serverless-notification/domain/notification"
 	"strings"
 	"testing"
 )
@@ -73,7 +74,7 @@ func TestSMSValidate_EmptyCarrier(t *testing.T) {
 
 func TestSMSSend_OK(t *testing.T) {
 	c := &SMSChannel{}
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Test",
 		Content: "Hello SMS",
 		Meta: map[string]string{
@@ -81,7 +82,7 @@ func TestSMSSend_OK(t *testing.T) {
 			"carrier": "att",
 		},
 	}
-	if err := c.Send(context.Background(), msg); err != nil {
+	if _, err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
 }
@@ -89,7 +90,7 @@ func TestSMSSend_OK(t *testing.T) {
 func TestSMSPrepare_TruncatesLongContent(t *testing.T) {
 	c := &SMSChannel{}
 	longContent := strings.Repeat("a", 200)
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Test",
 		Content: longContent,
 		Meta: map[string]string{
@@ -110,7 +111,7 @@ func TestSMSPrepare_TruncatesLongContent(t *testing.T) {
 func TestSMSPrepare_ShortContentUnchanged(t *testing.T) {
 	c := &SMSChannel{}
 	shortContent := "Short message"
-	msg := channel.Message{
+	msg := notification.Message{
 		Title:   "Test",
 		Content: shortContent,
 		Meta: map[string]string{
@@ -134,3 +135,36 @@ func TestSMSName(t *testing.T) {
 		t.Fatalf("expected name 'sms', got %q", c.Name())
 	}
 }
+
+func TestSMSValidate_ReportsEveryInvalidField(t *testing.T) {
+	c := &SMSChannel{}
+	err := c.Validate(map[string]string{"phone": "123"})
+
+	var validationErr *notification.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+	if len(validationErr.Fields) != 2 {
+		t.Fatalf("expected 2 invalid fields, got %v", validationErr.Fields)
+	}
+	if validationErr.Fields[0].Field != "phone" || validationErr.Fields[1].Field != "carrier" {
+		t.Fatalf("unexpected fields: %v", validationErr.Fields)
+	}
+}
+
+func TestSMSRedactMeta_MasksMiddleDigits(t *testing.T) {
+	c := &SMSChannel{}
+	meta := map[string]string{"phone": "+1234567890", "carrier": "verizon"}
+
+	redacted := c.RedactMeta(meta)
+
+	if redacted["phone"] != "+12*****890" {
+		t.Fatalf("expected masked phone, got %q", redacted["phone"])
+	}
+	if redacted["carrier"] != "verizon" {
+		t.Fatalf("expected carrier unchanged, got %q", redacted["carrier"])
+	}
+	if meta["phone"] != "+1234567890" {
+		t.Fatalf("expected original meta unchanged, got %q", meta["phone"])
+	}
+}
@@ -0,0 +1,193 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"regexp"
+	"serverless-notification/domain/notification"
+	"sort"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// defaultTemplate is used when meta has no template
+const defaultTemplate = "plain"
+
+var ErrUnknownTemplate = errors.New("unknown template")
+
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// builtinTemplates are compiled into the binary, so they load wherever the binary runs
+//
+//go:embed email/*.tmpl
+var builtinTemplates embed.FS
+
+// emailTemplate renders the text of an email and optionally an HTML alternative
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *template.Template
+}
+
+// templateData is what the templates render, Inline are the content ids of the inline images
+type templateData struct {
+	notification.Message
+	Inline []string
+}
+
+func (t *emailTemplate) render(msg templateData) (text, html string, err error) {
+	var buf bytes.Buffer
+	if err := t.text.Execute(&buf, msg); err != nil {
+		return "", "", err
+	}
+	text = buf.String()
+	if t.html == nil {
+		return text, "", nil
+	}
+	buf.Reset()
+	if err := t.html.Execute(&buf, msg); err != nil {
+		return "", "", err
+	}
+	return text, buf.String(), nil
+}
+
+// TemplateRegistry holds the email templates by name, each is a text body and
+// an optional HTML alternative. It is safe for concurrent use
+type TemplateRegistry struct {
+	mu        sync.RWMutex
+	templates map[string]*emailTemplate
+}
+
+// NewTemplateRegistry returns a registry with the built-in templates, titled and plain
+func NewTemplateRegistry() *TemplateRegistry {
+	r := &TemplateRegistry{templates: map[string]*emailTemplate{}}
+	sub, err := fs.Sub(builtinTemplates, "email")
+	if err != nil {
+		panic(err)
+	}
+	if err := r.LoadFS(sub); err != nil {
+		panic("invalid built-in email template: " + err.Error())
+	}
+	return r
+}
+
+// Register parses and adds a template, replacing any template with the same name
+// text is required, html is optional
+func (r *TemplateRegistry) Register(name, text, html string) error {
+	if !templateNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	if text == "" {
+		return fmt.Errorf("template %s: text is required", name)
+	}
+	t := &emailTemplate{}
+	var err error
+	if t.text, err = texttemplate.New(name).Parse(text); err != nil {
+		return fmt.Errorf("template %s: %w", name, err)
+	}
+	if html != "" {
+		if t.html, err = template.New(name).Parse(html); err != nil {
+			return fmt.Errorf("template %s: %w", name, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.templates[name] = t
+	return nil
+}
+
+// LoadFS registers every <name>.txt.tmpl of fsys along with <name>.html.tmpl if present
+func (r *TemplateRegistry) LoadFS(fsys fs.FS) error {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return err
+	}
+	texts := map[string]string{}
+	htmls := map[string]string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		var parts map[string]string
+		var name string
+		switch fileName := entry.Name(); {
+		case strings.HasSuffix(fileName, ".txt.tmpl"):
+			parts, name = texts, strings.TrimSuffix(fileName, ".txt.tmpl")
+		case strings.HasSuffix(fileName, ".html.tmpl"):
+			parts, name = htmls, strings.TrimSuffix(fileName, ".html.tmpl")
+		default:
+			continue
+		}
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return err
+		}
+		parts[name] = string(content)
+	}
+
+	var errs []error
+	for name := range htmls {
+		if _, ok := texts[name]; !ok {
+			errs = append(errs, fmt.Errorf("template %s: %s.txt.tmpl is required", name, name))
+		}
+	}
+	for name, text := range texts {
+		if err := r.Register(name, text, htmls[name]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LoadDir registers the templates of a directory, see LoadFS
+func (r *TemplateRegistry) LoadDir(dir string) error {
+	return r.LoadFS(os.DirFS(dir))
+}
+
+// LoadStore registers every template of store, a template that fails to parse is skipped and reported
+func (r *TemplateRegistry) LoadStore(ctx context.Context, store notification.TemplateStore) error {
+	templates, err := store.ListTemplates(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list templates: %w", err)
+	}
+	var errs []error
+	for _, t := range templates {
+		if err := r.Register(t.Name, t.Text, t.HTML); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Names returns the registered template names in order
+func (r *TemplateRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.templates))
+	for name := range r.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// get returns the template registered under name, the default one when name is empty
+func (r *TemplateRegistry) get(name string) (*emailTemplate, error) {
+	if name == "" {
+		name = defaultTemplate
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	t, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTemplate, name)
+	}
+	return t, nil
+}
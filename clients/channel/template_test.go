@@ -0,0 +1,136 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"serverless-notification/domain/notification"
+	"strings"
+	"testing"
+)
+
+type fakeTemplateStore []*notification.Template
+
+func (s fakeTemplateStore) ListTemplates(ctx context.Context) ([]*notification.Template, error) {
+	return s, nil
+}
+
+func TestNewTemplateRegistry_BuiltinsOutsideTheSourceTree(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd: %v", err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Chdir: %v", err)
+	}
+	defer os.Chdir(wd)
+
+	r := NewTemplateRegistry()
+
+	if got := strings.Join(r.Names(), ","); got != "plain,titled" {
+		t.Fatalf("expected the built-in templates, got %s", got)
+	}
+	tmpl, err := r.get("titled")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	text, html, err := tmpl.render(templateData{Message: notification.Message{Title: "Hola", Content: "Mundo"}})
+	if err != nil || !strings.Contains(text, "Mundo") || !strings.Contains(html, "<h1>Notification: Hola</h1>") {
+		t.Fatalf("unexpected render: %q %q %v", text, html, err)
+	}
+}
+
+func TestTemplateRegistry_LoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"receipt.txt.tmpl":  "Order {{.Content}}",
+		"receipt.html.tmpl": "<b>Order {{.Content}}</b>",
+		"plain.txt.tmpl":    "Custom {{.Content}}",
+		"orphan.html.tmpl":  "<p>{{.Content}}</p>",
+		"README.md":         "not a template",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	r := NewTemplateRegistry()
+
+	err := r.LoadDir(dir)
+
+	if err == nil || !strings.Contains(err.Error(), "orphan") {
+		t.Fatalf("expected the html without text to be reported, got %v", err)
+	}
+	if got := strings.Join(r.Names(), ","); got != "plain,receipt,titled" {
+		t.Fatalf("expected receipt to be added, got %s", got)
+	}
+	plain, _ := r.get("")
+	if text, _, _ := plain.render(templateData{Message: notification.Message{Content: "x"}}); text != "Custom x" {
+		t.Fatalf("expected the directory to override plain, got %q", text)
+	}
+}
+
+func TestTemplateRegistry_LoadStore(t *testing.T) {
+	r := NewTemplateRegistry()
+	store := fakeTemplateStore{
+		{Name: "welcome", Text: "Welcome {{.Title}}", HTML: "<h1>Welcome {{.Title}}</h1>"},
+		{Name: "broken", Text: "{{.Title"},
+	}
+
+	err := r.LoadStore(context.Background(), store)
+
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected the broken template to be reported, got %v", err)
+	}
+	if _, err := r.get("welcome"); err != nil {
+		t.Fatalf("expected welcome to be registered, got %v", err)
+	}
+	if _, err := r.get("broken"); !errors.Is(err, ErrUnknownTemplate) {
+		t.Fatalf("expected broken to be skipped, got %v", err)
+	}
+}
+
+func TestTemplateRegistry_RegisterInvalid(t *testing.T) {
+	r := NewTemplateRegistry()
+
+	if err := r.Register("../etc", "x", ""); err == nil {
+		t.Fatal("expected error for an invalid name")
+	}
+	if err := r.Register("empty", "", "<p>x</p>"); err == nil {
+		t.Fatal("expected error for a template without text")
+	}
+}
+
+func TestEmailValidate_UnknownTemplate(t *testing.T) {
+	c := &EmailChannel{}
+
+	err := c.Validate(map[string]string{"to": "user@example.com", "template": "fancy"})
+
+	var validationErr *notification.ValidationError
+	if !errors.As(err, &validationErr) || len(validationErr.Fields) != 1 || validationErr.Fields[0].Field != "template" {
+		t.Fatalf("expected validation error on field 'template', got %v", err)
+	}
+}
+
+func TestEmailSend_RegisteredTemplate(t *testing.T) {
+	transport := &fakeTransport{}
+	r := NewTemplateRegistry()
+	if err := r.Register("receipt", "Receipt: {{.Content}}", ""); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	c := NewEmailChannel("noreply@example.com", transport)
+	c.UseTemplates(r)
+
+	if _, err := c.Send(context.Background(), notification.Message{Title: "Hola", Content: "#42", Meta: map[string]string{"to": "user@example.com", "template": "receipt"}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if !strings.Contains(transport.msg, "Receipt: #42") {
+		t.Fatalf("expected the registered template, got %q", transport.msg)
+	}
+
+	_, err := c.Send(context.Background(), notification.Message{Meta: map[string]string{"to": "user@example.com", "template": "missing"}})
+	if err == nil {
+		t.Fatal("expected an unknown template not to be sent")
+	}
+}
@@ -0,0 +1,45 @@
+package clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrObjectNotFound is returned by Open when the bucket has no object under the key
+var ErrObjectNotFound = errors.New("object not found")
+
+// S3Client reads the email attachments referenced by object key from a bucket
+type S3Client struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Client creates a new S3Client reading from bucket
+func NewS3Client(client *s3.Client, bucket string) *S3Client {
+	return &S3Client{
+		client: client,
+		bucket: bucket,
+	}
+}
+
+// Open returns the content of the object under key, the caller closes it
+func (c *S3Client) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := c.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, key)
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", key, err)
+	}
+	return out.Body, nil
+}
@@ -0,0 +1,287 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	netsmtp "net/smtp"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Security is how the connection to the server is encrypted
+type Security string
+
+const (
+	// SecurityStartTLS upgrades a plain connection with STARTTLS, usually on port 587
+	SecurityStartTLS Security = "starttls"
+	// SecurityTLS connects with TLS from the start (implicit TLS), usually on port 465
+	SecurityTLS Security = "tls"
+	// SecurityNone never encrypts, only meant for local servers
+	SecurityNone Security = "none"
+)
+
+const (
+	AuthPlain = "plain"
+	AuthLogin = "login"
+)
+
+// defaultTimeout bounds the dial and every command when Config.Timeout is not set
+const defaultTimeout = 10 * time.Second
+
+// Config configures the connection to the SMTP server
+type Config struct {
+	Host     string
+	Port     int
+	Username string // no authentication when empty
+	Password string
+	Security Security // defaults to SecurityStartTLS
+	Auth     string   // AuthPlain (default) or AuthLogin
+	// Timeout bounds the dial and every SMTP command
+	Timeout time.Duration
+	// LocalName is sent with EHLO, defaults to localhost
+	LocalName string
+	// TLSConfig is used for both STARTTLS and implicit TLS, ServerName defaults to Host
+	TLSConfig *tls.Config
+}
+
+// Client sends messages through an SMTP server, keeping the connection open
+// between messages until Close. It is safe for concurrent use, messages are
+// sent one at a time
+type Client struct {
+	cfg Config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	client *netsmtp.Client
+}
+
+// NewClient validates cfg and returns a client, it does not connect until the first Send
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Host == "" {
+		return nil, errors.New("smtp: host is required")
+	}
+	if cfg.Port <= 0 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("smtp: invalid port %d", cfg.Port)
+	}
+	if cfg.Security == "" {
+		cfg.Security = SecurityStartTLS
+	}
+	switch cfg.Security {
+	case SecurityStartTLS, SecurityTLS, SecurityNone:
+	default:
+		return nil, fmt.Errorf("smtp: unknown security %q", cfg.Security)
+	}
+	if cfg.Auth == "" {
+		cfg.Auth = AuthPlain
+	}
+	if cfg.Auth != AuthPlain && cfg.Auth != AuthLogin {
+		return nil, fmt.Errorf("smtp: unknown auth %q", cfg.Auth)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.LocalName == "" {
+		cfg.LocalName = "localhost"
+	}
+	return &Client{cfg: cfg}, nil
+}
+
+// Send sends msg, a complete RFC 5322 message, from the envelope sender to every recipient
+// An open connection is reused, if the server dropped it a new one is dialed
+func (c *Client) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	if len(to) == 0 {
+		return errors.New("smtp: no recipients")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ready(ctx); err != nil {
+		return err
+	}
+	err := c.send(ctx, from, to, msg)
+	// A rejection leaves the session usable, anything else may have broken it
+	var protocolErr *textproto.Error
+	if err != nil && !errors.As(err, &protocolErr) {
+		c.drop()
+	}
+	return err
+}
+
+// Close ends the session with QUIT and closes the connection
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.client == nil {
+		return nil
+	}
+	c.conn.SetDeadline(time.Now().Add(c.cfg.Timeout))
+	err := c.client.Quit()
+	c.drop()
+	return err
+}
+
+// ready makes sure there is a connection with no transaction in progress
+func (c *Client) ready(ctx context.Context) error {
+	if c.client != nil {
+		c.extendDeadline(ctx)
+		if err := c.client.Reset(); err == nil {
+			return nil
+		}
+		// Servers close idle connections, dial again
+		c.drop()
+	}
+	return c.dial(ctx)
+}
+
+func (c *Client) send(ctx context.Context, from string, to []string, msg []byte) error {
+	c.extendDeadline(ctx)
+	if err := c.client.Mail(from); err != nil {
+		return fmt.Errorf("smtp: MAIL FROM: %w", err)
+	}
+	for _, rcpt := range to {
+		c.extendDeadline(ctx)
+		if err := c.client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp: RCPT TO %s: %w", rcpt, err)
+		}
+	}
+	c.extendDeadline(ctx)
+	w, err := c.client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp: DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp: writing message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp: message rejected: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) dial(ctx context.Context) error {
+	address := net.JoinHostPort(c.cfg.Host, strconv.Itoa(c.cfg.Port))
+	dialer := &net.Dialer{Timeout: c.cfg.Timeout}
+	var conn net.Conn
+	var err error
+	if c.cfg.Security == SecurityTLS {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: c.tlsConfig()}).DialContext(ctx, "tcp", address)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return fmt.Errorf("smtp: dial %s: %w", address, err)
+	}
+	c.conn = conn
+	c.extendDeadline(ctx)
+
+	client, err := netsmtp.NewClient(conn, c.cfg.Host)
+	if err != nil {
+		conn.Close()
+		c.conn = nil
+		return fmt.Errorf("smtp: greeting: %w", err)
+	}
+	c.client = client
+	if err := c.handshake(); err != nil {
+		c.drop()
+		return err
+	}
+	return nil
+}
+
+// handshake says hello, upgrades to TLS when configured and authenticates
+func (c *Client) handshake() error {
+	if err := c.client.Hello(c.cfg.LocalName); err != nil {
+		return fmt.Errorf("smtp: EHLO: %w", err)
+	}
+	if c.cfg.Security == SecurityStartTLS {
+		if ok, _ := c.client.Extension("STARTTLS"); !ok {
+			return errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := c.client.StartTLS(c.tlsConfig()); err != nil {
+			return fmt.Errorf("smtp: STARTTLS: %w", err)
+		}
+	}
+	if c.cfg.Username == "" {
+		return nil
+	}
+	if ok, _ := c.client.Extension("AUTH"); !ok {
+		return errors.New("smtp: server does not support AUTH")
+	}
+	var auth netsmtp.Auth
+	if c.cfg.Auth == AuthLogin {
+		auth = &loginAuth{username: c.cfg.Username, password: c.cfg.Password}
+	} else {
+		auth = netsmtp.PlainAuth("", c.cfg.Username, c.cfg.Password, c.cfg.Host)
+	}
+	if err := c.client.Auth(auth); err != nil {
+		return fmt.Errorf("smtp: AUTH: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) tlsConfig() *tls.Config {
+	cfg := &tls.Config{}
+	if c.cfg.TLSConfig != nil {
+		cfg = c.cfg.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = c.cfg.Host
+	}
+	return cfg
+}
+
+// extendDeadline gives the next command Timeout, or until ctx ends if that is sooner
+func (c *Client) extendDeadline(ctx context.Context) {
+	deadline := time.Now().Add(c.cfg.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.conn.SetDeadline(deadline)
+}
+
+// drop closes the connection without QUIT
+func (c *Client) drop() {
+	if c.client != nil {
+		c.client.Close()
+	} else if c.conn != nil {
+		c.conn.Close()
+	}
+	c.client = nil
+	c.conn = nil
+}
+
+// loginAuth implements the LOGIN mechanism, which net/smtp does not provide
+// Like PlainAuth it refuses to send the password over an unencrypted connection to another host
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *netsmtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:", "username:":
+		return []byte(a.username), nil
+	case "Password:", "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
@@ -0,0 +1,435 @@
+package smtp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"net"
+	netsmtp "net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeMessage struct {
+	from string
+	to   []string
+	data string
+}
+
+// fakeServer is an in-process SMTP server with just enough of the protocol for Client
+type fakeServer struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config // offers STARTTLS when set, unless implicitTLS
+	implicitTLS bool
+	username    string // requires AUTH when set
+	password    string
+	stall       time.Duration // delay before accepting a message
+	closeAfter  bool          // drop the connection after every message
+
+	mu          sync.Mutex
+	connections int
+	mechanisms  []string
+	messages    []fakeMessage
+	quits       int
+}
+
+func newFakeServer(t *testing.T, configure func(s *fakeServer)) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeServer{listener: listener}
+	if configure != nil {
+		configure(s)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	s.mu.Lock()
+	s.connections++
+	s.mu.Unlock()
+
+	secure := s.implicitTLS
+	if secure {
+		conn = tls.Server(conn, s.tlsConfig)
+	}
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 fake ESMTP")
+
+	var from string
+	var to []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-fake")
+			if !secure && s.tlsConfig != nil {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			if s.username != "" {
+				tp.PrintfLine("250-AUTH PLAIN LOGIN")
+			}
+			tp.PrintfLine("250 8BITMIME")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			if s.authenticate(tp, arg) {
+				tp.PrintfLine("235 authenticated")
+			} else {
+				tp.PrintfLine("535 bad credentials")
+			}
+		case "MAIL":
+			from = address(arg)
+			to = nil
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			to = append(to, address(arg))
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			time.Sleep(s.stall)
+			s.mu.Lock()
+			s.messages = append(s.messages, fakeMessage{from: from, to: to, data: string(data)})
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+			if s.closeAfter {
+				return
+			}
+		case "RSET":
+			from, to = "", nil
+			tp.PrintfLine("250 ok")
+		case "NOOP":
+			tp.PrintfLine("250 ok")
+		case "QUIT":
+			s.mu.Lock()
+			s.quits++
+			s.mu.Unlock()
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *fakeServer) authenticate(tp *textproto.Conn, arg string) bool {
+	mechanism, initial, _ := strings.Cut(arg, " ")
+	s.mu.Lock()
+	s.mechanisms = append(s.mechanisms, mechanism)
+	s.mu.Unlock()
+
+	read := func(challenge string) string {
+		tp.PrintfLine("334 %s", base64.StdEncoding.EncodeToString([]byte(challenge)))
+		line, _ := tp.ReadLine()
+		decoded, _ := base64.StdEncoding.DecodeString(line)
+		return string(decoded)
+	}
+	switch mechanism {
+	case "PLAIN":
+		response := initial
+		if response == "" {
+			line := read("")
+			return line == "\x00"+s.username+"\x00"+s.password
+		}
+		decoded, _ := base64.StdEncoding.DecodeString(response)
+		return string(decoded) == "\x00"+s.username+"\x00"+s.password
+	case "LOGIN":
+		return read("Username:") == s.username && read("Password:") == s.password
+	}
+	return false
+}
+
+func (s *fakeServer) snapshot() (connections int, messages []fakeMessage, mechanisms []string, quits int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections, append([]fakeMessage(nil), s.messages...), append([]string(nil), s.mechanisms...), s.quits
+}
+
+// address extracts the path of "FROM:<a@b> BODY=8BITMIME"
+func address(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
+
+// selfSigned returns the server config and a client config that trusts it
+func selfSigned(t *testing.T) (server, client *tls.Config) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fake smtp"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	return server, &tls.Config{RootCAs: pool}
+}
+
+func newTestClient(t *testing.T, cfg Config) *Client {
+	t.Helper()
+	c, err := NewClient(cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+const testMessage = "Subject: Hola\r\n\r\nMundo\r\n"
+
+func TestSend_StartTLSWithPlainAuth(t *testing.T) {
+	// Arrange
+	serverTLS, clientTLS := selfSigned(t)
+	s := newFakeServer(t, func(s *fakeServer) {
+		s.tlsConfig = serverTLS
+		s.username, s.password = "mailer", "secret"
+	})
+	c := newTestClient(t, Config{
+		Host: "127.0.0.1", Port: s.port(), Username: "mailer", Password: "secret",
+		Security: SecurityStartTLS, TLSConfig: clientTLS,
+	})
+
+	// Act
+	err := c.Send(context.Background(), "from@example.com", []string{"a@example.com", "b@example.com"}, []byte(testMessage))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	_, messages, mechanisms, _ := s.snapshot()
+	if len(messages) != 1 || messages[0].from != "from@example.com" || len(messages[0].to) != 2 {
+		t.Fatalf("expected one message to two recipients, got %+v", messages)
+	}
+	if !strings.Contains(messages[0].data, "Mundo") {
+		t.Fatalf("expected message body, got %q", messages[0].data)
+	}
+	if len(mechanisms) != 1 || mechanisms[0] != "PLAIN" {
+		t.Fatalf("expected PLAIN auth, got %v", mechanisms)
+	}
+}
+
+func TestSend_ImplicitTLSWithLoginAuth(t *testing.T) {
+	// Arrange
+	serverTLS, clientTLS := selfSigned(t)
+	s := newFakeServer(t, func(s *fakeServer) {
+		s.tlsConfig = serverTLS
+		s.implicitTLS = true
+		s.username, s.password = "mailer", "secret"
+	})
+	c := newTestClient(t, Config{
+		Host: "127.0.0.1", Port: s.port(), Username: "mailer", Password: "secret",
+		Security: SecurityTLS, Auth: AuthLogin, TLSConfig: clientTLS,
+	})
+
+	// Act
+	err := c.Send(context.Background(), "from@example.com", []string{"a@example.com"}, []byte(testMessage))
+
+	// Assert
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	_, messages, mechanisms, _ := s.snapshot()
+	if len(messages) != 1 {
+		t.Fatalf("expected one message, got %d", len(messages))
+	}
+	if len(mechanisms) != 1 || mechanisms[0] != "LOGIN" {
+		t.Fatalf("expected LOGIN auth, got %v", mechanisms)
+	}
+}
+
+func TestSend_WrongPasswordFails(t *testing.T) {
+	serverTLS, clientTLS := selfSigned(t)
+	s := newFakeServer(t, func(s *fakeServer) {
+		s.tlsConfig = serverTLS
+		s.username, s.password = "mailer", "secret"
+	})
+	c := newTestClient(t, Config{
+		Host: "127.0.0.1", Port: s.port(), Username: "mailer", Password: "wrong", TLSConfig: clientTLS,
+	})
+
+	err := c.Send(context.Background(), "from@example.com", []string{"a@example.com"}, []byte(testMessage))
+
+	if err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Fatalf("expected AUTH error, got %v", err)
+	}
+}
+
+func TestSend_StartTLSRequired(t *testing.T) {
+	s := newFakeServer(t, nil)
+	c := newTestClient(t, Config{Host: "127.0.0.1", Port: s.port()})
+
+	err := c.Send(context.Background(), "from@example.com", []string{"a@example.com"}, []byte(testMessage))
+
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("expected STARTTLS error, got %v", err)
+	}
+	if _, messages, _, _ := s.snapshot(); len(messages) != 0 {
+		t.Fatal("expected nothing to be sent in clear text")
+	}
+}
+
+func TestSend_ReusesConnection(t *testing.T) {
+	// Arrange
+	s := newFakeServer(t, nil)
+	c := newTestClient(t, Config{Host: "127.0.0.1", Port: s.port(), Security: SecurityNone})
+
+	// Act
+	for i := 0; i < 3; i++ {
+		if err := c.Send(context.Background(), "from@example.com", []string{"user" + strconv.Itoa(i) + "@example.com"}, []byte(testMessage)); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+	closeErr := c.Close()
+
+	// Assert
+	if closeErr != nil {
+		t.Fatalf("Close: %v", closeErr)
+	}
+	connections, messages, _, quits := s.snapshot()
+	if connections != 1 || len(messages) != 3 {
+		t.Fatalf("expected 3 messages over 1 connection, got %d over %d", len(messages), connections)
+	}
+	if quits != 1 {
+		t.Fatalf("expected Close to QUIT, got %d", quits)
+	}
+}
+
+func TestSend_RedialsDroppedConnection(t *testing.T) {
+	s := newFakeServer(t, func(s *fakeServer) { s.closeAfter = true })
+	c := newTestClient(t, Config{Host: "127.0.0.1", Port: s.port(), Security: SecurityNone})
+
+	for i := 0; i < 2; i++ {
+		if err := c.Send(context.Background(), "from@example.com", []string{"a@example.com"}, []byte(testMessage)); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+
+	connections, messages, _, _ := s.snapshot()
+	if connections != 2 || len(messages) != 2 {
+		t.Fatalf("expected 2 messages over 2 connections, got %d over %d", len(messages), connections)
+	}
+}
+
+func TestSend_Timeout(t *testing.T) {
+	s := newFakeServer(t, func(s *fakeServer) { s.stall = time.Second })
+	c := newTestClient(t, Config{Host: "127.0.0.1", Port: s.port(), Security: SecurityNone, Timeout: 100 * time.Millisecond})
+
+	start := time.Now()
+	err := c.Send(context.Background(), "from@example.com", []string{"a@example.com"}, []byte(testMessage))
+
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Fatalf("expected Send to give up after the timeout, took %s", elapsed)
+	}
+}
+
+func TestSend_ContextDeadline(t *testing.T) {
+	s := newFakeServer(t, func(s *fakeServer) { s.stall = time.Second })
+	c := newTestClient(t, Config{Host: "127.0.0.1", Port: s.port(), Security: SecurityNone})
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.Send(ctx, "from@example.com", []string{"a@example.com"}, []byte(testMessage))
+
+	if err == nil {
+		t.Fatal("expected error once the context deadline passed")
+	}
+	if elapsed := time.Since(start); elapsed > 900*time.Millisecond {
+		t.Fatalf("expected Send to honour the context deadline, took %s", elapsed)
+	}
+}
+
+func TestNewClient_InvalidConfig(t *testing.T) {
+	tests := []Config{
+		{Port: 587},
+		{Host: "smtp.example.com"},
+		{Host: "smtp.example.com", Port: 587, Security: "ssl"},
+		{Host: "smtp.example.com", Port: 587, Auth: "cram-md5"},
+	}
+
+	for _, cfg := range tests {
+		if _, err := NewClient(cfg); err == nil {
+			t.Fatalf("expected error for %+v", cfg)
+		}
+	}
+}
+
+func TestLoginAuth_RefusesClearTextToRemoteHost(t *testing.T) {
+	auth := &loginAuth{username: "mailer", password: "secret"}
+
+	if _, _, err := auth.Start(&netsmtp.ServerInfo{Name: "smtp.example.com", TLS: false}); err == nil {
+		t.Fatal("expected LOGIN to refuse an unencrypted connection")
+	}
+	if _, _, err := auth.Start(&netsmtp.ServerInfo{Name: "smtp.example.com", TLS: true}); err != nil {
+		t.Fatalf("expected LOGIN over TLS, got %v", err)
+	}
+}
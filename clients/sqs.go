@@ -10,6 +10,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 // SQSClient implements the notification.Queue to send messages to Amazon SQS
@@ -41,7 +42,7 @@ func (c *SQSClient) Publish(ctx context.Context, msg *notification.DispatchMessa
 		QueueUrl:               aws.String(c.queueURL),
 		MessageBody:            aws.String(string(messageJSON)),
 		MessageGroupId:         aws.String(msg.UserID),
-		MessageDeduplicationId: aws.String(msg.NotificationID),
+		MessageDeduplicationId: aws.String(msg.DeduplicationID()),
 	}
 
 	output, err := c.client.SendMessage(ctx, input)
@@ -52,3 +53,70 @@ func (c *SQSClient) Publish(ctx context.Context, msg *notification.DispatchMessa
 
 	return nil
 }
+
+const (
+	// maxBatchEntries is the most messages a SendMessageBatch request accepts
+	maxBatchEntries = 10
+	// maxBatchBytes is the most payload a SendMessageBatch request accepts
+	maxBatchBytes = 256 * 1024
+)
+
+// PublishBatch sends the messages with SendMessageBatch, as many per request as SQS allows
+// It returns the error of every message that was not sent, keyed by notification ID
+func (c *SQSClient) PublishBatch(ctx context.Context, messages []*notification.DispatchMessage) map[string]error {
+	failed := make(map[string]error)
+	if c.queueURL == "" {
+		for _, msg := range messages {
+			failed[msg.NotificationID] = fmt.Errorf("queue URL is not set")
+		}
+		return failed
+	}
+
+	var entries []types.SendMessageBatchRequestEntry
+	size := 0
+	flush := func() {
+		if len(entries) == 0 {
+			return
+		}
+		c.sendBatch(ctx, entries, failed)
+		entries = nil
+		size = 0
+	}
+	for _, msg := range messages {
+		messageJSON, err := json.Marshal(msg)
+		if err != nil {
+			failed[msg.NotificationID] = fmt.Errorf("failed to marshal message: %w", err)
+			continue
+		}
+		if len(entries) == maxBatchEntries || size+len(messageJSON) > maxBatchBytes {
+			flush()
+		}
+		// Notification IDs are valid entry IDs, SQS reports failures by them
+		entries = append(entries, types.SendMessageBatchRequestEntry{
+			Id:                     aws.String(msg.NotificationID),
+			MessageBody:            aws.String(string(messageJSON)),
+			MessageGroupId:         aws.String(msg.UserID),
+			MessageDeduplicationId: aws.String(msg.DeduplicationID()),
+		})
+		size += len(messageJSON)
+	}
+	flush()
+	return failed
+}
+
+func (c *SQSClient) sendBatch(ctx context.Context, entries []types.SendMessageBatchRequestEntry, failed map[string]error) {
+	output, err := c.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		QueueUrl: aws.String(c.queueURL),
+		Entries:  entries,
+	})
+	if err != nil {
+		for _, entry := range entries {
+			failed[*entry.Id] = fmt.Errorf("failed to send message batch to SQS: %w", err)
+		}
+		return
+	}
+	for _, entry := range output.Failed {
+		failed[aws.ToString(entry.Id)] = fmt.Errorf("failed to send message to SQS: %s: %s", aws.ToString(entry.Code), aws.ToString(entry.Message))
+	}
+	log.Printf("Message batch sent to SQS: %d sent, %d failed", len(output.Successful), len(output.Failed))
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"serverless-notification/cmd"
+	"serverless-notification/cmd/api/middleware"
 	"serverless-notification/cmd/api/routes"
 
 	"github.com/aws/aws-lambda-go/lambda"
@@ -22,7 +23,8 @@ func init() {
 }
 
 func main() {
-	service := cmd.InitDependencies()
+	deps := cmd.InitDependencies()
+	service := deps.Service
 
 	router := gin.Default()
 
@@ -33,8 +35,27 @@ func main() {
 		})
 	})
 
-	notificationRouteHandler := routes.NewNotificationRouteHandler(service)
-	notificationRouteHandler.RegisterRoutes(router)
+	authRouteHandler := routes.NewAuthRouteHandler(deps.Users, cmd.InitJWTIssuer())
+	authRouteHandler.RegisterRoutes(router)
+
+	notificationRouteHandler := routes.NewNotificationRouteHandler(service, deps.Channels)
+	authenticated := router.Group("/", middleware.Authenticate(cmd.InitJWTVerifier(), deps.APIKeys))
+	notificationRouteHandler.RegisterRoutes(authenticated)
+
+	batchRouteHandler := routes.NewBatchRouteHandler(service)
+	batchRouteHandler.RegisterRoutes(authenticated)
+
+	apiKeyRouteHandler := routes.NewAPIKeyRouteHandler(deps.APIKeys)
+	apiKeyRouteHandler.RegisterRoutes(authenticated)
+
+	contactRouteHandler := routes.NewContactRouteHandler(deps.Contacts)
+	contactRouteHandler.RegisterRoutes(authenticated)
+
+	preferenceRouteHandler := routes.NewPreferenceRouteHandler(deps.Preferences)
+	preferenceRouteHandler.RegisterRoutes(authenticated)
+
+	topicRouteHandler := routes.NewTopicRouteHandler(deps.Topics, service)
+	topicRouteHandler.RegisterRoutes(authenticated)
 
 	if isLambda() {
 		log.Println("Running in Lambda mode")
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// JWTIssuer signs the tokens returned by signup and login
+// It signs with RS256 when it has a private key and with HS256 otherwise
+type JWTIssuer struct {
+	method jwt.SigningMethod
+	key    interface{}
+	ttl    time.Duration
+}
+
+// NewJWTIssuer creates an issuer, privateKeyPEM takes precedence over secret
+func NewJWTIssuer(secret string, privateKeyPEM string, ttl time.Duration) (*JWTIssuer, error) {
+	if privateKeyPEM != "" {
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %w", err)
+		}
+		return &JWTIssuer{method: jwt.SigningMethodRS256, key: privateKey, ttl: ttl}, nil
+	}
+	if secret != "" {
+		return &JWTIssuer{method: jwt.SigningMethodHS256, key: []byte(secret), ttl: ttl}, nil
+	}
+	return nil, ErrNoVerificationKey
+}
+
+// Issue returns a token for subject with role and when it expires, role is empty for regular users
+func (i *JWTIssuer) Issue(subject, role string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(i.ttl)
+	token, err := jwt.NewWithClaims(i.method, Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}).SignedString(i.key)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to sign token: %w", err)
+	}
+	return token, expiresAt, nil
+}
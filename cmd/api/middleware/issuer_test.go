@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"serverless-notification/domain/auth"
+	"testing"
+	"time"
+)
+
+func TestIssue_HS256RoundTrip(t *testing.T) {
+	issuer, err := NewJWTIssuer(secret, "", time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer: %v", err)
+	}
+	verifier, err := NewJWTVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+
+	token, expiresAt, err := issuer.Issue("usr_123", auth.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	principal, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if principal.UserID != "usr_123" || !principal.HasScope(auth.ScopeAPIKeysAdmin) {
+		t.Fatalf("expected usr_123 as admin, got %+v", principal)
+	}
+	if time.Until(expiresAt) < 59*time.Minute {
+		t.Fatalf("expected the token to expire in an hour, got %s", expiresAt)
+	}
+}
+
+func TestIssue_RS256RoundTrip(t *testing.T) {
+	key, publicKeyPEM := rsaKey(t)
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	issuer, err := NewJWTIssuer(secret, string(privateKeyPEM), time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer: %v", err)
+	}
+	verifier, err := NewJWTVerifier("", publicKeyPEM)
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+
+	token, _, err := issuer.Issue("usr_123", "")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if _, err := verifier.Verify(token); err != nil {
+		t.Fatalf("expected an RS256 token, Verify: %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net/http"
+	"serverless-notification/domain/auth"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoVerificationKey = errors.New("a JWT secret or RSA public key is required")
+
+// Claims are the claims of the tokens, Role and Scope are optional
+// Scope is a space-separated list of scopes and takes precedence over the scopes of Role
+type Claims struct {
+	Role  string `json:"role,omitempty"`
+	Scope string `json:"scope,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Scopes returns the scopes the claims grant, unknown scopes are ignored
+func (c *Claims) Scopes() []string {
+	if c.Scope == "" {
+		return auth.ScopesForRole(c.Role)
+	}
+	var scopes []string
+	for _, scope := range strings.Fields(c.Scope) {
+		if auth.KnownScope(scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// JWTVerifier validates HS256 tokens with a shared secret and RS256 tokens with a public key
+// Only the algorithms with a configured key are accepted
+type JWTVerifier struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	parser    *jwt.Parser
+}
+
+// NewJWTVerifier creates a verifier, secret and publicKeyPEM are optional but one is required
+func NewJWTVerifier(secret string, publicKeyPEM string) (*JWTVerifier, error) {
+	v := &JWTVerifier{}
+	var methods []string
+	if secret != "" {
+		v.secret = []byte(secret)
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	if publicKeyPEM != "" {
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA public key: %w", err)
+		}
+		v.publicKey = publicKey
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+	if len(methods) == 0 {
+		return nil, ErrNoVerificationKey
+	}
+	v.parser = jwt.NewParser(jwt.WithValidMethods(methods), jwt.WithExpirationRequired())
+	return v, nil
+}
+
+// Verify validates the token and returns its subject as a principal with the scopes of the token
+func (v *JWTVerifier) Verify(token string) (auth.Principal, error) {
+	var claims Claims
+	if _, err := v.parser.ParseWithClaims(token, &claims, v.key); err != nil {
+		return auth.Principal{}, err
+	}
+	if claims.Subject == "" {
+		return auth.Principal{}, errors.New("token has no subject")
+	}
+	return auth.Principal{UserID: claims.Subject, Scopes: claims.Scopes()}, nil
+}
+
+func (v *JWTVerifier) key(token *jwt.Token) (interface{}, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return v.publicKey, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+// KeyAuthenticator resolves the principal of an API key, failing with auth.ErrUnauthenticated
+type KeyAuthenticator interface {
+	Authenticate(ctx context.Context, key string) (auth.Principal, error)
+}
+
+// Authenticate rejects requests without a valid bearer token or X-API-Key with 401
+// and stores the caller as the auth.Principal of the request context
+// keys is optional, without it API keys are not accepted
+func Authenticate(verifier *JWTVerifier, keys KeyAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var principal auth.Principal
+		if key := c.GetHeader("X-API-Key"); key != "" && keys != nil {
+			p, err := keys.Authenticate(c.Request.Context(), key)
+			if errors.Is(err, auth.ErrUnauthenticated) {
+				unauthorized(c, "invalid api key")
+				return
+			}
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			principal = p
+		} else {
+			token, ok := bearerToken(c.GetHeader("Authorization"))
+			if !ok {
+				unauthorized(c, "missing bearer token")
+				return
+			}
+			p, err := verifier.Verify(token)
+			if err != nil {
+				unauthorized(c, "invalid token")
+				return
+			}
+			principal = p
+		}
+		ctx := auth.WithPrincipal(c.Request.Context(), principal)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// RequireScope rejects callers that were not granted scope with 403
+// Must run after Authenticate
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.FromContext(c.Request.Context())
+		if !ok {
+			unauthorized(c, "unauthenticated")
+			return
+		}
+		if !principal.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing scope " + scope})
+			return
+		}
+		c.Next()
+	}
+}
+
+func bearerToken(header string) (string, bool) {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="notifications"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+}
@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"serverless-notification/domain/auth"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const secret = "test-secret"
+
+func sign(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.Claims) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func validClaims() jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{Subject: "usr_123", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+}
+
+func rsaKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("marshal public key: %v", err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestNewJWTVerifier_RequiresAKey(t *testing.T) {
+	if _, err := NewJWTVerifier("", ""); !errors.Is(err, ErrNoVerificationKey) {
+		t.Fatalf("expected ErrNoVerificationKey, got %v", err)
+	}
+}
+
+func TestVerify_HS256(t *testing.T) {
+	v, err := NewJWTVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+
+	principal, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte(secret), validClaims()))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if principal.UserID != "usr_123" {
+		t.Fatalf("expected usr_123, got %s", principal.UserID)
+	}
+}
+
+func TestVerify_RS256(t *testing.T) {
+	key, publicKeyPEM := rsaKey(t)
+	v, err := NewJWTVerifier("", publicKeyPEM)
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+
+	principal, err := v.Verify(sign(t, jwt.SigningMethodRS256, key, validClaims()))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if principal.UserID != "usr_123" {
+		t.Fatalf("expected usr_123, got %s", principal.UserID)
+	}
+}
+
+func TestVerify_Scopes(t *testing.T) {
+	v, err := NewJWTVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		claims    Claims
+		scope     string
+		wantScope bool
+	}{
+		{"user writes", Claims{}, auth.ScopeNotificationsWrite, true},
+		{"user is not admin", Claims{}, auth.ScopeAPIKeysAdmin, false},
+		{"admin role", Claims{Role: auth.RoleAdmin}, auth.ScopeAPIKeysAdmin, true},
+		{"unknown role", Claims{Role: "owner"}, auth.ScopeAPIKeysAdmin, false},
+		{"explicit scope", Claims{Scope: "notifications:read api_keys:admin"}, auth.ScopeAPIKeysAdmin, true},
+		{"explicit scope replaces the role", Claims{Role: auth.RoleAdmin, Scope: "notifications:read"}, auth.ScopeNotificationsWrite, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.claims.RegisteredClaims = validClaims()
+
+			principal, err := v.Verify(sign(t, jwt.SigningMethodHS256, []byte(secret), tt.claims))
+
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if principal.HasScope(tt.scope) != tt.wantScope {
+				t.Fatalf("expected HasScope(%s) to be %v, scopes %v", tt.scope, tt.wantScope, principal.Scopes)
+			}
+		})
+	}
+}
+
+func TestVerify_Rejects(t *testing.T) {
+	key, _ := rsaKey(t)
+	v, err := NewJWTVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	expired := validClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	noExpiry := validClaims()
+	noExpiry.ExpiresAt = nil
+	noSubject := validClaims()
+	noSubject.Subject = ""
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong secret", sign(t, jwt.SigningMethodHS256, []byte("other"), validClaims())},
+		{"expired", sign(t, jwt.SigningMethodHS256, []byte(secret), expired)},
+		{"no expiry", sign(t, jwt.SigningMethodHS256, []byte(secret), noExpiry)},
+		{"no subject", sign(t, jwt.SigningMethodHS256, []byte(secret), noSubject)},
+		{"RS256 without public key", sign(t, jwt.SigningMethodRS256, key, validClaims())},
+		{"none", sign(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims())},
+		{"garbage", "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := v.Verify(tt.token); err == nil {
+				t.Fatal("expected token to be rejected")
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v, err := NewJWTVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	router := gin.New()
+	router.GET("/me", Authenticate(v, nil), func(c *gin.Context) {
+		userID, err := auth.UserID(c.Request.Context())
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, userID)
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		code   int
+	}{
+		{"valid", "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), validClaims()), http.StatusOK},
+		{"lowercase scheme", "bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), validClaims()), http.StatusOK},
+		{"missing", "", http.StatusUnauthorized},
+		{"basic", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"invalid", "Bearer not-a-token", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/me", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+			if tt.code == http.StatusOK && w.Body.String() != "usr_123" {
+				t.Fatalf("expected the subject in the context, got %q", w.Body.String())
+			}
+			if tt.code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Fatal("expected a WWW-Authenticate header")
+			}
+		})
+	}
+}
+
+type fakeKeys struct{}
+
+func (fakeKeys) Authenticate(ctx context.Context, key string) (auth.Principal, error) {
+	if key != "nk_valid" {
+		return auth.Principal{}, auth.ErrUnauthenticated
+	}
+	return auth.Principal{UserID: "usr_key", KeyID: "k1", Scopes: []string{auth.ScopeNotificationsRead}}, nil
+}
+
+func TestAuthenticate_APIKeyAndScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	v, err := NewJWTVerifier(secret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	router := gin.New()
+	router.Use(Authenticate(v, fakeKeys{}))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/read", RequireScope(auth.ScopeNotificationsRead), ok)
+	router.GET("/write", RequireScope(auth.ScopeNotificationsWrite), ok)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		code   int
+	}{
+		{"key with scope", "/read", "X-API-Key", "nk_valid", http.StatusOK},
+		{"key without scope", "/write", "X-API-Key", "nk_valid", http.StatusForbidden},
+		{"invalid key", "/read", "X-API-Key", "nk_other", http.StatusUnauthorized},
+		{"jwt has every scope", "/write", "Authorization", "Bearer " + sign(t, jwt.SigningMethodHS256, []byte(secret), validClaims()), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set(tt.header, tt.value)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.code {
+				t.Fatalf("expected %d, got %d: %s", tt.code, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/apikey"
+	"serverless-notification/domain/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyRouteHandler struct {
+	service *apikey.Service
+}
+
+func NewAPIKeyRouteHandler(service *apikey.Service) *APIKeyRouteHandler {
+	return &APIKeyRouteHandler{service: service}
+}
+
+// RegisterRoutes registers the API key admin routes, router must authenticate the caller
+func (h *APIKeyRouteHandler) RegisterRoutes(router gin.IRouter) {
+	admin := router.Group("/admin/api-keys", middleware.RequireScope(auth.ScopeAPIKeysAdmin))
+	admin.POST("", h.mintKey())
+	admin.GET("", h.listKeys())
+	admin.POST("/:id/rotate", h.rotateKey())
+	admin.DELETE("/:id", h.revokeKey())
+}
+
+// POST /admin/api-keys
+// Mint a key for the caller, the secret is only returned here
+func (h *APIKeyRouteHandler) mintKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req apikey.MintRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key, secret, err := h.service.Mint(c.Request.Context(), req)
+		if err != nil {
+			writeAPIKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+	}
+}
+
+// GET /admin/api-keys
+// List the keys of the caller, without their secrets
+func (h *APIKeyRouteHandler) listKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys, err := h.service.List(c.Request.Context())
+		if err != nil {
+			writeAPIKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+	}
+}
+
+// POST /admin/api-keys/:id/rotate
+// Mint a replacement key, the old one keeps working during the grace period
+// A key cannot rotate a key with more scopes or channels than its own (403)
+// Path Parameters:
+// - id: string (required)
+func (h *APIKeyRouteHandler) rotateKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req apikey.RotateRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		key, secret, err := h.service.Rotate(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			writeAPIKeyError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": secret})
+	}
+}
+
+// DELETE /admin/api-keys/:id
+// Revoke a key, it stops working immediately
+// Path Parameters:
+// - id: string (required)
+func (h *APIKeyRouteHandler) revokeKey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.service.Revoke(c.Request.Context(), c.Param("id")); err != nil {
+			writeAPIKeyError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// writeAPIKeyError maps API key errors to their HTTP status
+func writeAPIKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, apikey.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, apikey.ErrKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
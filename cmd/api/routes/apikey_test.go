@@ -0,0 +1,194 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	channels "serverless-notification/clients/channel"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/apikey"
+	"serverless-notification/domain/auth"
+	"serverless-notification/domain/notification"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeAPIKeyRepository struct {
+	keys map[string]*apikey.Key
+}
+
+func (r *fakeAPIKeyRepository) CreateAPIKey(ctx context.Context, k *apikey.Key) error {
+	r.keys[k.ID] = k
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKey(ctx context.Context, userID, id string) (*apikey.Key, error) {
+	k, ok := r.keys[id]
+	if !ok || k.UserID != userID {
+		return nil, apikey.ErrKeyNotFound
+	}
+	return k, nil
+}
+
+func (r *fakeAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*apikey.Key, error) {
+	for _, k := range r.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return nil, apikey.ErrKeyNotFound
+}
+
+func (r *fakeAPIKeyRepository) ListAPIKeys(ctx context.Context, userID string) ([]*apikey.Key, error) {
+	var keys []*apikey.Key
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeAPIKeyRepository) RevokeAPIKey(ctx context.Context, k *apikey.Key, at time.Time) error {
+	k.RevokedAt = &at
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) ExpireAPIKey(ctx context.Context, k *apikey.Key, at time.Time) error {
+	k.ExpiresAt = &at
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) TouchAPIKey(ctx context.Context, k *apikey.Key, at time.Time) error {
+	k.LastUsedAt = &at
+	return nil
+}
+
+// newKeyTestRouter serves the notification and API key routes, accepting JWTs and API keys
+func newKeyTestRouter(repo *fakeRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	registry := notification.NewChannelRegistry(&channels.EmailChannel{}, &channels.SMSChannel{}, &channels.PushChannel{})
+	service := notification.NewService(repo, &fakeQueue{}, registry)
+	keys := apikey.NewService(&fakeAPIKeyRepository{keys: map[string]*apikey.Key{}})
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		panic(err)
+	}
+	router := gin.New()
+	authenticated := router.Group("/", middleware.Authenticate(verifier, keys))
+	NewNotificationRouteHandler(service, registry).RegisterRoutes(authenticated)
+	NewAPIKeyRouteHandler(keys).RegisterRoutes(authenticated)
+	return router
+}
+
+// adminAuthorization is the header of usr_123 signed in with the admin role
+func adminAuthorization() []string {
+	return []string{"Authorization", bearerWithRole("usr_123", auth.RoleAdmin)}
+}
+
+func mintKey(t *testing.T, router *gin.Engine, body string) (string, string) {
+	t.Helper()
+	w := do(router, http.MethodPost, "/admin/api-keys", body, adminAuthorization()...)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			ID string `json:"id"`
+		} `json:"api_key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	return resp.APIKey.ID, resp.Key
+}
+
+func TestAPIKey_SendsWithinAllowedChannels(t *testing.T) {
+	repo := &fakeRepository{}
+	router := newKeyTestRouter(repo)
+	_, key := mintKey(t, router, `{"name":"billing","scopes":["notifications:write"],"allowed_channels":["email"]}`)
+
+	email := do(router, http.MethodPost, "/notifications", `{"title":"Hola","content":"Mundo","channel_name":"email","meta":{"to":"user@example.com"}}`, "X-API-Key", key)
+	sms := do(router, http.MethodPost, "/notifications", `{"title":"Hola","content":"Mundo","channel_name":"sms","meta":{"phone":"+1234567890","carrier":"att"}}`, "X-API-Key", key)
+
+	if email.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", email.Code, email.Body.String())
+	}
+	if sms.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", sms.Code, sms.Body.String())
+	}
+	if repo.created[0].UserID != "usr_123" {
+		t.Fatalf("expected the key owner as owner, got %s", repo.created[0].UserID)
+	}
+}
+
+func TestAPIKey_MissingScopeReturns403(t *testing.T) {
+	router := newKeyTestRouter(scheduledRepository())
+	_, key := mintKey(t, router, `{"name":"writer","scopes":["notifications:write"]}`)
+
+	if w := do(router, http.MethodGet, "/notifications/scheduled", "", "X-API-Key", key); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without notifications:read, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(router, http.MethodGet, "/admin/api-keys", "", "X-API-Key", key); w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 without api_keys:admin, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIKey_AdminRoutesRequireTheAdminRole(t *testing.T) {
+	router := newKeyTestRouter(&fakeRepository{})
+
+	routes := []struct{ method, path, body string }{
+		{http.MethodPost, "/admin/api-keys", `{"name":"k","scopes":["notifications:read"]}`},
+		{http.MethodGet, "/admin/api-keys", ""},
+		{http.MethodPost, "/admin/api-keys/k1/rotate", ""},
+		{http.MethodDelete, "/admin/api-keys/k1", ""},
+	}
+
+	for _, r := range routes {
+		if w := do(router, r.method, r.path, r.body); w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: expected 403 for a user without the admin role, got %d: %s", r.method, r.path, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestAPIKey_Revoke(t *testing.T) {
+	router := newKeyTestRouter(scheduledRepository())
+	id, key := mintKey(t, router, `{"name":"reader","scopes":["notifications:read"]}`)
+
+	if w := do(router, http.MethodGet, "/notifications/scheduled", "", "X-API-Key", key); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(router, http.MethodDelete, "/admin/api-keys/"+id, "", adminAuthorization()...); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(router, http.MethodGet, "/notifications/scheduled", "", "X-API-Key", key); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revoking, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestAPIKey_Rotate(t *testing.T) {
+	router := newKeyTestRouter(scheduledRepository())
+	id, oldKey := mintKey(t, router, `{"name":"reader","scopes":["notifications:read"]}`)
+
+	w := do(router, http.MethodPost, "/admin/api-keys/"+id+"/rotate", `{"grace_period":"0s"}`, adminAuthorization()...)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	if w := do(router, http.MethodGet, "/notifications/scheduled", "", "X-API-Key", oldKey); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected the old key to stop working, got %d", w.Code)
+	}
+	if w := do(router, http.MethodGet, "/notifications/scheduled", "", "X-API-Key", resp.Key); w.Code != http.StatusOK {
+		t.Fatalf("expected the new key to work, got %d: %s", w.Code, w.Body.String())
+	}
+}
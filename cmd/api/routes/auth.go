@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthRouteHandler struct {
+	service *user.Service
+	issuer  *middleware.JWTIssuer
+}
+
+func NewAuthRouteHandler(service *user.Service, issuer *middleware.JWTIssuer) *AuthRouteHandler {
+	return &AuthRouteHandler{service: service, issuer: issuer}
+}
+
+// RegisterRoutes registers the public authentication routes
+func (h *AuthRouteHandler) RegisterRoutes(router gin.IRouter) {
+	router.POST("/auth/signup", h.signup())
+	router.POST("/auth/login", h.login())
+}
+
+// POST /auth/signup
+// Create a user and return a token for it, 409 if the email is registered
+// and 400 if the password is over 72 bytes, the most bcrypt hashes
+func (h *AuthRouteHandler) signup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req user.SignupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		created, err := h.service.Signup(c.Request.Context(), req)
+		if err != nil {
+			writeAuthError(c, err)
+			return
+		}
+		h.writeToken(c, http.StatusCreated, created)
+	}
+}
+
+// POST /auth/login
+// Return a token for the user with the given email and password
+func (h *AuthRouteHandler) login() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req user.LoginRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		found, err := h.service.Login(c.Request.Context(), req)
+		if err != nil {
+			writeAuthError(c, err)
+			return
+		}
+		h.writeToken(c, http.StatusOK, found)
+	}
+}
+
+func (h *AuthRouteHandler) writeToken(c *gin.Context, status int, u *user.User) {
+	token, expiresAt, err := h.issuer.Issue(u.ID, u.Role)
+	if err != nil {
+		writeAuthError(c, err)
+		return
+	}
+	c.JSON(status, gin.H{
+		"token":      token,
+		"token_type": "Bearer",
+		"expires_at": expiresAt,
+		"user":       u,
+	})
+}
+
+// writeAuthError maps user errors to their HTTP status
+func writeAuthError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrPasswordTooLong):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, user.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,135 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/auth"
+	"serverless-notification/domain/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeUserRepository struct {
+	users map[string]*user.User
+}
+
+func (r *fakeUserRepository) Create(ctx context.Context, u *user.User) error {
+	if _, ok := r.users[u.Email]; ok {
+		return user.ErrEmailTaken
+	}
+	r.users[u.Email] = u
+	return nil
+}
+
+func (r *fakeUserRepository) GetByID(ctx context.Context, id string) (*user.User, error) {
+	for _, u := range r.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+	return nil, user.ErrUserNotFound
+}
+
+func (r *fakeUserRepository) GetByEmail(ctx context.Context, email string) (*user.User, error) {
+	u, ok := r.users[email]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	return u, nil
+}
+
+func newAuthTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	issuer, err := middleware.NewJWTIssuer(testSecret, "", time.Hour)
+	if err != nil {
+		t.Fatalf("NewJWTIssuer: %v", err)
+	}
+	router := gin.New()
+	service := user.NewService(&fakeUserRepository{users: map[string]*user.User{}})
+	NewAuthRouteHandler(service, issuer).RegisterRoutes(router)
+	return router
+}
+
+func TestSignup_IssuesToken(t *testing.T) {
+	router := newAuthTestRouter(t)
+
+	w := post(router, "/auth/signup", `{"email":"john@example.com","password":"correct horse"}`)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Token string         `json:"token"`
+		User  map[string]any `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		t.Fatalf("NewJWTVerifier: %v", err)
+	}
+	principal, err := verifier.Verify(body.Token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if principal.UserID != body.User["id"] {
+		t.Fatalf("expected the token subject to be the user, got %s and %v", principal.UserID, body.User["id"])
+	}
+	if principal.HasScope(auth.ScopeAPIKeysAdmin) {
+		t.Fatal("expected a signed up user not to be an admin")
+	}
+	if _, ok := body.User["password_hash"]; ok {
+		t.Fatal("expected the password hash not to be returned")
+	}
+}
+
+func TestSignup_DuplicateEmailReturns409(t *testing.T) {
+	router := newAuthTestRouter(t)
+	post(router, "/auth/signup", `{"email":"john@example.com","password":"correct horse"}`)
+
+	w := post(router, "/auth/signup", `{"email":"john@example.com","password":"another one"}`)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSignup_InvalidRequestReturns400(t *testing.T) {
+	router := newAuthTestRouter(t)
+
+	w := post(router, "/auth/signup", `{"email":"not-an-email","password":"short"}`)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestSignup_MultibytePasswordTooLongReturns400(t *testing.T) {
+	router := newAuthTestRouter(t)
+
+	w := post(router, "/auth/signup", `{"email":"john@example.com","password":"`+strings.Repeat("ñ", 40)+`"}`)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLogin(t *testing.T) {
+	router := newAuthTestRouter(t)
+	post(router, "/auth/signup", `{"email":"john@example.com","password":"correct horse"}`)
+
+	if w := post(router, "/auth/login", `{"email":"john@example.com","password":"correct horse"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post(router, "/auth/login", `{"email":"john@example.com","password":"wrong password"}`); w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", w.Code, w.Body.String())
+	}
+}
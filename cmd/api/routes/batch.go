@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/auth"
+	"serverless-notification/domain/notification"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BatchRouteHandler struct {
+	service *notification.Service
+}
+
+func NewBatchRouteHandler(service *notification.Service) *BatchRouteHandler {
+	return &BatchRouteHandler{service: service}
+}
+
+// RegisterRoutes registers the batch routes, router must authenticate the caller
+// Batches create notifications for other users, so they need their own scope,
+// only admins and the API keys they mint have it
+func (h *BatchRouteHandler) RegisterRoutes(router gin.IRouter) {
+	broadcast := middleware.RequireScope(auth.ScopeNotificationsBroadcast)
+
+	router.POST("/notifications/batch", broadcast, h.postBatch())
+	router.POST("/notifications/broadcast", broadcast, h.postBroadcast())
+	router.GET("/batches/:id", broadcast, h.getBatch())
+}
+
+// POST /notifications/batch
+// Create a notification for each item, each item names its user
+// The notifications that could not be stored or queued are listed in errors,
+// it only fails when none of them could be stored
+func (h *BatchRouteHandler) postBatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req notification.BatchRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		batch, err := h.service.CreateBatch(c.Request.Context(), req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, batch)
+	}
+}
+
+// POST /notifications/broadcast
+// Send the same notification to every user in user_ids, addressed from their contacts
+// Errors are reported like POST /notifications/batch
+func (h *BatchRouteHandler) postBroadcast() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req notification.BroadcastRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		batch, err := h.service.Broadcast(c.Request.Context(), req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, batch)
+	}
+}
+
+// GET /batches/:id
+// Get a batch of the caller with how many of its notifications are in each status
+func (h *BatchRouteHandler) getBatch() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		batch, err := h.service.GetBatch(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, batch)
+	}
+}
@@ -0,0 +1,182 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	channels "serverless-notification/clients/channel"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/notification"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeBatchStore struct {
+	batches       map[string]*notification.Batch
+	notifications []*notification.Notification
+}
+
+func (s *fakeBatchStore) CreateBatch(ctx context.Context, b *notification.Batch) error {
+	stored := *b
+	s.batches[b.ID] = &stored
+	return nil
+}
+
+func (s *fakeBatchStore) GetBatch(ctx context.Context, id string) (*notification.Batch, error) {
+	b, ok := s.batches[id]
+	if !ok {
+		return nil, notification.ErrBatchNotFound
+	}
+	stored := *b
+	return &stored, nil
+}
+
+func (s *fakeBatchStore) CreateMany(ctx context.Context, notifications []*notification.Notification) error {
+	s.notifications = append(s.notifications, notifications...)
+	return nil
+}
+
+func (s *fakeBatchStore) CountBatch(ctx context.Context, id string) (map[notification.Status]int, error) {
+	counts := map[notification.Status]int{}
+	for _, n := range s.notifications {
+		if n.BatchID == id {
+			counts[n.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *fakeBatchStore) AddUnstored(ctx context.Context, id string, count int) error {
+	s.batches[id].Unstored += count
+	return nil
+}
+
+type fakeBatchQueue struct{}
+
+func (q *fakeBatchQueue) PublishBatch(ctx context.Context, messages []*notification.DispatchMessage) map[string]error {
+	return nil
+}
+
+func newBatchTestRouter(store *fakeBatchStore) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	registry := notification.NewChannelRegistry(&channels.EmailChannel{})
+	service := notification.NewService(&fakeRepository{}, &fakeQueue{}, registry)
+	service.EnableBatches(store, &fakeBatchQueue{})
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		panic(err)
+	}
+	router := gin.New()
+	NewBatchRouteHandler(service).RegisterRoutes(router.Group("/", middleware.Authenticate(verifier, nil)))
+	return router
+}
+
+func TestPostBroadcast_CreatesBatch(t *testing.T) {
+	store := &fakeBatchStore{batches: map[string]*notification.Batch{}}
+	router := newBatchTestRouter(store)
+
+	w := do(router, http.MethodPost, "/notifications/broadcast", `{
+		"user_ids": ["usr_1", "usr_2"],
+		"title": "Maintenance",
+		"content": "Tonight at 22:00",
+		"channel_name": "email",
+		"meta": {"to": "all@example.com", "template": "titled"}
+	}`, adminAuthorization()...)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var batch notification.Batch
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if batch.UserID != "usr_123" || batch.Total != 2 || batch.Counts[notification.StatusQueued] != 2 {
+		t.Fatalf("expected a batch of 2 queued notifications, got %+v", batch)
+	}
+	if len(store.notifications) != 2 || store.notifications[0].UserID != "usr_1" || store.notifications[1].UserID != "usr_2" {
+		t.Fatalf("expected a notification per user, got %v", store.notifications)
+	}
+}
+
+func TestBatchRoutes_UserWithoutBroadcastScopeReturns403(t *testing.T) {
+	store := &fakeBatchStore{batches: map[string]*notification.Batch{"b1": {ID: "b1", UserID: "usr_123", Total: 1}}}
+	router := newBatchTestRouter(store)
+
+	routes := []struct{ method, path, body string }{
+		{http.MethodPost, "/notifications/batch", `{"notifications": [{"user_id": "usr_1", "title": "a", "content": "b", "channel_name": "email", "meta": {"to": "a@example.com"}}]}`},
+		{http.MethodPost, "/notifications/broadcast", `{"user_ids": ["usr_1"], "title": "a", "content": "b", "channel_name": "email", "meta": {"to": "a@example.com"}}`},
+		{http.MethodGet, "/batches/b1", ""},
+	}
+
+	for _, r := range routes {
+		if w := do(router, r.method, r.path, r.body); w.Code != http.StatusForbidden {
+			t.Fatalf("%s %s: expected 403 for a signed up user, got %d: %s", r.method, r.path, w.Code, w.Body.String())
+		}
+	}
+	if len(store.notifications) != 0 {
+		t.Fatalf("expected no notification to be created, got %d", len(store.notifications))
+	}
+}
+
+func TestPostBatch_MissingUserIDReturns400(t *testing.T) {
+	router := newBatchTestRouter(&fakeBatchStore{batches: map[string]*notification.Batch{}})
+
+	w := do(router, http.MethodPost, "/notifications/batch", `{"notifications": [{"title": "a", "content": "b", "channel_name": "email"}]}`, adminAuthorization()...)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPostBatch_InvalidItemReturns422(t *testing.T) {
+	store := &fakeBatchStore{batches: map[string]*notification.Batch{}}
+	router := newBatchTestRouter(store)
+
+	w := do(router, http.MethodPost, "/notifications/batch", `{"notifications": [
+		{"user_id": "usr_1", "title": "a", "content": "b", "channel_name": "fax"}
+	]}`, adminAuthorization()...)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(store.batches) != 0 {
+		t.Fatal("expected no batch to be stored")
+	}
+}
+
+func TestGetBatch_Progress(t *testing.T) {
+	store := &fakeBatchStore{
+		batches: map[string]*notification.Batch{"b1": {ID: "b1", UserID: "usr_123", Total: 3}},
+		notifications: []*notification.Notification{
+			{ID: "n1", BatchID: "b1", Status: notification.StatusDelivered},
+			{ID: "n2", BatchID: "b1", Status: notification.StatusDelivered},
+			{ID: "n3", BatchID: "b1", Status: notification.StatusFailed},
+		},
+	}
+	router := newBatchTestRouter(store)
+
+	w := do(router, http.MethodGet, "/batches/b1", "", adminAuthorization()...)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var batch notification.Batch
+	if err := json.Unmarshal(w.Body.Bytes(), &batch); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if batch.Counts[notification.StatusDelivered] != 2 || batch.Counts[notification.StatusFailed] != 1 {
+		t.Fatalf("expected 2 delivered and 1 failed, got %v", batch.Counts)
+	}
+}
+
+func TestGetBatch_OtherUserReturns404(t *testing.T) {
+	router := newBatchTestRouter(&fakeBatchStore{batches: map[string]*notification.Batch{"b1": {ID: "b1", UserID: "usr_other"}}})
+
+	w := do(router, http.MethodGet, "/batches/b1", "", adminAuthorization()...)
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
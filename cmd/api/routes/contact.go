@@ -0,0 +1,202 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/auth"
+	"serverless-notification/domain/contact"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactRouteHandler struct {
+	service *contact.Service
+}
+
+func NewContactRouteHandler(service *contact.Service) *ContactRouteHandler {
+	return &ContactRouteHandler{service: service}
+}
+
+// RegisterRoutes registers the contact book routes, router must authenticate the caller
+func (h *ContactRouteHandler) RegisterRoutes(router gin.IRouter) {
+	read := middleware.RequireScope(auth.ScopeContactsRead)
+	write := middleware.RequireScope(auth.ScopeContactsWrite)
+
+	contacts := router.Group("/contacts")
+	contacts.GET("", read, h.getContacts())
+	contacts.POST("/emails", write, h.addEmail())
+	contacts.POST("/emails/verify", write, h.verifyEmail())
+	contacts.DELETE("/emails/:address", write, h.deleteEmail())
+	contacts.PUT("/phones", write, h.putPhone())
+	contacts.DELETE("/phones/:number", write, h.deletePhone())
+	contacts.POST("/devices", write, h.registerDevice())
+	contacts.PUT("/devices/:id", write, h.updateDevice())
+	contacts.DELETE("/devices/:id", write, h.deleteDevice())
+}
+
+// GET /contacts
+// Get the emails, phones and push devices of the caller
+func (h *ContactRouteHandler) getContacts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		profile, err := h.service.Get(c.Request.Context())
+		if err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, profile)
+	}
+}
+
+// POST /contacts/emails
+// Add an email, it is used for notifications once verified with the code sent to it
+// Asking for another code within a minute of the last one, or after too many wrong codes
+// while it is still valid, returns 429
+func (h *ContactRouteHandler) addEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req contact.AddEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		email, err := h.service.AddEmail(c.Request.Context(), req)
+		if err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, email)
+	}
+}
+
+// POST /contacts/emails/verify
+// Verify an email with the code sent to it
+func (h *ContactRouteHandler) verifyEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req contact.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		email, err := h.service.VerifyEmail(c.Request.Context(), req)
+		if err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, email)
+	}
+}
+
+// DELETE /contacts/emails/:address
+// Path Parameters:
+// - address: string (required)
+func (h *ContactRouteHandler) deleteEmail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.service.DeleteEmail(c.Request.Context(), c.Param("address")); err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// PUT /contacts/phones
+// Add a phone number or change its carrier
+func (h *ContactRouteHandler) putPhone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req contact.PhoneRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		phone, err := h.service.PutPhone(c.Request.Context(), req)
+		if err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, phone)
+	}
+}
+
+// DELETE /contacts/phones/:number
+// Path Parameters:
+// - number: string (required), in E.164 format
+func (h *ContactRouteHandler) deletePhone() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.service.DeletePhone(c.Request.Context(), c.Param("number")); err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// POST /contacts/devices
+// Register a push device token, registering a known token refreshes its device
+func (h *ContactRouteHandler) registerDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req contact.DeviceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		device, err := h.service.RegisterDevice(c.Request.Context(), req)
+		if err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, device)
+	}
+}
+
+// PUT /contacts/devices/:id
+// Replace the token of a device
+// Path Parameters:
+// - id: string (required)
+func (h *ContactRouteHandler) updateDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req contact.DeviceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		device, err := h.service.UpdateDevice(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, device)
+	}
+}
+
+// DELETE /contacts/devices/:id
+// Path Parameters:
+// - id: string (required)
+func (h *ContactRouteHandler) deleteDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.service.DeleteDevice(c.Request.Context(), c.Param("id")); err != nil {
+			writeContactError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// writeContactError maps contact book errors to their HTTP status
+func writeContactError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, contact.ErrInvalidContact):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, contact.ErrContactNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, contact.ErrContactExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, contact.ErrInvalidCode):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, contact.ErrCodeThrottled):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
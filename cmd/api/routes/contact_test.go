@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/contact"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeContactRepository keeps a single contact book, routes tests only act as one user
+type fakeContactRepository struct {
+	profile contact.Profile
+}
+
+func (r *fakeContactRepository) GetProfile(ctx context.Context, userID string) (*contact.Profile, error) {
+	copied := r.profile
+	return &copied, nil
+}
+
+func (r *fakeContactRepository) PutEmail(ctx context.Context, userID string, e *contact.Email) error {
+	for i, existing := range r.profile.Emails {
+		if existing.Address == e.Address {
+			r.profile.Emails[i] = e
+			return nil
+		}
+	}
+	r.profile.Emails = append(r.profile.Emails, e)
+	return nil
+}
+
+func (r *fakeContactRepository) DeleteEmail(ctx context.Context, userID, address string) error {
+	return contact.ErrContactNotFound
+}
+
+func (r *fakeContactRepository) PutPhone(ctx context.Context, userID string, p *contact.Phone) error {
+	r.profile.Phones = append(r.profile.Phones, p)
+	return nil
+}
+
+func (r *fakeContactRepository) DeletePhone(ctx context.Context, userID, number string) error {
+	return contact.ErrContactNotFound
+}
+
+func (r *fakeContactRepository) PutDevice(ctx context.Context, userID string, d *contact.Device) error {
+	r.profile.Devices = append(r.profile.Devices, d)
+	return nil
+}
+
+func (r *fakeContactRepository) DeleteDevice(ctx context.Context, userID, id string) error {
+	return contact.ErrContactNotFound
+}
+
+type fakeCodeSender struct {
+	code string
+}
+
+func (s *fakeCodeSender) SendVerificationCode(ctx context.Context, address, code string) error {
+	s.code = code
+	return nil
+}
+
+func newContactTestRouter(sender *fakeCodeSender) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		panic(err)
+	}
+	router := gin.New()
+	service := contact.NewService(&fakeContactRepository{}, sender)
+	NewContactRouteHandler(service).RegisterRoutes(router.Group("/", middleware.Authenticate(verifier, nil)))
+	return router
+}
+
+func TestContacts_AddAndVerifyEmail(t *testing.T) {
+	sender := &fakeCodeSender{}
+	router := newContactTestRouter(sender)
+
+	if w := post(router, "/contacts/emails", `{"address":"user@example.com"}`); w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post(router, "/contacts/emails", `{"address":"user@example.com"}`); w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 for another code right away, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post(router, "/contacts/emails/verify", `{"address":"user@example.com","code":"000000x"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422 for a wrong code, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post(router, "/contacts/emails/verify", `{"address":"user@example.com","code":"`+sender.code+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w := do(router, http.MethodGet, "/contacts", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp map[string][]map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(resp["emails"]) != 1 || resp["emails"][0]["verified"] != true {
+		t.Fatalf("expected one verified email, got %s", w.Body.String())
+	}
+	if _, ok := resp["emails"][0]["code_hash"]; ok {
+		t.Fatalf("expected the code hash not to be exposed, got %s", w.Body.String())
+	}
+}
+
+func TestContacts_InvalidPhoneReturns400(t *testing.T) {
+	router := newContactTestRouter(&fakeCodeSender{})
+
+	w := do(router, http.MethodPut, "/contacts/phones", `{"number":"555-1234","carrier":"att"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestContacts_DeleteUnknownDeviceReturns404(t *testing.T) {
+	router := newContactTestRouter(&fakeCodeSender{})
+
+	w := do(router, http.MethodDelete, "/contacts/devices/missing", "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -1,7 +1,10 @@
 package routes
 
 import (
+	"errors"
 	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/auth"
 	"serverless-notification/domain/notification"
 	"strconv"
 
@@ -9,24 +12,37 @@ import (
 )
 
 type NotificationRouteHandler struct {
-	service *notification.Service
+	service  *notification.Service
+	channels *notification.ChannelRegistry
 }
 
-func NewNotificationRouteHandler(service *notification.Service) *NotificationRouteHandler {
-	return &NotificationRouteHandler{service: service}
+// NewNotificationRouteHandler creates the handler, channels provides the meta redaction policies
+func NewNotificationRouteHandler(service *notification.Service, channels *notification.ChannelRegistry) *NotificationRouteHandler {
+	return &NotificationRouteHandler{service: service, channels: channels}
 }
 
-func (h *NotificationRouteHandler) RegisterRoutes(router *gin.Engine) {
-	router.POST("/notifications", h.postNotification())
-	router.GET("/notifications/:id", h.getNotificationByID())
-	router.GET("/notifications", h.getNotificationsByUserID())
-	// router.DELETE("/notifications/:id", h.deleteNotificationByID())
-	// router.PUT("/notifications/:id", h.updateNotificationByID())
+// RegisterRoutes registers the notification routes, router must authenticate the caller
+func (h *NotificationRouteHandler) RegisterRoutes(router gin.IRouter) {
+	read := middleware.RequireScope(auth.ScopeNotificationsRead)
+	write := middleware.RequireScope(auth.ScopeNotificationsWrite)
+
+	router.POST("/notifications", write, h.postNotification())
+	router.GET("/notifications/:id", read, h.getNotificationByID())
+	router.GET("/notifications/:id/attempts", read, h.getNotificationAttempts())
+	router.GET("/notifications", read, h.getNotificationsByUserID())
+	router.DELETE("/notifications/:id", write, h.deleteNotificationByID())
+	router.PUT("/notifications/:id", write, h.updateNotificationByID())
+	router.POST("/notifications/:id/cancel", write, h.cancelNotificationByID())
 
 }
 
+// maxIdempotencyKeyLength bounds the Idempotency-Key header, it is part of a DynamoDB key
+const maxIdempotencyKeyLength = 255
+
 // POST /notifications
 // Create a new notification
+// Headers:
+// - Idempotency-Key: string (optional) / retries with the same key return the first response
 func (h *NotificationRouteHandler) postNotification() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req notification.CreateRequest
@@ -34,12 +50,17 @@ func (h *NotificationRouteHandler) postNotification() gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
-		notification, err := h.service.Create(c.Request.Context(), req)
+		key := c.GetHeader("Idempotency-Key")
+		if len(key) > maxIdempotencyKeyLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+		created, err := h.service.CreateIdempotent(c.Request.Context(), key, req)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusCreated, notification)
+		c.JSON(http.StatusCreated, h.redact(created))
 	}
 }
 
@@ -52,17 +73,81 @@ func (h *NotificationRouteHandler) getNotificationByID() gin.HandlerFunc {
 		id := c.Param("id")
 		notification, err := h.service.GetByID(c.Request.Context(), id)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, notification)
+		c.JSON(http.StatusOK, h.redact(notification))
+	}
+}
+
+// PUT /notifications/:id
+// Update a scheduled notification, 409 once it was cancelled or dispatched
+// Path Parameters:
+// - id: string (required)
+func (h *NotificationRouteHandler) updateNotificationByID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req notification.UpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updated, err := h.service.Update(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, h.redact(updated))
+	}
+}
+
+// DELETE /notifications/:id
+// Cancel and delete a scheduled notification, 409 once it was dispatched
+// Path Parameters:
+// - id: string (required)
+func (h *NotificationRouteHandler) deleteNotificationByID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.service.Delete(c.Request.Context(), c.Param("id")); err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// POST /notifications/:id/cancel
+// Cancel a notification that was not sent yet and keep it, 409 once it was dispatched
+// Path Parameters:
+// - id: string (required)
+func (h *NotificationRouteHandler) cancelNotificationByID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cancelled, err := h.service.Cancel(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, h.redact(cancelled))
+	}
+}
+
+// GET /notifications/:id/attempts
+// Get the delivery attempts of a notification, oldest first
+// Path Parameters:
+// - id: string (required)
+func (h *NotificationRouteHandler) getNotificationAttempts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		attempts, err := h.service.ListAttempts(c.Request.Context(), id)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"attempts": attempts})
 	}
 }
 
 // GET /notifications
-// Get notifications by user ID
+// Get the notifications of the authenticated user
 // Query Parameters:
-// - user_id: string (required)
 // - limit: int (optional, default: 10)
 // - next_token: string (optional) / last key from previous response
 func (h *NotificationRouteHandler) getNotificationsByUserID() gin.HandlerFunc {
@@ -72,15 +157,74 @@ func (h *NotificationRouteHandler) getNotificationsByUserID() gin.HandlerFunc {
 			limit = 10
 		}
 		query := notification.ListQuery{
-			UserID:    c.Query("user_id"),
 			Limit:     limit,
 			NextToken: c.Query("next_token"),
 		}
 		notifications, err := h.service.List(c.Request.Context(), query)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, notifications)
+		redacted := make([]*notification.Notification, len(notifications))
+		for i, n := range notifications {
+			redacted[i] = h.redact(n)
+		}
+		c.JSON(http.StatusOK, redacted)
+	}
+}
+
+// redact returns a copy of n with its meta redacted by the policy of every channel
+// it can be sent through, meta of a fallback chain holds the addresses of each one
+func (h *NotificationRouteHandler) redact(n *notification.Notification) *notification.Notification {
+	redacted := *n
+	redacted.Meta = n.Meta
+	for _, channel := range n.Channels() {
+		redacted.Meta = h.channels.RedactMeta(channel, redacted.Meta)
+	}
+	return &redacted
+}
+
+// writeError maps domain errors to their HTTP status
+func writeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrForbidden), errors.Is(err, notification.ErrChannelNotAllowed):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, notification.ErrInvalidChannel), errors.Is(err, notification.ErrInvalidSchedule):
+		c.JSON(http.StatusUnprocessableEntity, validationErrorResponse(err))
+	case errors.Is(err, notification.ErrInvalidBatch):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, notification.ErrIdempotencyKeyReused):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, notification.ErrDuplicateNotification):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, notification.ErrNotificationNotFound), errors.Is(err, notification.ErrBatchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, notification.ErrAlreadyDispatched), errors.Is(err, notification.ErrNotificationCancelled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// validationErrorResponse builds the 422 body naming every failing field
+func validationErrorResponse(err error) gin.H {
+	fields := []notification.FieldError{}
+	var validationErr *notification.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		fields = validationErr.Fields
+	case errors.Is(err, notification.ErrUnknownChannel):
+		fields = append(fields, notification.FieldError{Field: "channel_name", Message: "unknown channel"})
+	}
+
+	message := notification.ErrInvalidChannel.Error()
+	if errors.Is(err, notification.ErrInvalidSchedule) {
+		message = notification.ErrInvalidSchedule.Error()
+	}
+	return gin.H{
+		"error":  message,
+		"fields": fields,
 	}
 }
@@ -0,0 +1,389 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	channels "serverless-notification/clients/channel"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/notification"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type fakeRepository struct {
+	notification.Repository
+	created []*notification.Notification
+	stored  map[string]*notification.Notification
+	deleted []string
+	keys    map[string]*notification.IdempotencyRecord
+}
+
+func (r *fakeRepository) ReserveIdempotencyKey(ctx context.Context, record *notification.IdempotencyRecord) (*notification.IdempotencyRecord, error) {
+	if existing, ok := r.keys[record.Key]; ok {
+		return existing, notification.ErrDuplicateNotification
+	}
+	if r.keys == nil {
+		r.keys = map[string]*notification.IdempotencyRecord{}
+	}
+	r.keys[record.Key] = record
+	return nil, nil
+}
+
+func (r *fakeRepository) CompleteIdempotencyKey(ctx context.Context, record *notification.IdempotencyRecord) error {
+	r.keys[record.Key] = record
+	return nil
+}
+
+func (r *fakeRepository) ReleaseIdempotencyKey(ctx context.Context, record *notification.IdempotencyRecord) error {
+	delete(r.keys, record.Key)
+	return nil
+}
+
+func (r *fakeRepository) Create(ctx context.Context, n *notification.Notification) error {
+	r.created = append(r.created, n)
+	return nil
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, id string) (*notification.Notification, error) {
+	n, ok := r.stored[id]
+	if !ok {
+		return nil, notification.ErrNotificationNotFound
+	}
+	copied := *n
+	return &copied, nil
+}
+
+func (r *fakeRepository) Update(ctx context.Context, n *notification.Notification, updates map[string]interface{}) error {
+	r.stored[n.ID] = n
+	return nil
+}
+
+func (r *fakeRepository) Delete(ctx context.Context, id string) error {
+	r.deleted = append(r.deleted, id)
+	return nil
+}
+
+func (r *fakeRepository) UpdateStatus(ctx context.Context, n *notification.Notification, change notification.StatusChange) error {
+	return nil
+}
+
+type fakeQueue struct{}
+
+func (q *fakeQueue) Publish(ctx context.Context, message *notification.DispatchMessage) error {
+	return nil
+}
+
+const testSecret = "test-secret"
+
+func newTestRouter(repo *fakeRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	registry := notification.NewChannelRegistry(&channels.EmailChannel{}, &channels.SMSChannel{}, &channels.PushChannel{})
+	service := notification.NewService(repo, &fakeQueue{}, registry)
+	service.EnableIdempotency(repo)
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		panic(err)
+	}
+	router := gin.New()
+	NewNotificationRouteHandler(service, registry).RegisterRoutes(router.Group("/", middleware.Authenticate(verifier, nil)))
+	return router
+}
+
+// bearer returns an Authorization header value for userID
+func bearer(userID string) string {
+	return bearerWithRole(userID, "")
+}
+
+func bearerWithRole(userID, role string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, middleware.Claims{
+		Role: role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		panic(err)
+	}
+	return "Bearer " + token
+}
+
+func post(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+	return do(router, http.MethodPost, path, body)
+}
+
+func do(router *gin.Engine, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", bearer("usr_123"))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestPostNotification_Created(t *testing.T) {
+	repo := &fakeRepository{}
+	router := newTestRouter(repo)
+
+	w := post(router, "/notifications", `{"title":"Hola","content":"Mundo","channel_name":"email","meta":{"to":"user@example.com"}}`)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.created) != 1 {
+		t.Fatalf("expected 1 stored notification, got %d", len(repo.created))
+	}
+}
+
+func TestPostNotification_InvalidMetaReturns422(t *testing.T) {
+	repo := &fakeRepository{}
+	router := newTestRouter(repo)
+
+	w := post(router, "/notifications", `{"title":"Hola","content":"Mundo","channel_name":"sms","meta":{"phone":"12345"}}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Error  string                    `json:"error"`
+		Fields []notification.FieldError `json:"fields"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(body.Fields) != 2 || body.Fields[0].Field != "phone" || body.Fields[1].Field != "carrier" {
+		t.Fatalf("expected phone and carrier fields, got %+v", body.Fields)
+	}
+	if len(repo.created) != 0 {
+		t.Fatal("expected nothing to be stored")
+	}
+}
+
+func TestPostNotification_UnknownChannelReturns422(t *testing.T) {
+	router := newTestRouter(&fakeRepository{})
+
+	w := post(router, "/notifications", `{"title":"Hola","content":"Mundo","channel_name":"fax"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"channel_name"`) {
+		t.Fatalf("expected channel_name field in body, got %s", w.Body.String())
+	}
+}
+
+func scheduledRepository() *fakeRepository {
+	return &fakeRepository{stored: map[string]*notification.Notification{
+		"scheduled": {ID: "scheduled", UserID: "usr_123", ChannelName: "email", Status: notification.StatusScheduled, Meta: map[string]string{"to": "old@example.com"}},
+		"delivered": {ID: "delivered", UserID: "usr_123", ChannelName: "email", Status: notification.StatusDelivered},
+	}}
+}
+
+func TestPutNotification_UpdatesScheduled(t *testing.T) {
+	repo := scheduledRepository()
+	router := newTestRouter(repo)
+
+	w := do(router, http.MethodPut, "/notifications/scheduled", `{"title":"Nuevo","meta":{"to":"new@example.com"}}`)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if repo.stored["scheduled"].Title != "Nuevo" || repo.stored["scheduled"].Meta["to"] != "new@example.com" {
+		t.Fatalf("expected title and meta to be saved, got %+v", repo.stored["scheduled"])
+	}
+}
+
+func TestPutNotification_DispatchedReturns409(t *testing.T) {
+	router := newTestRouter(scheduledRepository())
+
+	w := do(router, http.MethodPut, "/notifications/delivered", `{"title":"Nuevo"}`)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPutNotification_CancelledReturns409(t *testing.T) {
+	repo := scheduledRepository()
+	repo.stored["cancelled"] = &notification.Notification{ID: "cancelled", UserID: "usr_123", ChannelName: "email", Title: "Viejo", Status: notification.StatusCancelled}
+	router := newTestRouter(repo)
+
+	w := do(router, http.MethodPut, "/notifications/cancelled", `{"title":"Nuevo"}`)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if repo.stored["cancelled"].Title != "Viejo" {
+		t.Fatalf("expected the cancelled notification not to change, got %q", repo.stored["cancelled"].Title)
+	}
+}
+
+func TestPutNotification_InvalidMetaReturns422(t *testing.T) {
+	router := newTestRouter(scheduledRepository())
+
+	w := do(router, http.MethodPut, "/notifications/scheduled", `{"meta":{"to":"not-an-email"}}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCancelNotification(t *testing.T) {
+	repo := scheduledRepository()
+	router := newTestRouter(repo)
+
+	w := post(router, "/notifications/scheduled/cancel", "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Status notification.Status `json:"status"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Status != notification.StatusCancelled {
+		t.Fatalf("expected the cancelled notification, got %s", w.Body.String())
+	}
+	if w := post(router, "/notifications/delivered/cancel", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 for a delivered notification, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeleteNotification(t *testing.T) {
+	repo := scheduledRepository()
+	router := newTestRouter(repo)
+
+	if w := do(router, http.MethodDelete, "/notifications/scheduled", ""); w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.deleted) != 1 || repo.deleted[0] != "scheduled" {
+		t.Fatalf("expected scheduled to be deleted, got %v", repo.deleted)
+	}
+	if w := do(router, http.MethodDelete, "/notifications/delivered", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(router, http.MethodDelete, "/notifications/missing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetNotification_RedactsMeta(t *testing.T) {
+	repo := &fakeRepository{stored: map[string]*notification.Notification{
+		"n1": {ID: "n1", UserID: "usr_123", ChannelName: "sms", Status: notification.StatusDelivered, Meta: map[string]string{"phone": "+1234567890", "carrier": "att"}},
+	}}
+	router := newTestRouter(repo)
+
+	w := do(router, http.MethodGet, "/notifications/n1", "")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body struct {
+		Meta map[string]string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if body.Meta["phone"] != "+12*****890" || body.Meta["carrier"] != "att" {
+		t.Fatalf("expected redacted meta, got %v", body.Meta)
+	}
+	if repo.stored["n1"].Meta["phone"] != "+1234567890" {
+		t.Fatal("expected stored meta to stay unredacted")
+	}
+}
+
+func TestGetNotification_RedactsMetaOfEveryFallback(t *testing.T) {
+	repo := &fakeRepository{stored: map[string]*notification.Notification{
+		"n1": {ID: "n1", UserID: "usr_123", ChannelName: "sms", Fallback: []string{"email"}, Status: notification.StatusDelivered,
+			Meta: map[string]string{"phone": "+1234567890", "carrier": "att", "to": "john@example.com", "attachments": `[{"url":"https://files.example.com/a.pdf?sig=secret"},{"content":"aGVsbG8="}]`}},
+	}}
+	router := newTestRouter(repo)
+
+	w := do(router, http.MethodGet, "/notifications/n1", "")
+
+	var body struct {
+		Meta map[string]string
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if body.Meta["phone"] != "+12*****890" || body.Meta["to"] != "j***@example.com" {
+		t.Fatalf("expected meta redacted by both channels, got %v", body.Meta)
+	}
+	if body.Meta["attachments"] != `[{"url":"https://files.example.com/***"},{}]` {
+		t.Fatalf("expected attachment URLs masked and content left out, got %s", body.Meta["attachments"])
+	}
+}
+
+func TestPostNotification_IdempotencyKeyReplay(t *testing.T) {
+	repo := &fakeRepository{}
+	router := newTestRouter(repo)
+	body := `{"title":"Hola","content":"Mundo","channel_name":"email","meta":{"to":"user@example.com"}}`
+
+	first := do(router, http.MethodPost, "/notifications", body, "Idempotency-Key", "abc")
+	replay := do(router, http.MethodPost, "/notifications", body, "Idempotency-Key", "abc")
+
+	if first.Code != http.StatusCreated || replay.Code != http.StatusCreated {
+		t.Fatalf("expected 201 twice, got %d and %d: %s", first.Code, replay.Code, replay.Body.String())
+	}
+	if replay.Body.String() != first.Body.String() {
+		t.Fatalf("expected the original body on replay\nfirst:  %s\nreplay: %s", first.Body.String(), replay.Body.String())
+	}
+	if len(repo.created) != 1 {
+		t.Fatalf("expected 1 stored notification, got %d", len(repo.created))
+	}
+}
+
+func TestPostNotification_IdempotencyKeyDifferentPayloadReturns422(t *testing.T) {
+	router := newTestRouter(&fakeRepository{})
+
+	do(router, http.MethodPost, "/notifications", `{"title":"Hola","content":"Mundo","channel_name":"email","meta":{"to":"user@example.com"}}`, "Idempotency-Key", "abc")
+	w := do(router, http.MethodPost, "/notifications", `{"title":"Hola","content":"Otro","channel_name":"email","meta":{"to":"user@example.com"}}`, "Idempotency-Key", "abc")
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestGetNotification_OtherUserReturns404(t *testing.T) {
+	router := newTestRouter(scheduledRepository())
+
+	w := do(router, http.MethodGet, "/notifications/scheduled", "", "Authorization", bearer("usr_456"))
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestNotifications_MissingTokenReturns401(t *testing.T) {
+	router := newTestRouter(scheduledRepository())
+
+	w := do(router, http.MethodGet, "/notifications/scheduled", "", "Authorization", "")
+
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPostNotification_UserFromToken(t *testing.T) {
+	repo := &fakeRepository{}
+	router := newTestRouter(repo)
+
+	w := post(router, "/notifications", `{"user_id":"usr_456","title":"Hola","content":"Mundo","channel_name":"email","meta":{"to":"user@example.com"}}`)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if repo.created[0].UserID != "usr_123" {
+		t.Fatalf("expected the token subject as owner, got %s", repo.created[0].UserID)
+	}
+}
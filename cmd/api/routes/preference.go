@@ -0,0 +1,68 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/auth"
+	"serverless-notification/domain/preference"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PreferenceRouteHandler struct {
+	service *preference.Service
+}
+
+func NewPreferenceRouteHandler(service *preference.Service) *PreferenceRouteHandler {
+	return &PreferenceRouteHandler{service: service}
+}
+
+// RegisterRoutes registers the preference routes, router must authenticate the caller
+func (h *PreferenceRouteHandler) RegisterRoutes(router gin.IRouter) {
+	router.GET("/users/me/preferences", middleware.RequireScope(auth.ScopePreferencesRead), h.getPreferences())
+	router.PUT("/users/me/preferences", middleware.RequireScope(auth.ScopePreferencesWrite), h.putPreferences())
+}
+
+// GET /users/me/preferences
+// Get the opt-outs of the caller
+func (h *PreferenceRouteHandler) getPreferences() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		preferences, err := h.service.Get(c.Request.Context())
+		if err != nil {
+			writePreferenceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, preferences)
+	}
+}
+
+// PUT /users/me/preferences
+// Replace the opt-outs of the caller, notifications they block are suppressed instead of sent
+func (h *PreferenceRouteHandler) putPreferences() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req preference.UpdateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		preferences, err := h.service.Put(c.Request.Context(), req)
+		if err != nil {
+			writePreferenceError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, preferences)
+	}
+}
+
+// writePreferenceError maps preference errors to their HTTP status
+func writePreferenceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrUnauthenticated):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, preference.ErrInvalidPreferences):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/preference"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakePreferenceRepository struct {
+	preferences *preference.Preferences
+}
+
+func (r *fakePreferenceRepository) GetPreferences(ctx context.Context, userID string) (*preference.Preferences, error) {
+	if r.preferences == nil {
+		return &preference.Preferences{OptOuts: []preference.OptOut{}}, nil
+	}
+	return r.preferences, nil
+}
+
+func (r *fakePreferenceRepository) PutPreferences(ctx context.Context, userID string, p *preference.Preferences) error {
+	r.preferences = p
+	return nil
+}
+
+func newPreferenceTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		panic(err)
+	}
+	router := gin.New()
+	service := preference.NewService(&fakePreferenceRepository{})
+	NewPreferenceRouteHandler(service).RegisterRoutes(router.Group("/", middleware.Authenticate(verifier, nil)))
+	return router
+}
+
+func TestPreferences_PutThenGet(t *testing.T) {
+	router := newPreferenceTestRouter()
+
+	if w := do(router, http.MethodGet, "/users/me/preferences", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"opt_outs":[]`) {
+		t.Fatalf("expected empty preferences, got %d: %s", w.Code, w.Body.String())
+	}
+	w := do(router, http.MethodPut, "/users/me/preferences", `{"opt_outs":[{"channel":"sms","category":"marketing"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = do(router, http.MethodGet, "/users/me/preferences", "")
+	if !strings.Contains(w.Body.String(), `{"channel":"sms","category":"marketing"}`) {
+		t.Fatalf("expected the saved opt-out, got %s", w.Body.String())
+	}
+}
+
+func TestPreferences_InvalidOptOutReturns400(t *testing.T) {
+	router := newPreferenceTestRouter()
+
+	w := do(router, http.MethodPut, "/users/me/preferences", `{"opt_outs":[{}]}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+}
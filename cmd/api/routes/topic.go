@@ -0,0 +1,138 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/auth"
+	"serverless-notification/domain/notification"
+	"serverless-notification/domain/topic"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TopicRouteHandler struct {
+	topics        *topic.Service
+	notifications *notification.Service
+}
+
+// NewTopicRouteHandler creates the handler, notifications sends what is published to a topic
+func NewTopicRouteHandler(topics *topic.Service, notifications *notification.Service) *TopicRouteHandler {
+	return &TopicRouteHandler{topics: topics, notifications: notifications}
+}
+
+// RegisterRoutes registers the topic routes, router must authenticate the caller
+func (h *TopicRouteHandler) RegisterRoutes(router gin.IRouter) {
+	read := middleware.RequireScope(auth.ScopeTopicsRead)
+	write := middleware.RequireScope(auth.ScopeTopicsWrite)
+
+	router.POST("/topics", write, h.postTopic())
+	router.GET("/topics", read, h.getTopics())
+	router.DELETE("/topics/:id", write, h.deleteTopic())
+	router.PUT("/topics/:id/subscription", write, h.putSubscription())
+	router.DELETE("/topics/:id/subscription", write, h.deleteSubscription())
+	router.POST("/topics/:id/publish", middleware.RequireScope(auth.ScopeNotificationsBroadcast), h.publish())
+}
+
+// POST /topics
+// Create a topic owned by the caller
+func (h *TopicRouteHandler) postTopic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req topic.CreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		created, err := h.topics.Create(c.Request.Context(), req)
+		if err != nil {
+			writeTopicError(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+	}
+}
+
+// GET /topics
+// List every topic
+func (h *TopicRouteHandler) getTopics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		topics, err := h.topics.List(c.Request.Context())
+		if err != nil {
+			writeTopicError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"topics": topics})
+	}
+}
+
+// DELETE /topics/:id
+// Delete a topic of the caller and its subscriptions
+func (h *TopicRouteHandler) deleteTopic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.topics.Delete(c.Request.Context(), c.Param("id")); err != nil {
+			writeTopicError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// PUT /topics/:id/subscription
+// Subscribe the caller to a topic
+func (h *TopicRouteHandler) putSubscription() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subscription, err := h.topics.Subscribe(c.Request.Context(), c.Param("id"))
+		if err != nil {
+			writeTopicError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, subscription)
+	}
+}
+
+// DELETE /topics/:id/subscription
+// Unsubscribe the caller from a topic
+func (h *TopicRouteHandler) deleteSubscription() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := h.topics.Unsubscribe(c.Request.Context(), c.Param("id")); err != nil {
+			writeTopicError(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// POST /topics/:id/publish
+// Send a notification to every subscriber of a topic of the caller
+// Body: the fields of POST /notifications
+// Returns 202 with the counts by status, the notifications that could not be stored or
+// queued are listed in errors. It only fails when none of them could be published
+func (h *TopicRouteHandler) publish() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req notification.CreateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		publication, err := h.notifications.PublishTopic(c.Request.Context(), c.Param("id"), req)
+		if err != nil {
+			writeTopicError(c, err)
+			return
+		}
+		c.JSON(http.StatusAccepted, publication)
+	}
+}
+
+// writeTopicError maps topic errors to their HTTP status, the rest are notification errors
+func writeTopicError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, topic.ErrInvalidTopic):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, topic.ErrTopicNotFound), errors.Is(err, topic.ErrNotSubscribed):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, topic.ErrTopicExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		writeError(c, err)
+	}
+}
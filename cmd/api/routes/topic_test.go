@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	channels "serverless-notification/clients/channel"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/notification"
+	"serverless-notification/domain/topic"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeTopicRepository struct {
+	topic.Repository
+	topics      map[string]*topic.Topic
+	subscribers []string
+}
+
+func (r *fakeTopicRepository) CreateTopic(ctx context.Context, t *topic.Topic) error {
+	if _, ok := r.topics[t.ID]; ok {
+		return topic.ErrTopicExists
+	}
+	r.topics[t.ID] = t
+	return nil
+}
+
+func (r *fakeTopicRepository) GetTopic(ctx context.Context, id string) (*topic.Topic, error) {
+	t, ok := r.topics[id]
+	if !ok {
+		return nil, topic.ErrTopicNotFound
+	}
+	return t, nil
+}
+
+func (r *fakeTopicRepository) ListSubscribers(ctx context.Context, topicID string) ([]string, error) {
+	return r.subscribers, nil
+}
+
+func newTopicTestRouter(topics *fakeTopicRepository, repo *fakeRepository) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	topicService := topic.NewService(topics)
+	service := notification.NewService(repo, &fakeQueue{}, notification.NewChannelRegistry(&channels.EmailChannel{}))
+	service.EnableTopics(topicService)
+	verifier, err := middleware.NewJWTVerifier(testSecret, "")
+	if err != nil {
+		panic(err)
+	}
+	router := gin.New()
+	NewTopicRouteHandler(topicService, service).RegisterRoutes(router.Group("/", middleware.Authenticate(verifier, nil)))
+	return router
+}
+
+func TestPostTopic_Created(t *testing.T) {
+	topics := &fakeTopicRepository{topics: map[string]*topic.Topic{}}
+	router := newTopicTestRouter(topics, &fakeRepository{})
+
+	w := post(router, "/topics", `{"id": "order-updates", "name": "Order updates"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if topics.topics["order-updates"].OwnerID != "usr_123" {
+		t.Fatalf("expected topic owned by the caller, got %+v", topics.topics["order-updates"])
+	}
+
+	w = post(router, "/topics", `{"id": "order-updates", "name": "Again"}`)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPutSubscription_UnknownTopicReturns404(t *testing.T) {
+	router := newTopicTestRouter(&fakeTopicRepository{topics: map[string]*topic.Topic{}}, &fakeRepository{})
+
+	w := do(router, http.MethodPut, "/topics/status-page/subscription", "")
+
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPublish_SendsToSubscribers(t *testing.T) {
+	topics := &fakeTopicRepository{
+		topics:      map[string]*topic.Topic{"order-updates": {ID: "order-updates", OwnerID: "usr_123"}},
+		subscribers: []string{"usr_1", "usr_2"},
+	}
+	repo := &fakeRepository{}
+	router := newTopicTestRouter(topics, repo)
+
+	w := do(router, http.MethodPost, "/topics/order-updates/publish", `{"title": "Shipped", "content": "On its way", "channel_name": "email", "meta": {"to": "orders@example.com"}}`, adminAuthorization()...)
+
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.created) != 2 || repo.created[0].UserID != "usr_1" || repo.created[1].Topic != "order-updates" {
+		t.Fatalf("expected a notification per subscriber, got %v", repo.created)
+	}
+}
+
+func TestPublish_OtherOwnerReturns403(t *testing.T) {
+	topics := &fakeTopicRepository{topics: map[string]*topic.Topic{"order-updates": {ID: "order-updates", OwnerID: "usr_other"}}}
+	router := newTopicTestRouter(topics, &fakeRepository{})
+
+	w := post(router, "/topics/order-updates/publish", `{"title": "a", "content": "b", "channel_name": "email", "meta": {"to": "x@example.com"}}`)
+
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -2,32 +2,76 @@ package cmd
 
 import (
 	"context"
+	"net/mail"
 	"os"
 	"serverless-notification/adapters/dynamodb"
 	"serverless-notification/clients"
+	channels "serverless-notification/clients/channel"
+	"serverless-notification/clients/smtp"
+	"serverless-notification/cmd/api/middleware"
+	"serverless-notification/domain/apikey"
+	"serverless-notification/domain/contact"
 	"serverless-notification/domain/notification"
+	"serverless-notification/domain/preference"
+	"serverless-notification/domain/topic"
+	"serverless-notification/domain/user"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	awsDynamodb "github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 )
 
-// InitDependencies initializes all dependencies and returns a configured Service
-func InitDependencies() *notification.Service {
+// Dependencies are the services and channels of the API and the workers
+// They share one AWS config and one email channel
+type Dependencies struct {
+	Service     *notification.Service
+	Channels    *notification.ChannelRegistry
+	Users       *user.Service
+	APIKeys     *apikey.Service
+	Contacts    *contact.Service
+	Preferences *preference.Service
+	Topics      *topic.Service
+}
+
+// InitDependencies initializes all dependencies from the environment
+func InitDependencies() *Dependencies {
 	cfg := loadAWSConfig()
 
 	dynamoClient := awsDynamodb.NewFromConfig(cfg)
 	sqsClient := sqs.NewFromConfig(cfg)
 
 	notificationRepo := dynamodb.NewNotificationRepository(dynamoClient, os.Getenv("NOTIFICATIONS_TABLE"))
+	userRepo := dynamodb.NewUserRepository(dynamoClient, os.Getenv("USERS_TABLE"))
 	queue := clients.NewSQSClient(sqsClient, os.Getenv("SQS_QUEUE_URL"))
 
-	validator := &noopValidator{}
+	email := newEmailChannel(cfg, notificationRepo)
+	deps := &Dependencies{
+		Channels: newChannelRegistry(email),
+		Users:    user.NewService(userRepo),
+		APIKeys:  apikey.NewService(userRepo),
+		// Verification codes are sent through the email channel
+		Contacts:    contact.NewService(userRepo, email),
+		Preferences: preference.NewService(userRepo),
+		Topics:      topic.NewService(notificationRepo),
+	}
 
-	service := notification.NewService(notificationRepo, queue, validator)
+	service := notification.NewService(notificationRepo, queue, deps.Channels)
+	service.EnableIdempotency(notificationRepo)
+	service.EnableBatches(notificationRepo, queue)
+	if os.Getenv("OUTBOX_ENABLED") == "true" {
+		service.EnableOutbox(notificationRepo)
+	}
+	service.EnableContactBook(deps.Contacts)
+	service.EnablePreferences(deps.Preferences)
+	service.EnableTopics(deps.Topics)
+	deps.Service = service
 
-	return service
+	return deps
 }
 
 func loadAWSConfig() aws.Config {
@@ -46,9 +90,116 @@ func loadAWSConfig() aws.Config {
 	return cfg
 }
 
-// dummy validator that always returns nil
-type noopValidator struct{}
+// newChannelRegistry returns a registry with every available channel
+// New channels only need to be registered here
+func newChannelRegistry(email *channels.EmailChannel) *notification.ChannelRegistry {
+	return notification.NewChannelRegistry(
+		email,
+		&channels.SMSChannel{},
+		&channels.PushChannel{},
+	)
+}
+
+// newEmailChannel returns the email channel sending from EMAIL_FROM
+// EMAIL_SENDER_DOMAINS (comma-separated) are the verified domains meta["from"] may send from
+// EMAIL_TEMPLATES_DIR adds the <name>.txt.tmpl and <name>.html.tmpl files of a directory to the
+// built-in templates, EMAIL_TEMPLATES_FROM_DB=true adds the ones of templates, the notifications table
+// ATTACHMENTS_BUCKET lets attachments reference objects of that S3 bucket by key
+// SMTP_HOST enables the SMTP transport, without it emails are printed to stdout
+// SMTP_PORT is 587 by default, SMTP_SECURITY is starttls (default), tls or none,
+// SMTP_AUTH is plain (default) or login and only used with SMTP_USERNAME,
+// SMTP_TIMEOUT bounds the dial and every command, 10s by default
+func newEmailChannel(cfg aws.Config, templates notification.TemplateStore) *channels.EmailChannel {
+	from := os.Getenv("EMAIL_FROM")
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return configureEmailChannel(channels.NewEmailChannel(from, nil), cfg, templates)
+	}
+	if _, err := mail.ParseAddress(from); err != nil {
+		panic("invalid EMAIL_FROM: " + err.Error())
+	}
+
+	port := 587
+	if s := os.Getenv("SMTP_PORT"); s != "" {
+		parsed, err := strconv.Atoi(s)
+		if err != nil {
+			panic("invalid SMTP_PORT: " + err.Error())
+		}
+		port = parsed
+	}
+	var timeout time.Duration
+	if s := os.Getenv("SMTP_TIMEOUT"); s != "" {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			panic("invalid SMTP_TIMEOUT: " + err.Error())
+		}
+		timeout = parsed
+	}
+	client, err := smtp.NewClient(smtp.Config{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Security: smtp.Security(os.Getenv("SMTP_SECURITY")),
+		Auth:     os.Getenv("SMTP_AUTH"),
+		Timeout:  timeout,
+	})
+	if err != nil {
+		panic("failed to configure SMTP: " + err.Error())
+	}
+	return configureEmailChannel(channels.NewEmailChannel(from, client), cfg, templates)
+}
+
+// configureEmailChannel enables the optional features of channel from the environment
+func configureEmailChannel(channel *channels.EmailChannel, cfg aws.Config, store notification.TemplateStore) *channels.EmailChannel {
+	if domains := os.Getenv("EMAIL_SENDER_DOMAINS"); domains != "" {
+		channel.AllowSenderDomains(strings.Split(domains, ",")...)
+	}
+	templates := channels.NewTemplateRegistry()
+	if dir := os.Getenv("EMAIL_TEMPLATES_DIR"); dir != "" {
+		if err := templates.LoadDir(dir); err != nil {
+			panic("failed to load email templates: " + err.Error())
+		}
+	}
+	// Like the directory, a stored template that cannot be loaded fails startup, otherwise
+	// the notifications naming it would be rejected as an unknown template
+	if os.Getenv("EMAIL_TEMPLATES_FROM_DB") == "true" {
+		if err := templates.LoadStore(context.TODO(), store); err != nil {
+			panic("failed to load email templates: " + err.Error())
+		}
+	}
+	channel.UseTemplates(templates)
+	if bucket := os.Getenv("ATTACHMENTS_BUCKET"); bucket != "" {
+		channel.EnableAttachmentStore(clients.NewS3Client(s3.NewFromConfig(cfg), bucket))
+	}
+	return channel
+}
+
+// InitJWTVerifier returns the verifier of API bearer tokens
+// JWT_SECRET enables HS256 and JWT_PUBLIC_KEY (PEM) enables RS256, at least one is required
+func InitJWTVerifier() *middleware.JWTVerifier {
+	verifier, err := middleware.NewJWTVerifier(os.Getenv("JWT_SECRET"), os.Getenv("JWT_PUBLIC_KEY"))
+	if err != nil {
+		panic("failed to configure JWT: " + err.Error())
+	}
+	return verifier
+}
 
-func (v *noopValidator) Validate(channelName string, meta map[string]string) error {
-	return nil
+// InitJWTIssuer returns the issuer of the tokens returned by signup and login
+// JWT_PRIVATE_KEY (PEM) signs RS256 tokens, otherwise JWT_SECRET signs HS256 tokens
+// JWT_TTL is how long tokens are valid, one hour by default
+func InitJWTIssuer() *middleware.JWTIssuer {
+	ttl := time.Hour
+	if s := os.Getenv("JWT_TTL"); s != "" {
+		parsed, err := time.ParseDuration(s)
+		if err != nil {
+			panic("invalid JWT_TTL: " + err.Error())
+		}
+		ttl = parsed
+	}
+	issuer, err := middleware.NewJWTIssuer(os.Getenv("JWT_SECRET"), os.Getenv("JWT_PRIVATE_KEY"), ttl)
+	if err != nil {
+		panic("failed to configure JWT: " + err.Error())
+	}
+	return issuer
 }
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"serverless-notification/domain/notification"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Tracker records the delivery progress of a notification
+// and holds it back during the quiet hours of its user
+type Tracker interface {
+	MarkSending(ctx context.Context, id string, redelivered bool) error
+	UpdateStatus(ctx context.Context, id string, status notification.Status, reason string) error
+	RecordAttempt(ctx context.Context, attempt *notification.Attempt) error
+	DeferForQuietHours(ctx context.Context, message *notification.DispatchMessage, channelName string, retry int, now time.Time) (*time.Time, error)
+	RecordChannelPath(ctx context.Context, id, deliveredChannel string, steps []notification.ChannelStep) error
+}
+
+// Handler consumes DispatchMessages from SQS and sends them through their channel
+type Handler struct {
+	channels *notification.ChannelRegistry
+	tracker  Tracker
+}
+
+// NewHandler creates a new Handler routing by channel name
+func NewHandler(channels *notification.ChannelRegistry, tracker Tracker) *Handler {
+	return &Handler{channels: channels, tracker: tracker}
+}
+
+// Handle processes every record of the batch and reports only the failed ones,
+// so SQS redelivers those and deletes the rest
+// Channel connections are shared by the records of a batch and closed at the end,
+// the container may be frozen for long after it returns
+func (h *Handler) Handle(ctx context.Context, event events.SQSEvent) (events.SQSEventResponse, error) {
+	defer func() {
+		if err := h.channels.Close(); err != nil {
+			log.Printf("Failed to close channels: %v", err)
+		}
+	}()
+	failures := []events.SQSBatchItemFailure{}
+	for _, record := range event.Records {
+		if err := h.process(ctx, record); err != nil {
+			log.Printf("Failed to process message %s: %v", record.MessageId, err)
+			failures = append(failures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+	return events.SQSEventResponse{BatchItemFailures: failures}, nil
+}
+
+func (h *Handler) process(ctx context.Context, record events.SQSMessage) error {
+	var dispatch notification.DispatchMessage
+	if err := json.Unmarshal([]byte(record.Body), &dispatch); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+
+	// Retries continue from the deliveries of the publishes before a deferral
+	retry := dispatch.RetryOffset + retryNumber(record)
+
+	// The scheduler publishes it again once the quiet hours end
+	deferredTo, err := h.tracker.DeferForQuietHours(ctx, &dispatch, dispatch.ChannelName, retry, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to defer: %w", err)
+	}
+	if deferredTo != nil {
+		log.Printf("Deferring notification %s to %s, quiet hours", dispatch.NotificationID, deferredTo.Format(time.RFC3339))
+		return nil
+	}
+
+	// A notification that was cancelled or already delivered is dropped, not retried
+	// One left in sending is only taken over by a redelivery, the last receive stopped midway
+	if err := h.tracker.MarkSending(ctx, dispatch.NotificationID, retryNumber(record) > 0); err != nil {
+		if errors.Is(err, notification.ErrInvalidTransition) {
+			log.Printf("Skipping notification %s: %v", dispatch.NotificationID, err)
+			return nil
+		}
+		return fmt.Errorf("failed to mark as sending: %w", err)
+	}
+
+	// Channels are tried in order until one delivers it, the path is kept for chains
+	channels := dispatch.Channels()
+	var steps []notification.ChannelStep
+	var delivered string
+	for step, channelName := range channels {
+		// Falling back to a channel inside the quiet hours holds the notification back instead,
+		// the chain is tried again once they end. Attempts of this retry were recorded already
+		if step > 0 {
+			deferredTo, err = h.tracker.DeferForQuietHours(ctx, &dispatch, channelName, retry+1, time.Now())
+			if err != nil {
+				err = fmt.Errorf("failed to defer: %w", err)
+				steps = append(steps, notification.ChannelStep{Channel: channelName, At: time.Now(), Error: err.Error()})
+				break
+			}
+			if deferredTo != nil {
+				break
+			}
+		}
+
+		attempt := &notification.Attempt{
+			NotificationID: dispatch.NotificationID,
+			UserID:         dispatch.UserID,
+			Retry:          retry,
+			Channel:        channelName,
+			Step:           step,
+			StartedAt:      time.Now(),
+		}
+		var receipt notification.Receipt
+		receipt, err = h.send(ctx, channelName, dispatch)
+		attempt.EndedAt = time.Now()
+		attempt.Provider = receipt.Provider
+		attempt.ProviderMessageID = receipt.ProviderMessageID
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+		// Losing the attempt log is better than sending the notification twice
+		if recordErr := h.tracker.RecordAttempt(ctx, attempt); recordErr != nil {
+			log.Printf("Failed to record attempt of notification %s: %v", dispatch.NotificationID, recordErr)
+		}
+
+		steps = append(steps, notification.ChannelStep{Channel: channelName, At: attempt.EndedAt, Error: attempt.Error})
+		if err == nil {
+			delivered = channelName
+			break
+		}
+		if step < len(channels)-1 {
+			log.Printf("Notification %s failed through %s, falling back: %v", dispatch.NotificationID, channelName, err)
+		}
+	}
+	if len(channels) > 1 {
+		if pathErr := h.tracker.RecordChannelPath(ctx, dispatch.NotificationID, delivered, steps); pathErr != nil {
+			log.Printf("Failed to record channel path of notification %s: %v", dispatch.NotificationID, pathErr)
+		}
+	}
+
+	if deferredTo != nil {
+		log.Printf("Deferring notification %s to %s, quiet hours of %s", dispatch.NotificationID, deferredTo.Format(time.RFC3339), channels[len(steps)])
+		return nil
+	}
+
+	if err != nil {
+		if statusErr := h.tracker.UpdateStatus(ctx, dispatch.NotificationID, notification.StatusFailed, err.Error()); statusErr != nil {
+			log.Printf("Failed to mark notification %s as failed: %v", dispatch.NotificationID, statusErr)
+		}
+		return err
+	}
+
+	if err := h.tracker.UpdateStatus(ctx, dispatch.NotificationID, notification.StatusDelivered, ""); err != nil {
+		// The message was sent, retrying it would deliver it twice
+		log.Printf("Failed to mark notification %s as delivered: %v", dispatch.NotificationID, err)
+	}
+
+	log.Printf("Notification %s sent through %s", dispatch.NotificationID, delivered)
+	return nil
+}
+
+// send delivers the message through channelName, meta the channel cannot use
+// is rejected before sending so the next channel of the chain is tried
+func (h *Handler) send(ctx context.Context, channelName string, dispatch notification.DispatchMessage) (notification.Receipt, error) {
+	channel, err := h.channels.Get(channelName)
+	if err != nil {
+		return notification.Receipt{}, err
+	}
+	if len(dispatch.Fallback) > 0 {
+		if err := channel.Validate(dispatch.Meta); err != nil {
+			return notification.Receipt{}, fmt.Errorf("invalid meta: %w", err)
+		}
+	}
+
+	msg := dispatch.ToMessage()
+	if err := channel.Prepare(ctx, &msg); err != nil {
+		return notification.Receipt{}, fmt.Errorf("failed to prepare message: %w", err)
+	}
+	receipt, err := channel.Send(ctx, msg)
+	if err != nil {
+		return receipt, fmt.Errorf("failed to send message: %w", err)
+	}
+	return receipt, nil
+}
+
+// retryNumber derives the retry from how many times SQS has delivered the record
+func retryNumber(record events.SQSMessage) int {
+	count, err := strconv.Atoi(record.Attributes["ApproximateReceiveCount"])
+	if err != nil || count < 1 {
+		return 0
+	}
+	return count - 1
+}
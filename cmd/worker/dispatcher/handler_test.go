@@ -0,0 +1,482 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"serverless-notification/domain/notification"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type fakeChannel struct {
+	name        string
+	validateErr error
+	sendErr     error
+	sent        []notification.Message
+	closes      int
+}
+
+func (c *fakeChannel) Name() string {
+	return c.name
+}
+
+func (c *fakeChannel) Validate(meta map[string]string) error {
+	return c.validateErr
+}
+
+func (c *fakeChannel) Prepare(ctx context.Context, msg *notification.Message) error {
+	return nil
+}
+
+func (c *fakeChannel) Send(ctx context.Context, msg notification.Message) (notification.Receipt, error) {
+	receipt := notification.Receipt{Provider: c.name + "-provider"}
+	if c.sendErr != nil {
+		return receipt, c.sendErr
+	}
+	c.sent = append(c.sent, msg)
+	receipt.ProviderMessageID = "pm-" + msg.NotificationID
+	return receipt, nil
+}
+
+func (c *fakeChannel) Close() error {
+	c.closes++
+	return nil
+}
+
+type fakeTracker struct {
+	statuses map[string]notification.Status
+	reasons  map[string]string
+	attempts []*notification.Attempt
+	quietTo  *time.Time // when set, non-urgent notifications are deferred to it
+	quietOn  string     // when set, only this channel is inside the quiet hours
+	paths    map[string][]notification.ChannelStep
+	chosen   map[string]string
+
+	deferredRetry int // retry of the last deferral
+}
+
+func newFakeTracker() *fakeTracker {
+	return &fakeTracker{
+		statuses: map[string]notification.Status{},
+		reasons:  map[string]string{},
+		paths:    map[string][]notification.ChannelStep{},
+		chosen:   map[string]string{},
+	}
+}
+
+func (f *fakeTracker) RecordAttempt(ctx context.Context, attempt *notification.Attempt) error {
+	f.attempts = append(f.attempts, attempt)
+	return nil
+}
+
+func (f *fakeTracker) DeferForQuietHours(ctx context.Context, message *notification.DispatchMessage, channelName string, retry int, now time.Time) (*time.Time, error) {
+	if f.quietTo == nil || message.Priority == notification.PriorityUrgent {
+		return nil, nil
+	}
+	if f.quietOn != "" && f.quietOn != channelName {
+		return nil, nil
+	}
+	f.statuses[message.NotificationID] = notification.StatusScheduled
+	f.deferredRetry = retry
+	return f.quietTo, nil
+}
+
+func (f *fakeTracker) RecordChannelPath(ctx context.Context, id, deliveredChannel string, steps []notification.ChannelStep) error {
+	f.paths[id] = append(f.paths[id], steps...)
+	f.chosen[id] = deliveredChannel
+	return nil
+}
+
+func (f *fakeTracker) MarkSending(ctx context.Context, id string, redelivered bool) error {
+	if f.statuses[id] == notification.StatusSending && !redelivered {
+		return notification.ErrInvalidTransition
+	}
+	return f.UpdateStatus(ctx, id, notification.StatusSending, "")
+}
+
+func (f *fakeTracker) UpdateStatus(ctx context.Context, id string, status notification.Status, reason string) error {
+	current, ok := f.statuses[id]
+	if !ok {
+		current = notification.StatusQueued
+	}
+	if !current.CanTransitionTo(status) {
+		return notification.ErrInvalidTransition
+	}
+	f.statuses[id] = status
+	f.reasons[id] = reason
+	return nil
+}
+
+func record(t *testing.T, messageID string, msg notification.DispatchMessage) events.SQSMessage {
+	t.Helper()
+	body, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return events.SQSMessage{MessageId: messageID, Body: string(body)}
+}
+
+func failedIDs(resp events.SQSEventResponse) []string {
+	ids := make([]string, len(resp.BatchItemFailures))
+	for i, f := range resp.BatchItemFailures {
+		ids[i] = f.ItemIdentifier
+	}
+	return ids
+}
+
+func TestHandle_AllSucceed(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	h := NewHandler(notification.NewChannelRegistry(email), newFakeTracker())
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "email", Title: "Hola"}),
+		record(t, "m2", notification.DispatchMessage{NotificationID: "n2", ChannelName: "email", Title: "Chau"}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected no failures, got %v", failedIDs(resp))
+	}
+	if len(email.sent) != 2 {
+		t.Fatalf("expected 2 sent messages, got %d", len(email.sent))
+	}
+	if email.sent[0].NotificationID != "n1" || email.sent[0].Title != "Hola" {
+		t.Fatalf("unexpected message: %+v", email.sent[0])
+	}
+}
+
+func TestHandle_ClosesChannelsAfterBatch(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	h := NewHandler(notification.NewChannelRegistry(email), newFakeTracker())
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "email"}),
+		record(t, "m2", notification.DispatchMessage{NotificationID: "n2", ChannelName: "email"}),
+	}}
+
+	if _, err := h.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(email.sent) != 2 || email.closes != 1 {
+		t.Fatalf("expected 2 messages and one close for the batch, got %d messages and %d closes", len(email.sent), email.closes)
+	}
+}
+
+func TestHandle_MixedBatchReportsOnlyFailures(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	push := &fakeChannel{name: "push", sendErr: errors.New("bad token")}
+	tracker := newFakeTracker()
+	h := NewHandler(notification.NewChannelRegistry(email, push), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", UserID: "u1", ChannelName: "push"}),
+		record(t, "m2", notification.DispatchMessage{NotificationID: "n2", UserID: "u1", ChannelName: "email"}),
+		record(t, "m3", notification.DispatchMessage{NotificationID: "n3", UserID: "u1", ChannelName: "fax"}),
+		{MessageId: "m4", Body: "{not json"},
+		record(t, "m5", notification.DispatchMessage{NotificationID: "n5", UserID: "u1", ChannelName: "email"}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	got := failedIDs(resp)
+	want := []string{"m1", "m3", "m4"}
+	if len(got) != len(want) {
+		t.Fatalf("expected failures %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected failures %v, got %v", want, got)
+		}
+	}
+	if len(email.sent) != 2 {
+		t.Fatalf("expected later messages of the same user to be sent, got %d", len(email.sent))
+	}
+	if tracker.statuses["n1"] != notification.StatusFailed || !strings.Contains(tracker.reasons["n1"], "bad token") {
+		t.Fatalf("expected n1 failed with reason, got %s %q", tracker.statuses["n1"], tracker.reasons["n1"])
+	}
+	if tracker.statuses["n2"] != notification.StatusDelivered {
+		t.Fatalf("expected n2 delivered, got %s", tracker.statuses["n2"])
+	}
+}
+
+func TestHandle_SkipsCancelledNotification(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	tracker := newFakeTracker()
+	tracker.statuses["n1"] = notification.StatusCancelled
+	h := NewHandler(notification.NewChannelRegistry(email), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "email"}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected cancelled message to be dropped, got failures %v", failedIDs(resp))
+	}
+	if len(email.sent) != 0 {
+		t.Fatalf("expected cancelled notification not to be sent")
+	}
+	if tracker.statuses["n1"] != notification.StatusCancelled {
+		t.Fatalf("expected status to stay cancelled, got %s", tracker.statuses["n1"])
+	}
+}
+
+func TestHandle_RedeliveryResumesStuckSending(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	tracker := newFakeTracker()
+	// The previous receive marked it as sending and crashed before finishing
+	tracker.statuses["n1"] = notification.StatusSending
+	h := NewHandler(notification.NewChannelRegistry(email), tracker)
+	redelivered := record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "email"})
+	redelivered.Attributes = map[string]string{"ApproximateReceiveCount": "2"}
+
+	resp, err := h.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{redelivered}})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected no failures, got %v", failedIDs(resp))
+	}
+	if len(email.sent) != 1 || tracker.statuses["n1"] != notification.StatusDelivered {
+		t.Fatalf("expected the stuck notification to be delivered, got %d sent and %s", len(email.sent), tracker.statuses["n1"])
+	}
+}
+
+func TestHandle_FirstReceiveSkipsSending(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	tracker := newFakeTracker()
+	tracker.statuses["n1"] = notification.StatusSending
+	h := NewHandler(notification.NewChannelRegistry(email), tracker)
+	duplicate := record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "email"})
+	duplicate.Attributes = map[string]string{"ApproximateReceiveCount": "1"}
+
+	if _, err := h.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{duplicate}}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(email.sent) != 0 {
+		t.Fatal("expected a duplicate of a message being sent not to be sent again")
+	}
+}
+
+func TestHandle_EmptyBatch(t *testing.T) {
+	h := NewHandler(notification.NewChannelRegistry(), newFakeTracker())
+	resp, err := h.Handle(context.Background(), events.SQSEvent{})
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected no failures, got %v", failedIDs(resp))
+	}
+}
+
+func TestHandle_RecordsAttempts(t *testing.T) {
+	email := &fakeChannel{name: "email"}
+	push := &fakeChannel{name: "push", sendErr: errors.New("bad token")}
+	tracker := newFakeTracker()
+	tracker.statuses["n1"] = notification.StatusFailed
+	h := NewHandler(notification.NewChannelRegistry(email, push), tracker)
+	retried := record(t, "m1", notification.DispatchMessage{NotificationID: "n1", UserID: "u1", ChannelName: "push"})
+	retried.Attributes = map[string]string{"ApproximateReceiveCount": "3"}
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		retried,
+		record(t, "m2", notification.DispatchMessage{NotificationID: "n2", UserID: "u1", ChannelName: "email"}),
+	}}
+
+	if _, err := h.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(tracker.attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(tracker.attempts))
+	}
+	failed := tracker.attempts[0]
+	if failed.NotificationID != "n1" || failed.Retry != 2 || failed.Provider != "push-provider" || !strings.Contains(failed.Error, "bad token") {
+		t.Fatalf("unexpected failed attempt: %+v", failed)
+	}
+	delivered := tracker.attempts[1]
+	if delivered.Retry != 0 || delivered.ProviderMessageID != "pm-n2" || delivered.Error != "" {
+		t.Fatalf("unexpected delivered attempt: %+v", delivered)
+	}
+	if delivered.EndedAt.Before(delivered.StartedAt) {
+		t.Fatalf("expected ended_at after started_at: %+v", delivered)
+	}
+}
+
+func TestHandle_RetriesContinueAfterDeferral(t *testing.T) {
+	push := &fakeChannel{name: "push"}
+	tracker := newFakeTracker()
+	until := time.Now().Add(time.Hour)
+	tracker.quietTo = &until
+	h := NewHandler(notification.NewChannelRegistry(push), tracker)
+	deferred := record(t, "m1", notification.DispatchMessage{NotificationID: "n1", UserID: "u1", ChannelName: "push", RetryOffset: 1})
+	deferred.Attributes = map[string]string{"ApproximateReceiveCount": "3"}
+
+	if _, err := h.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{deferred}}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if tracker.deferredRetry != 3 {
+		t.Fatalf("expected the deferral at retry 3, got %d", tracker.deferredRetry)
+	}
+
+	// Published again after the quiet hours, SQS counts deliveries from 1
+	tracker.quietTo = nil
+	tracker.statuses["n1"] = notification.StatusQueued
+	republished := record(t, "m2", notification.DispatchMessage{NotificationID: "n1", UserID: "u1", ChannelName: "push", RetryOffset: 3})
+	republished.Attributes = map[string]string{"ApproximateReceiveCount": "1"}
+	if _, err := h.Handle(context.Background(), events.SQSEvent{Records: []events.SQSMessage{republished}}); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(tracker.attempts) != 1 || tracker.attempts[0].Retry != 3 {
+		t.Fatalf("expected the attempt to follow the earlier deliveries, got %+v", tracker.attempts)
+	}
+}
+
+func TestHandle_QuietHoursDeferNonUrgent(t *testing.T) {
+	push := &fakeChannel{name: "push"}
+	tracker := newFakeTracker()
+	quietTo := time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)
+	tracker.quietTo = &quietTo
+	h := NewHandler(notification.NewChannelRegistry(push), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "push", Priority: notification.PriorityNormal}),
+		record(t, "m2", notification.DispatchMessage{NotificationID: "n2", ChannelName: "push", Priority: notification.PriorityUrgent}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected deferred messages to be deleted, got failures %v", failedIDs(resp))
+	}
+	if len(push.sent) != 1 || push.sent[0].NotificationID != "n2" {
+		t.Fatalf("expected only the urgent notification to be sent, got %+v", push.sent)
+	}
+	if tracker.statuses["n1"] != notification.StatusScheduled || len(tracker.attempts) != 1 {
+		t.Fatalf("expected n1 scheduled without an attempt, got %s and %d attempts", tracker.statuses["n1"], len(tracker.attempts))
+	}
+}
+
+func TestHandle_FallbackInsideQuietHoursDefers(t *testing.T) {
+	push := &fakeChannel{name: "push", sendErr: errors.New("bad token")}
+	sms := &fakeChannel{name: "sms"}
+	tracker := newFakeTracker()
+	quietTo := time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)
+	tracker.quietTo = &quietTo
+	tracker.quietOn = "sms"
+	h := NewHandler(notification.NewChannelRegistry(push, sms), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "push", Fallback: []string{"sms"}}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected the deferred message to be deleted, got failures %v", failedIDs(resp))
+	}
+	if len(sms.sent) != 0 {
+		t.Fatalf("expected nothing sent through the quiet channel, got %+v", sms.sent)
+	}
+	if tracker.statuses["n1"] != notification.StatusScheduled || tracker.deferredRetry != 1 {
+		t.Fatalf("expected n1 scheduled with the next retry, got %s at %d", tracker.statuses["n1"], tracker.deferredRetry)
+	}
+	if len(tracker.attempts) != 1 || tracker.attempts[0].Channel != "push" {
+		t.Fatalf("expected only the push attempt, got %+v", tracker.attempts)
+	}
+	if path := tracker.paths["n1"]; len(path) != 1 || tracker.chosen["n1"] != "" {
+		t.Fatalf("expected the path to stop at push, got %+v", path)
+	}
+}
+
+func TestHandle_FallsBackToNextChannel(t *testing.T) {
+	push := &fakeChannel{name: "push", sendErr: errors.New("bad token")}
+	sms := &fakeChannel{name: "sms"}
+	email := &fakeChannel{name: "email"}
+	tracker := newFakeTracker()
+	h := NewHandler(notification.NewChannelRegistry(push, sms, email), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "push", Fallback: []string{"sms", "email"}}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(resp.BatchItemFailures) != 0 {
+		t.Fatalf("expected no failures, got %v", failedIDs(resp))
+	}
+	if len(sms.sent) != 1 || len(email.sent) != 0 {
+		t.Fatalf("expected delivery through sms only, got sms=%d email=%d", len(sms.sent), len(email.sent))
+	}
+	path := tracker.paths["n1"]
+	if tracker.chosen["n1"] != "sms" || len(path) != 2 || path[0].Channel != "push" || path[0].Error == "" || path[1].Error != "" {
+		t.Fatalf("unexpected path %+v through %q", path, tracker.chosen["n1"])
+	}
+	if len(tracker.attempts) != 2 || tracker.attempts[1].Channel != "sms" || tracker.attempts[1].Step != 1 {
+		t.Fatalf("expected an attempt per channel, got %+v", tracker.attempts)
+	}
+	if tracker.statuses["n1"] != notification.StatusDelivered {
+		t.Fatalf("expected delivered, got %s", tracker.statuses["n1"])
+	}
+}
+
+func TestHandle_FallbackChainExhaustedFails(t *testing.T) {
+	push := &fakeChannel{name: "push", sendErr: errors.New("bad token")}
+	sms := &fakeChannel{name: "sms", sendErr: errors.New("carrier down")}
+	tracker := newFakeTracker()
+	h := NewHandler(notification.NewChannelRegistry(push, sms), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "push", Fallback: []string{"sms"}}),
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if ids := failedIDs(resp); len(ids) != 1 || ids[0] != "m1" {
+		t.Fatalf("expected m1 to be retried, got %v", ids)
+	}
+	if tracker.chosen["n1"] != "" || len(tracker.paths["n1"]) != 2 {
+		t.Fatalf("expected a path without a delivered channel, got %+v %q", tracker.paths["n1"], tracker.chosen["n1"])
+	}
+	if tracker.statuses["n1"] != notification.StatusFailed || !strings.Contains(tracker.reasons["n1"], "carrier down") {
+		t.Fatalf("expected failed with the last error, got %s %q", tracker.statuses["n1"], tracker.reasons["n1"])
+	}
+}
+
+func TestHandle_FallbackSkipsChannelWithoutContactData(t *testing.T) {
+	push := &fakeChannel{name: "push", validateErr: errors.New("token is required")}
+	email := &fakeChannel{name: "email"}
+	tracker := newFakeTracker()
+	h := NewHandler(notification.NewChannelRegistry(push, email), tracker)
+	event := events.SQSEvent{Records: []events.SQSMessage{
+		record(t, "m1", notification.DispatchMessage{NotificationID: "n1", ChannelName: "push", Fallback: []string{"email"}}),
+	}}
+
+	if _, err := h.Handle(context.Background(), event); err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+
+	if len(push.sent) != 0 || len(email.sent) != 1 {
+		t.Fatalf("expected delivery through email only, got push=%d email=%d", len(push.sent), len(email.sent))
+	}
+	if path := tracker.paths["n1"]; !strings.Contains(path[0].Error, "token is required") || tracker.chosen["n1"] != "email" {
+		t.Fatalf("unexpected path %+v", path)
+	}
+}
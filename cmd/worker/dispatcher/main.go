@@ -1 +1,14 @@
-package dispatcher
+package main
+
+import (
+	"serverless-notification/cmd"
+
+	"github.com/aws/aws-lambda-go/lambda"
+)
+
+func main() {
+	deps := cmd.InitDependencies()
+	handler := NewHandler(deps.Channels, deps.Service)
+
+	lambda.Start(handler.Handle)
+}
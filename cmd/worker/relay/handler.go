@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log"
+
+	"serverless-notification/adapters/dynamodb"
+	"serverless-notification/domain/notification"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Relayer publishes outbox records to the dispatcher queue
+type Relayer interface {
+	RelayOutbox(ctx context.Context, record *notification.OutboxRecord) error
+}
+
+// Handler consumes the DynamoDB Stream of the notifications table and relays new outbox items
+type Handler struct {
+	relayer Relayer
+}
+
+// NewHandler creates a new Handler
+func NewHandler(relayer Relayer) *Handler {
+	return &Handler{relayer: relayer}
+}
+
+// Handle relays every pending outbox item of the batch and reports only the failed ones
+func (h *Handler) Handle(ctx context.Context, event events.DynamoDBEvent) (events.DynamoDBEventResponse, error) {
+	failures := []events.DynamoDBBatchItemFailure{}
+	for _, record := range event.Records {
+		if err := h.process(ctx, record); err != nil {
+			log.Printf("Failed to relay stream record %s: %v", record.EventID, err)
+			failures = append(failures, events.DynamoDBBatchItemFailure{ItemIdentifier: record.Change.SequenceNumber})
+		}
+	}
+	return events.DynamoDBEventResponse{BatchItemFailures: failures}, nil
+}
+
+func (h *Handler) process(ctx context.Context, record events.DynamoDBEventRecord) error {
+	if record.EventName != string(events.DynamoDBOperationTypeInsert) {
+		return nil
+	}
+	outboxRecord, ok, err := dynamodb.OutboxRecordFromStream(record.Change.NewImage)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return h.relayer.RelayOutbox(ctx, outboxRecord)
+}
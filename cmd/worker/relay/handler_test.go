@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"serverless-notification/domain/notification"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+type fakeRelayer struct {
+	failFor string
+	relayed []string
+}
+
+func (r *fakeRelayer) RelayOutbox(ctx context.Context, record *notification.OutboxRecord) error {
+	if record.NotificationID == r.failFor {
+		return errors.New("sqs unavailable")
+	}
+	r.relayed = append(r.relayed, record.NotificationID)
+	return nil
+}
+
+func outboxInsert(sequence, notificationID string) events.DynamoDBEventRecord {
+	return events.DynamoDBEventRecord{
+		EventName: string(events.DynamoDBOperationTypeInsert),
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: sequence,
+			NewImage: map[string]events.DynamoDBAttributeValue{
+				"SK":              events.NewStringAttribute("OUTBOX#" + notificationID),
+				"notification_id": events.NewStringAttribute(notificationID),
+				"user_id":         events.NewStringAttribute("usr_123"),
+				"message":         events.NewStringAttribute(`{"notification_id":"` + notificationID + `"}`),
+				"outbox_status":   events.NewStringAttribute("pending"),
+				"created_at":      events.NewStringAttribute("2024-11-03T15:30:00Z"),
+			},
+		},
+	}
+}
+
+func TestHandle_RelaysOutboxInserts(t *testing.T) {
+	relayer := &fakeRelayer{failFor: "n2"}
+	h := NewHandler(relayer)
+	notificationInsert := events.DynamoDBEventRecord{
+		EventName: string(events.DynamoDBOperationTypeInsert),
+		Change: events.DynamoDBStreamRecord{
+			SequenceNumber: "3",
+			NewImage:       map[string]events.DynamoDBAttributeValue{"SK": events.NewStringAttribute("NOTIF#2024-11-03T15:30:00Z#n3")},
+		},
+	}
+	modify := outboxInsert("4", "n4")
+	modify.EventName = string(events.DynamoDBOperationTypeModify)
+	event := events.DynamoDBEvent{Records: []events.DynamoDBEventRecord{
+		outboxInsert("1", "n1"),
+		outboxInsert("2", "n2"),
+		notificationInsert,
+		modify,
+	}}
+
+	resp, err := h.Handle(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Handle: %v", err)
+	}
+	if len(relayer.relayed) != 1 || relayer.relayed[0] != "n1" {
+		t.Fatalf("expected only n1 to be relayed, got %v", relayer.relayed)
+	}
+	if len(resp.BatchItemFailures) != 1 || resp.BatchItemFailures[0].ItemIdentifier != "2" {
+		t.Fatalf("expected sequence 2 to fail, got %v", resp.BatchItemFailures)
+	}
+}
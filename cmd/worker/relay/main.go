@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"serverless-notification/cmd"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Println("Warning: .env file not found")
+		}
+	}
+}
+
+func main() {
+	service := cmd.InitDependencies().Service
+
+	if isLambda() {
+		log.Println("Running in Lambda mode")
+		lambda.Start(NewHandler(service).Handle)
+		return
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("OUTBOX_POLL_INTERVAL"))
+	if err != nil {
+		interval = 5 * time.Second
+	}
+	log.Printf("Polling outbox every %s", interval)
+	for {
+		relayed, err := service.RelayPending(context.Background(), 25)
+		if err != nil {
+			log.Printf("Failed to relay outbox: %v", err)
+		}
+		if relayed > 0 {
+			log.Printf("Relayed %d outbox records", relayed)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func isLambda() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"serverless-notification/cmd"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/joho/godotenv"
+)
+
+func init() {
+	if _, err := os.Stat(".env"); err == nil {
+		if err := godotenv.Load(); err != nil {
+			log.Println("Warning: .env file not found")
+		}
+	}
+}
+
+// main publishes due scheduled notifications
+// In AWS it runs on an EventBridge schedule (every minute), locally it loops
+func main() {
+	service := cmd.InitDependencies().Service
+
+	dispatchDue := func(ctx context.Context) error {
+		published, err := service.DispatchDue(ctx, time.Now())
+		if published > 0 {
+			log.Printf("Published %d scheduled notifications", published)
+		}
+		return err
+	}
+
+	if isLambda() {
+		log.Println("Running in Lambda mode")
+		lambda.Start(dispatchDue)
+		return
+	}
+
+	interval, err := time.ParseDuration(os.Getenv("SCHEDULER_POLL_INTERVAL"))
+	if err != nil {
+		interval = time.Minute
+	}
+	log.Printf("Checking scheduled notifications every %s", interval)
+	for {
+		if err := dispatchDue(context.Background()); err != nil {
+			log.Printf("Failed to dispatch scheduled notifications: %v", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+func isLambda() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
@@ -0,0 +1,37 @@
+package apikey
+
+import "time"
+
+// Key is an API key of a backend service, only the SHA-256 hash of the secret is stored
+type Key struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"-"`
+	Name            string     `json:"name"`
+	Prefix          string     `json:"prefix"` // first characters of the secret, to tell keys apart
+	Hash            string     `json:"-"`
+	Scopes          []string   `json:"scopes"`
+	AllowedChannels []string   `json:"allowed_channels"` // empty allows every channel
+	CreatedAt       time.Time  `json:"created_at"`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt      *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+}
+
+// Active reports whether the key can still authenticate at now
+func (k *Key) Active(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	return k.ExpiresAt == nil || now.Before(*k.ExpiresAt)
+}
+
+type MintRequest struct {
+	Name            string     `json:"name" binding:"required"`
+	Scopes          []string   `json:"scopes" binding:"required,min=1"`
+	AllowedChannels []string   `json:"allowed_channels"`
+	ExpiresAt       *time.Time `json:"expires_at"` // optional, RFC3339
+}
+
+type RotateRequest struct {
+	GracePeriod string `json:"grace_period"` // how long the old key keeps working, 24h by default
+}
@@ -0,0 +1,17 @@
+package apikey
+
+import (
+	"context"
+	"time"
+)
+
+// Repository defines the contract for API key persistence
+type Repository interface {
+	CreateAPIKey(ctx context.Context, k *Key) error
+	GetAPIKey(ctx context.Context, userID, id string) (*Key, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*Key, error)
+	ListAPIKeys(ctx context.Context, userID string) ([]*Key, error)
+	RevokeAPIKey(ctx context.Context, k *Key, at time.Time) error
+	ExpireAPIKey(ctx context.Context, k *Key, at time.Time) error
+	TouchAPIKey(ctx context.Context, k *Key, at time.Time) error
+}
@@ -0,0 +1,225 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"serverless-notification/domain/auth"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrKeyNotFound    = errors.New("api key not found")
+	ErrInvalidKey     = fmt.Errorf("%w: invalid api key", auth.ErrUnauthenticated)
+	ErrInvalidRequest = errors.New("invalid api key request")
+)
+
+const (
+	// keyPrefix marks API keys so they are easy to recognise in logs and secret scanners
+	keyPrefix = "nk_"
+	// lastUsedResolution limits last_used_at writes to one per key and minute
+	lastUsedResolution = time.Minute
+	// defaultGracePeriod is how long a rotated key keeps working
+	defaultGracePeriod = 24 * time.Hour
+)
+
+// Service contains the business logic for API keys
+type Service struct {
+	repo Repository
+}
+
+// NewService creates a new instance of the service
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Mint creates a key for the authenticated user and returns it with its secret
+// The secret is only available here, the key stores its hash
+// Callers authenticated with a key cannot mint a key with more scopes or channels than their own
+func (s *Service) Mint(ctx context.Context, req MintRequest) (*Key, string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, "", auth.ErrUnauthenticated
+	}
+	if err := validateMint(principal, req, time.Now()); err != nil {
+		return nil, "", err
+	}
+	return s.mint(ctx, principal.UserID, req)
+}
+
+func (s *Service) mint(ctx context.Context, userID string, req MintRequest) (*Key, string, error) {
+	secret, err := generateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	key := &Key{
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		Name:            req.Name,
+		Prefix:          secret[:len(keyPrefix)+6],
+		Hash:            hashSecret(secret),
+		Scopes:          req.Scopes,
+		AllowedChannels: req.AllowedChannels,
+		CreatedAt:       time.Now(),
+		ExpiresAt:       req.ExpiresAt,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("failed to create api key: %w", err)
+	}
+	return key, secret, nil
+}
+
+// List lists the keys of the authenticated user, revoked and expired ones included
+func (s *Service) List(ctx context.Context) ([]*Key, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListAPIKeys(ctx, userID)
+}
+
+// Revoke revokes a key of the authenticated user, it stops working immediately
+func (s *Service) Revoke(ctx context.Context, id string) error {
+	key, err := s.getOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	return s.repo.RevokeAPIKey(ctx, key, time.Now())
+}
+
+// Rotate mints a key with the same name, scopes, channels and expiry as id
+// and makes id expire once the grace period is over
+// Like Mint, callers authenticated with a key cannot rotate a key broader than their own
+func (s *Service) Rotate(ctx context.Context, id string, req RotateRequest) (*Key, string, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, "", auth.ErrUnauthenticated
+	}
+	gracePeriod := defaultGracePeriod
+	if req.GracePeriod != "" {
+		parsed, err := time.ParseDuration(req.GracePeriod)
+		if err != nil || parsed < 0 {
+			return nil, "", fmt.Errorf("%w: grace_period must be a positive duration", ErrInvalidRequest)
+		}
+		gracePeriod = parsed
+	}
+
+	old, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	if !old.Active(now) {
+		return nil, "", fmt.Errorf("%w: the key is revoked or expired", ErrInvalidRequest)
+	}
+
+	mintReq := MintRequest{
+		Name:            old.Name,
+		Scopes:          old.Scopes,
+		AllowedChannels: old.AllowedChannels,
+		ExpiresAt:       old.ExpiresAt,
+	}
+	if err := validateMint(principal, mintReq, now); err != nil {
+		return nil, "", err
+	}
+	key, secret, err := s.mint(ctx, old.UserID, mintReq)
+	if err != nil {
+		return nil, "", err
+	}
+
+	expiresAt := now.Add(gracePeriod)
+	if old.ExpiresAt == nil || expiresAt.Before(*old.ExpiresAt) {
+		if err := s.repo.ExpireAPIKey(ctx, old, expiresAt); err != nil {
+			return nil, "", fmt.Errorf("failed to expire rotated api key: %w", err)
+		}
+	}
+	return key, secret, nil
+}
+
+// Authenticate returns the principal of an API key secret, or ErrInvalidKey
+func (s *Service) Authenticate(ctx context.Context, secret string) (auth.Principal, error) {
+	if !strings.HasPrefix(secret, keyPrefix) {
+		return auth.Principal{}, ErrInvalidKey
+	}
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashSecret(secret))
+	if errors.Is(err, ErrKeyNotFound) {
+		return auth.Principal{}, ErrInvalidKey
+	}
+	if err != nil {
+		return auth.Principal{}, err
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return auth.Principal{}, ErrInvalidKey
+	}
+	// last_used_at is informative, a failed write must not reject the request
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		_ = s.repo.TouchAPIKey(ctx, key, now)
+	}
+
+	return auth.Principal{
+		UserID:          key.UserID,
+		KeyID:           key.ID,
+		Scopes:          key.Scopes,
+		AllowedChannels: key.AllowedChannels,
+	}, nil
+}
+
+// getOwned gets a key of the authenticated user by ID
+func (s *Service) getOwned(ctx context.Context, id string) (*Key, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetAPIKey(ctx, userID, id)
+}
+
+func validateMint(principal auth.Principal, req MintRequest, now time.Time) error {
+	for _, scope := range req.Scopes {
+		if !auth.KnownScope(scope) {
+			return fmt.Errorf("%w: unknown scope %s", ErrInvalidRequest, scope)
+		}
+		if !principal.HasScope(scope) {
+			return fmt.Errorf("%w: cannot grant scope %s", auth.ErrForbidden, scope)
+		}
+	}
+	if len(principal.AllowedChannels) > 0 && len(req.AllowedChannels) == 0 {
+		return fmt.Errorf("%w: allowed_channels must be a subset of the caller's channels", auth.ErrForbidden)
+	}
+	for _, channel := range req.AllowedChannels {
+		if !principal.CanUseChannel(channel) {
+			return fmt.Errorf("%w: cannot grant channel %s", auth.ErrForbidden, channel)
+		}
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// generateSecret returns a new key secret with 256 bits of entropy
+func generateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashSecret is the value stored for a secret, SHA-256 is enough for random 256 bit secrets
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,259 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+type fakeRepository struct {
+	keys    map[string]*Key
+	touches int
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{keys: map[string]*Key{}}
+}
+
+func (r *fakeRepository) CreateAPIKey(ctx context.Context, k *Key) error {
+	r.keys[k.ID] = k
+	return nil
+}
+
+func (r *fakeRepository) GetAPIKey(ctx context.Context, userID, id string) (*Key, error) {
+	k, ok := r.keys[id]
+	if !ok || k.UserID != userID {
+		return nil, ErrKeyNotFound
+	}
+	return k, nil
+}
+
+func (r *fakeRepository) GetAPIKeyByHash(ctx context.Context, hash string) (*Key, error) {
+	for _, k := range r.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (r *fakeRepository) ListAPIKeys(ctx context.Context, userID string) ([]*Key, error) {
+	var keys []*Key
+	for _, k := range r.keys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (r *fakeRepository) RevokeAPIKey(ctx context.Context, k *Key, at time.Time) error {
+	k.RevokedAt = &at
+	return nil
+}
+
+func (r *fakeRepository) ExpireAPIKey(ctx context.Context, k *Key, at time.Time) error {
+	k.ExpiresAt = &at
+	return nil
+}
+
+func (r *fakeRepository) TouchAPIKey(ctx context.Context, k *Key, at time.Time) error {
+	r.touches++
+	k.LastUsedAt = &at
+	return nil
+}
+
+// asUser signs in userID as an admin, the role that manages API keys
+func asUser(userID string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: userID, Scopes: auth.ScopesForRole(auth.RoleAdmin)})
+}
+
+func TestMint_StoresOnlyTheHash(t *testing.T) {
+	repo := newFakeRepository()
+	s := NewService(repo)
+
+	key, secret, err := s.Mint(asUser("usr_123"), MintRequest{Name: "billing", Scopes: []string{auth.ScopeNotificationsWrite}})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	if !strings.HasPrefix(secret, "nk_") || !strings.HasPrefix(secret, key.Prefix) {
+		t.Fatalf("unexpected secret %q for prefix %q", secret, key.Prefix)
+	}
+	if key.Hash == "" || strings.Contains(key.Hash, secret) {
+		t.Fatalf("expected the secret to be hashed, got %q", key.Hash)
+	}
+	if key.UserID != "usr_123" {
+		t.Fatalf("expected the caller as owner, got %s", key.UserID)
+	}
+}
+
+func TestMint_InvalidRequest(t *testing.T) {
+	s := NewService(newFakeRepository())
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name string
+		req  MintRequest
+	}{
+		{"unknown scope", MintRequest{Name: "k", Scopes: []string{"everything"}}},
+		{"expired", MintRequest{Name: "k", Scopes: []string{auth.ScopeNotificationsRead}, ExpiresAt: &past}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.Mint(asUser("usr_123"), tt.req); !errors.Is(err, ErrInvalidRequest) {
+				t.Fatalf("expected ErrInvalidRequest, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMint_KeyCannotEscalate(t *testing.T) {
+	s := NewService(newFakeRepository())
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:          "usr_123",
+		KeyID:           "k1",
+		Scopes:          []string{auth.ScopeAPIKeysAdmin, auth.ScopeNotificationsRead},
+		AllowedChannels: []string{"email"},
+	})
+
+	tests := []struct {
+		name string
+		req  MintRequest
+	}{
+		{"more scopes", MintRequest{Name: "k", Scopes: []string{auth.ScopeNotificationsWrite}}},
+		{"every channel", MintRequest{Name: "k", Scopes: []string{auth.ScopeNotificationsRead}}},
+		{"other channel", MintRequest{Name: "k", Scopes: []string{auth.ScopeNotificationsRead}, AllowedChannels: []string{"sms"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := s.Mint(ctx, tt.req); !errors.Is(err, auth.ErrForbidden) {
+				t.Fatalf("expected ErrForbidden, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	repo := newFakeRepository()
+	s := NewService(repo)
+	key, secret, err := s.Mint(asUser("usr_123"), MintRequest{
+		Name:            "billing",
+		Scopes:          []string{auth.ScopeNotificationsWrite},
+		AllowedChannels: []string{"email"},
+	})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	principal, err := s.Authenticate(context.Background(), secret)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if principal.UserID != "usr_123" || principal.KeyID != key.ID {
+		t.Fatalf("unexpected principal: %+v", principal)
+	}
+	if !principal.HasScope(auth.ScopeNotificationsWrite) || principal.HasScope(auth.ScopeNotificationsRead) {
+		t.Fatalf("expected only the key scopes, got %v", principal.Scopes)
+	}
+	if !principal.CanUseChannel("email") || principal.CanUseChannel("sms") {
+		t.Fatalf("expected only the key channels, got %v", principal.AllowedChannels)
+	}
+
+	if _, err := s.Authenticate(context.Background(), secret); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if key.LastUsedAt == nil || repo.touches != 1 {
+		t.Fatalf("expected last_used_at to be written once a minute, got %d writes", repo.touches)
+	}
+}
+
+func TestAuthenticate_Rejects(t *testing.T) {
+	s := NewService(newFakeRepository())
+	revoked, revokedSecret, err := s.Mint(asUser("usr_123"), MintRequest{Name: "revoked", Scopes: []string{auth.ScopeNotificationsRead}})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	if err := s.Revoke(asUser("usr_123"), revoked.ID); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	expired, expiredSecret, err := s.Mint(asUser("usr_123"), MintRequest{Name: "expired", Scopes: []string{auth.ScopeNotificationsRead}})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	past := time.Now().Add(-time.Second)
+	expired.ExpiresAt = &past
+
+	for _, secret := range []string{revokedSecret, expiredSecret, "nk_unknown", "not-a-key"} {
+		if _, err := s.Authenticate(context.Background(), secret); !errors.Is(err, ErrInvalidKey) || !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Fatalf("expected ErrInvalidKey for %q, got %v", secret, err)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	s := NewService(newFakeRepository())
+	old, oldSecret, err := s.Mint(asUser("usr_123"), MintRequest{Name: "billing", Scopes: []string{auth.ScopeNotificationsWrite}, AllowedChannels: []string{"email"}})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	rotated, secret, err := s.Rotate(asUser("usr_123"), old.ID, RotateRequest{GracePeriod: "1h"})
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+
+	if rotated.ID == old.ID || secret == oldSecret {
+		t.Fatal("expected a new key")
+	}
+	if rotated.Name != "billing" || len(rotated.AllowedChannels) != 1 || rotated.AllowedChannels[0] != "email" {
+		t.Fatalf("expected the settings of the old key, got %+v", rotated)
+	}
+	if old.ExpiresAt == nil || time.Until(*old.ExpiresAt) > time.Hour {
+		t.Fatalf("expected the old key to expire after the grace period, got %v", old.ExpiresAt)
+	}
+	for _, candidate := range []string{oldSecret, secret} {
+		if _, err := s.Authenticate(context.Background(), candidate); err != nil {
+			t.Fatalf("expected both keys to work during the grace period: %v", err)
+		}
+	}
+}
+
+func TestRotate_KeyCannotEscalate(t *testing.T) {
+	s := NewService(newFakeRepository())
+	broad, _, err := s.Mint(asUser("usr_123"), MintRequest{Name: "ops", Scopes: []string{auth.ScopeNotificationsWrite, auth.ScopeAPIKeysAdmin}})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{
+		UserID:          "usr_123",
+		KeyID:           "k1",
+		Scopes:          []string{auth.ScopeAPIKeysAdmin, auth.ScopeNotificationsRead},
+		AllowedChannels: []string{"email"},
+	})
+
+	if _, _, err := s.Rotate(ctx, broad.ID, RotateRequest{}); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if broad.ExpiresAt != nil {
+		t.Fatal("expected the broader key not to be rotated")
+	}
+}
+
+func TestRevoke_OtherUserIsNotFound(t *testing.T) {
+	s := NewService(newFakeRepository())
+	key, _, err := s.Mint(asUser("usr_123"), MintRequest{Name: "billing", Scopes: []string{auth.ScopeNotificationsRead}})
+	if err != nil {
+		t.Fatalf("Mint: %v", err)
+	}
+
+	if err := s.Revoke(asUser("usr_456"), key.ID); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("expected ErrKeyNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+// Scopes an API key can be granted
+const (
+	ScopeNotificationsRead      = "notifications:read"
+	ScopeNotificationsWrite     = "notifications:write"
+	ScopeNotificationsBroadcast = "notifications:broadcast" // batches for other users
+	ScopeContactsRead           = "contacts:read"
+	ScopeContactsWrite          = "contacts:write"
+	ScopePreferencesRead        = "preferences:read"
+	ScopePreferencesWrite       = "preferences:write"
+	ScopeTopicsRead             = "topics:read"
+	ScopeTopicsWrite            = "topics:write"
+	ScopeAPIKeysAdmin           = "api_keys:admin"
+)
+
+// KnownScope reports whether scope is one of the scopes above
+func KnownScope(scope string) bool {
+	switch scope {
+	case ScopeNotificationsRead, ScopeNotificationsWrite, ScopeNotificationsBroadcast, ScopeContactsRead,
+		ScopeContactsWrite, ScopePreferencesRead, ScopePreferencesWrite, ScopeTopicsRead, ScopeTopicsWrite,
+		ScopeAPIKeysAdmin:
+		return true
+	}
+	return false
+}
+
+// RoleAdmin is the role of the users that administer the service, it grants every scope
+const RoleAdmin = "admin"
+
+// ScopesForRole returns the scopes of a user with role, an empty role is a regular user
+// Regular users act on their own data, they cannot notify other users or manage API keys
+func ScopesForRole(role string) []string {
+	scopes := []string{
+		ScopeNotificationsRead, ScopeNotificationsWrite, ScopeContactsRead, ScopeContactsWrite,
+		ScopePreferencesRead, ScopePreferencesWrite, ScopeTopicsRead, ScopeTopicsWrite,
+	}
+	if role == RoleAdmin {
+		scopes = append(scopes, ScopeNotificationsBroadcast, ScopeAPIKeysAdmin)
+	}
+	return scopes
+}
+
+// Principal is the authenticated caller of a request
+// Users authenticated with a JWT have no KeyID and the scopes of their token,
+// callers authenticated with an API key are limited to the key's scopes and channels
+type Principal struct {
+	UserID          string
+	KeyID           string
+	Scopes          []string
+	AllowedChannels []string // empty allows every channel
+}
+
+// HasScope reports whether the caller was granted scope
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope)
+}
+
+// CanUseChannel reports whether the caller may send through channelName
+func (p Principal) CanUseChannel(channelName string) bool {
+	return len(p.AllowedChannels) == 0 || slices.Contains(p.AllowedChannels, channelName)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the authenticated caller
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the authenticated caller stored in ctx
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok && p.UserID != ""
+}
+
+// UserID returns the ID of the authenticated caller, or ErrUnauthenticated
+func UserID(ctx context.Context) (string, error) {
+	p, ok := FromContext(ctx)
+	if !ok {
+		return "", ErrUnauthenticated
+	}
+	return p.UserID, nil
+}
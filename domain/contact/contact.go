@@ -0,0 +1,57 @@
+package contact
+
+import "time"
+
+// Profile is the contact book of a user, used to address notifications that leave it out of meta
+type Profile struct {
+	Emails  []*Email  `json:"emails"`
+	Phones  []*Phone  `json:"phones"`
+	Devices []*Device `json:"devices"`
+}
+
+// Email is an email address of the user, only verified addresses are used for delivery
+type Email struct {
+	Address    string     `json:"address"`
+	Verified   bool       `json:"verified"`
+	CreatedAt  time.Time  `json:"created_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+
+	// Pending verification, cleared once verified
+	CodeHash      string     `json:"-"`
+	CodeExpiresAt *time.Time `json:"-"`
+	CodeAttempts  int        `json:"-"`
+}
+
+type Phone struct {
+	Number    string    `json:"number"` // E.164
+	Carrier   string    `json:"carrier"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Device is a push registration, a user can have one per app install
+type Device struct {
+	ID        string    `json:"id"`
+	Token     string    `json:"token"`
+	Platform  string    `json:"platform"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type AddEmailRequest struct {
+	Address string `json:"address" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Address string `json:"address" binding:"required"`
+	Code    string `json:"code" binding:"required"`
+}
+
+type PhoneRequest struct {
+	Number  string `json:"number" binding:"required"`
+	Carrier string `json:"carrier" binding:"required"`
+}
+
+type DeviceRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform" binding:"required"`
+}
@@ -0,0 +1,16 @@
+package contact
+
+import "context"
+
+// Repository defines the contract for contact persistence
+// GetProfile returns emails and phones oldest first
+// Put methods insert or replace, Delete methods fail with ErrContactNotFound
+type Repository interface {
+	GetProfile(ctx context.Context, userID string) (*Profile, error)
+	PutEmail(ctx context.Context, userID string, e *Email) error
+	DeleteEmail(ctx context.Context, userID, address string) error
+	PutPhone(ctx context.Context, userID string, p *Phone) error
+	DeletePhone(ctx context.Context, userID, number string) error
+	PutDevice(ctx context.Context, userID string, d *Device) error
+	DeleteDevice(ctx context.Context, userID, id string) error
+}
@@ -0,0 +1,373 @@
+package contact
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"serverless-notification/domain/auth"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrContactNotFound = errors.New("contact not found")
+	ErrContactExists   = errors.New("contact already exists")
+	ErrInvalidContact  = errors.New("invalid contact")
+	ErrInvalidCode     = errors.New("invalid or expired verification code")
+	ErrCodeThrottled   = errors.New("a verification code was sent recently, try again later")
+)
+
+const (
+	// codeTTL is how long an email verification code is valid
+	codeTTL = 15 * time.Minute
+	// maxCodeAttempts is how many wrong codes are accepted before a new one must be requested
+	maxCodeAttempts = 5
+	// resendCooldown is how long after sending a code another one can be requested
+	resendCooldown = time.Minute
+)
+
+var (
+	phoneRegexp = regexp.MustCompile(`^\+[1-9]\d{1,14}$`)
+	platforms   = []string{"ios", "android", "web"}
+)
+
+// CodeSender delivers email verification codes
+type CodeSender interface {
+	SendVerificationCode(ctx context.Context, address, code string) error
+}
+
+// Service contains the business logic for the contact book of the authenticated user
+type Service struct {
+	repo   Repository
+	sender CodeSender
+}
+
+// NewService creates a new instance of the service
+func NewService(repo Repository, sender CodeSender) *Service {
+	return &Service{repo: repo, sender: sender}
+}
+
+// Get returns the contact book of the authenticated user
+func (s *Service) Get(ctx context.Context) (*Profile, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetProfile(ctx, userID)
+}
+
+// AddEmail adds an unverified email and sends it a verification code
+// Adding an address that is pending verification sends a new code, at most once per resendCooldown.
+// Wrong codes still count against the new one while the last one is valid, when they ran out
+// a new code can only be requested once it expires
+func (s *Service) AddEmail(ctx context.Context, req AddEmailRequest) (*Email, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := mail.ParseAddress(req.Address)
+	if err != nil || parsed.Address != strings.TrimSpace(req.Address) {
+		return nil, fmt.Errorf("%w: invalid email address", ErrInvalidContact)
+	}
+	address := strings.ToLower(parsed.Address)
+
+	profile, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	existing := profile.email(address)
+	if existing != nil && existing.Verified {
+		return nil, fmt.Errorf("%w: %s", ErrContactExists, address)
+	}
+
+	now := time.Now()
+	createdAt := now
+	var attempts int
+	if existing != nil {
+		createdAt = existing.CreatedAt
+		if existing.CodeExpiresAt != nil && now.Before(*existing.CodeExpiresAt) {
+			sentAt := existing.CodeExpiresAt.Add(-codeTTL)
+			if now.Before(sentAt.Add(resendCooldown)) || existing.CodeAttempts >= maxCodeAttempts {
+				return nil, ErrCodeThrottled
+			}
+			attempts = existing.CodeAttempts
+		}
+	}
+
+	code, err := generateCode()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := now.Add(codeTTL)
+	email := &Email{
+		Address:       address,
+		CreatedAt:     createdAt,
+		CodeHash:      hashCode(address, code),
+		CodeExpiresAt: &expiresAt,
+		CodeAttempts:  attempts,
+	}
+	if err := s.repo.PutEmail(ctx, userID, email); err != nil {
+		return nil, err
+	}
+	if err := s.sender.SendVerificationCode(ctx, address, code); err != nil {
+		return nil, fmt.Errorf("failed to send verification code: %w", err)
+	}
+	return email, nil
+}
+
+// VerifyEmail marks an email as verified if code is the last code sent to it
+func (s *Service) VerifyEmail(ctx context.Context, req VerifyEmailRequest) (*Email, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	email := profile.email(strings.ToLower(strings.TrimSpace(req.Address)))
+	if email == nil {
+		return nil, ErrContactNotFound
+	}
+	if email.Verified {
+		return email, nil
+	}
+
+	now := time.Now()
+	if email.CodeExpiresAt == nil || now.After(*email.CodeExpiresAt) || email.CodeAttempts >= maxCodeAttempts {
+		return nil, ErrInvalidCode
+	}
+	if subtle.ConstantTimeCompare([]byte(email.CodeHash), []byte(hashCode(email.Address, req.Code))) != 1 {
+		email.CodeAttempts++
+		if err := s.repo.PutEmail(ctx, userID, email); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCode
+	}
+
+	email.Verified = true
+	email.VerifiedAt = &now
+	email.CodeHash = ""
+	email.CodeExpiresAt = nil
+	email.CodeAttempts = 0
+	if err := s.repo.PutEmail(ctx, userID, email); err != nil {
+		return nil, err
+	}
+	return email, nil
+}
+
+// DeleteEmail removes an email of the authenticated user
+func (s *Service) DeleteEmail(ctx context.Context, address string) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteEmail(ctx, userID, strings.ToLower(address))
+}
+
+// PutPhone adds a phone number or changes its carrier
+func (s *Service) PutPhone(ctx context.Context, req PhoneRequest) (*Phone, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !phoneRegexp.MatchString(req.Number) {
+		return nil, fmt.Errorf("%w: number must be in E.164 format", ErrInvalidContact)
+	}
+	phone := &Phone{Number: req.Number, Carrier: req.Carrier, CreatedAt: time.Now()}
+	if err := s.repo.PutPhone(ctx, userID, phone); err != nil {
+		return nil, err
+	}
+	return phone, nil
+}
+
+// DeletePhone removes a phone number of the authenticated user
+func (s *Service) DeletePhone(ctx context.Context, number string) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeletePhone(ctx, userID, number)
+}
+
+// RegisterDevice registers a push device, registering a known token refreshes that device
+func (s *Service) RegisterDevice(ctx context.Context, req DeviceRequest) (*Device, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDevice(req); err != nil {
+		return nil, err
+	}
+	profile, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	device := &Device{ID: uuid.New().String(), CreatedAt: now}
+	for _, d := range profile.Devices {
+		if d.Token == req.Token {
+			device = d
+		}
+	}
+	device.Token = req.Token
+	device.Platform = req.Platform
+	device.UpdatedAt = now
+	if err := s.repo.PutDevice(ctx, userID, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// UpdateDevice replaces the token of a device, push providers rotate them
+func (s *Service) UpdateDevice(ctx context.Context, id string, req DeviceRequest) (*Device, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateDevice(req); err != nil {
+		return nil, err
+	}
+	profile, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	device := profile.device(id)
+	if device == nil {
+		return nil, ErrContactNotFound
+	}
+	device.Token = req.Token
+	device.Platform = req.Platform
+	device.UpdatedAt = time.Now()
+	if err := s.repo.PutDevice(ctx, userID, device); err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// DeleteDevice removes a push device of the authenticated user
+func (s *Service) DeleteDevice(ctx context.Context, id string) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteDevice(ctx, userID, id)
+}
+
+// FillAddress returns meta with the address of channelName taken from the contact book of userID
+// when meta leaves it out. Email uses the oldest verified address, sms the oldest phone and push
+// the device named by meta["device_id"] or the most recently updated one
+// If the book has no suitable contact meta is returned as it is and channel validation reports it
+func (s *Service) FillAddress(ctx context.Context, userID, channelName string, meta map[string]string) (map[string]string, error) {
+	var missing bool
+	switch channelName {
+	case "email":
+		missing = meta["to"] == ""
+	case "sms":
+		missing = meta["phone"] == ""
+	case "push":
+		missing = meta["token"] == ""
+	}
+	if !missing {
+		return meta, nil
+	}
+
+	profile, err := s.repo.GetProfile(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+
+	filled := make(map[string]string, len(meta)+2)
+	for k, v := range meta {
+		filled[k] = v
+	}
+	switch channelName {
+	case "email":
+		for _, e := range profile.Emails {
+			if e.Verified {
+				filled["to"] = e.Address
+				break
+			}
+		}
+	case "sms":
+		if len(profile.Phones) > 0 {
+			filled["phone"] = profile.Phones[0].Number
+			filled["carrier"] = profile.Phones[0].Carrier
+		}
+	case "push":
+		device := profile.device(meta["device_id"])
+		if meta["device_id"] == "" {
+			device = profile.latestDevice()
+		}
+		if device != nil {
+			filled["token"] = device.Token
+			filled["platform"] = device.Platform
+		}
+	}
+	return filled, nil
+}
+
+func (p *Profile) email(address string) *Email {
+	for _, e := range p.Emails {
+		if e.Address == address {
+			return e
+		}
+	}
+	return nil
+}
+
+func (p *Profile) device(id string) *Device {
+	for _, d := range p.Devices {
+		if d.ID == id {
+			return d
+		}
+	}
+	return nil
+}
+
+func (p *Profile) latestDevice() *Device {
+	var latest *Device
+	for _, d := range p.Devices {
+		if latest == nil || d.UpdatedAt.After(latest.UpdatedAt) {
+			latest = d
+		}
+	}
+	return latest
+}
+
+func validateDevice(req DeviceRequest) error {
+	if len(req.Token) < 10 || len(req.Token) > 4096 {
+		return fmt.Errorf("%w: invalid token", ErrInvalidContact)
+	}
+	if !slices.Contains(platforms, req.Platform) {
+		return fmt.Errorf("%w: platform must be one of %s", ErrInvalidContact, strings.Join(platforms, ", "))
+	}
+	return nil
+}
+
+// generateCode returns a random 6 digit code
+func generateCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", fmt.Errorf("failed to generate verification code: %w", err)
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashCode binds the code to its address so a code cannot verify another email
+func hashCode(address, code string) string {
+	sum := sha256.Sum256([]byte(address + ":" + code))
+	return hex.EncodeToString(sum[:])
+}
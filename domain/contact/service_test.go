@@ -0,0 +1,324 @@
+package contact
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+type fakeRepository struct {
+	profiles map[string]*Profile
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{profiles: map[string]*Profile{}}
+}
+
+func (r *fakeRepository) profile(userID string) *Profile {
+	p, ok := r.profiles[userID]
+	if !ok {
+		p = &Profile{}
+		r.profiles[userID] = p
+	}
+	return p
+}
+
+func (r *fakeRepository) GetProfile(ctx context.Context, userID string) (*Profile, error) {
+	p := r.profile(userID)
+	copied := &Profile{}
+	for _, e := range p.Emails {
+		c := *e
+		copied.Emails = append(copied.Emails, &c)
+	}
+	for _, ph := range p.Phones {
+		c := *ph
+		copied.Phones = append(copied.Phones, &c)
+	}
+	for _, d := range p.Devices {
+		c := *d
+		copied.Devices = append(copied.Devices, &c)
+	}
+	return copied, nil
+}
+
+func (r *fakeRepository) PutEmail(ctx context.Context, userID string, e *Email) error {
+	p := r.profile(userID)
+	for i, existing := range p.Emails {
+		if existing.Address == e.Address {
+			p.Emails[i] = e
+			return nil
+		}
+	}
+	p.Emails = append(p.Emails, e)
+	return nil
+}
+
+func (r *fakeRepository) DeleteEmail(ctx context.Context, userID, address string) error {
+	p := r.profile(userID)
+	for i, e := range p.Emails {
+		if e.Address == address {
+			p.Emails = append(p.Emails[:i], p.Emails[i+1:]...)
+			return nil
+		}
+	}
+	return ErrContactNotFound
+}
+
+func (r *fakeRepository) PutPhone(ctx context.Context, userID string, ph *Phone) error {
+	p := r.profile(userID)
+	for i, existing := range p.Phones {
+		if existing.Number == ph.Number {
+			p.Phones[i] = ph
+			return nil
+		}
+	}
+	p.Phones = append(p.Phones, ph)
+	return nil
+}
+
+func (r *fakeRepository) DeletePhone(ctx context.Context, userID, number string) error {
+	p := r.profile(userID)
+	for i, ph := range p.Phones {
+		if ph.Number == number {
+			p.Phones = append(p.Phones[:i], p.Phones[i+1:]...)
+			return nil
+		}
+	}
+	return ErrContactNotFound
+}
+
+func (r *fakeRepository) PutDevice(ctx context.Context, userID string, d *Device) error {
+	p := r.profile(userID)
+	for i, existing := range p.Devices {
+		if existing.ID == d.ID {
+			p.Devices[i] = d
+			return nil
+		}
+	}
+	p.Devices = append(p.Devices, d)
+	return nil
+}
+
+func (r *fakeRepository) DeleteDevice(ctx context.Context, userID, id string) error {
+	p := r.profile(userID)
+	for i, d := range p.Devices {
+		if d.ID == id {
+			p.Devices = append(p.Devices[:i], p.Devices[i+1:]...)
+			return nil
+		}
+	}
+	return ErrContactNotFound
+}
+
+type fakeSender struct {
+	codes map[string]string
+}
+
+func (s *fakeSender) SendVerificationCode(ctx context.Context, address, code string) error {
+	s.codes[address] = code
+	return nil
+}
+
+func asUser(userID string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: userID})
+}
+
+func TestAddEmail_VerifyWithCode(t *testing.T) {
+	sender := &fakeSender{codes: map[string]string{}}
+	s := NewService(newFakeRepository(), sender)
+	ctx := asUser("usr_123")
+
+	email, err := s.AddEmail(ctx, AddEmailRequest{Address: "User@Example.com"})
+	if err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+	if email.Address != "user@example.com" || email.Verified {
+		t.Fatalf("expected an unverified lowercase address, got %+v", email)
+	}
+	code := sender.codes["user@example.com"]
+	if len(code) != 6 {
+		t.Fatalf("expected a 6 digit code to be sent, got %q", code)
+	}
+
+	verified, err := s.VerifyEmail(ctx, VerifyEmailRequest{Address: "user@example.com", Code: code})
+	if err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	if !verified.Verified || verified.VerifiedAt == nil || verified.CodeHash != "" {
+		t.Fatalf("expected email to be verified, got %+v", verified)
+	}
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); !errors.Is(err, ErrContactExists) {
+		t.Fatalf("expected ErrContactExists for a verified address, got %v", err)
+	}
+}
+
+func TestVerifyEmail_LocksAfterTooManyAttempts(t *testing.T) {
+	sender := &fakeSender{codes: map[string]string{}}
+	s := NewService(newFakeRepository(), sender)
+	ctx := asUser("usr_123")
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+
+	for i := 0; i < maxCodeAttempts; i++ {
+		if _, err := s.VerifyEmail(ctx, VerifyEmailRequest{Address: "user@example.com", Code: "wrong"}); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("expected ErrInvalidCode, got %v", err)
+		}
+	}
+	code := sender.codes["user@example.com"]
+	if _, err := s.VerifyEmail(ctx, VerifyEmailRequest{Address: "user@example.com", Code: code}); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected the right code to be rejected once locked, got %v", err)
+	}
+}
+
+func TestAddEmail_ResendIsThrottled(t *testing.T) {
+	repo := newFakeRepository()
+	sender := &fakeSender{codes: map[string]string{}}
+	s := NewService(repo, sender)
+	ctx := asUser("usr_123")
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); !errors.Is(err, ErrCodeThrottled) {
+		t.Fatalf("expected ErrCodeThrottled within the cooldown, got %v", err)
+	}
+
+	// Past the cooldown a new code is sent, the wrong codes so far still count
+	if _, err := s.VerifyEmail(ctx, VerifyEmailRequest{Address: "user@example.com", Code: "wrong"}); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+	sentAt := time.Now().Add(-resendCooldown)
+	expiresAt := sentAt.Add(codeTTL)
+	repo.profiles["usr_123"].Emails[0].CodeExpiresAt = &expiresAt
+	first := sender.codes["user@example.com"]
+	email, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"})
+	if err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+	if email.CodeAttempts != 1 || email.CodeHash == hashCode("user@example.com", first) {
+		t.Fatalf("expected a new code keeping 1 attempt, got %+v", email)
+	}
+}
+
+func TestAddEmail_NoNewCodeAfterTooManyAttempts(t *testing.T) {
+	repo := newFakeRepository()
+	sender := &fakeSender{codes: map[string]string{}}
+	s := NewService(repo, sender)
+	ctx := asUser("usr_123")
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+	pending := repo.profiles["usr_123"].Emails[0]
+	pending.CodeAttempts = maxCodeAttempts
+	sentAt := time.Now().Add(-resendCooldown)
+	expiresAt := sentAt.Add(codeTTL)
+	pending.CodeExpiresAt = &expiresAt
+
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); !errors.Is(err, ErrCodeThrottled) {
+		t.Fatalf("expected ErrCodeThrottled while the locked code is valid, got %v", err)
+	}
+
+	// Once it expires a new code starts over
+	expired := time.Now().Add(-time.Second)
+	pending.CodeExpiresAt = &expired
+	email, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"})
+	if err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+	if email.CodeAttempts != 0 {
+		t.Fatalf("expected the attempts to start over, got %d", email.CodeAttempts)
+	}
+}
+
+func TestVerifyEmail_ExpiredCode(t *testing.T) {
+	repo := newFakeRepository()
+	sender := &fakeSender{codes: map[string]string{}}
+	s := NewService(repo, sender)
+	ctx := asUser("usr_123")
+	if _, err := s.AddEmail(ctx, AddEmailRequest{Address: "user@example.com"}); err != nil {
+		t.Fatalf("AddEmail: %v", err)
+	}
+	expired := time.Now().Add(-time.Minute)
+	repo.profiles["usr_123"].Emails[0].CodeExpiresAt = &expired
+
+	_, err := s.VerifyEmail(ctx, VerifyEmailRequest{Address: "user@example.com", Code: sender.codes["user@example.com"]})
+	if !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode, got %v", err)
+	}
+}
+
+func TestPutPhone_RejectsInvalidNumber(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeSender{})
+
+	if _, err := s.PutPhone(asUser("usr_123"), PhoneRequest{Number: "555-1234", Carrier: "att"}); !errors.Is(err, ErrInvalidContact) {
+		t.Fatalf("expected ErrInvalidContact, got %v", err)
+	}
+}
+
+func TestRegisterDevice_SameTokenUpdatesDevice(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeSender{})
+	ctx := asUser("usr_123")
+
+	first, err := s.RegisterDevice(ctx, DeviceRequest{Token: "token-abcdef", Platform: "ios"})
+	if err != nil {
+		t.Fatalf("RegisterDevice: %v", err)
+	}
+	second, err := s.RegisterDevice(ctx, DeviceRequest{Token: "token-abcdef", Platform: "ios"})
+	if err != nil {
+		t.Fatalf("RegisterDevice: %v", err)
+	}
+
+	if first.ID != second.ID {
+		t.Fatalf("expected the same device, got %s and %s", first.ID, second.ID)
+	}
+	if _, err := s.RegisterDevice(ctx, DeviceRequest{Token: "token-abcdef", Platform: "symbian"}); !errors.Is(err, ErrInvalidContact) {
+		t.Fatalf("expected ErrInvalidContact for an unknown platform, got %v", err)
+	}
+}
+
+func TestFillAddress(t *testing.T) {
+	repo := newFakeRepository()
+	now := time.Now()
+	repo.profiles["usr_123"] = &Profile{
+		Emails: []*Email{
+			{Address: "pending@example.com", CreatedAt: now.Add(-2 * time.Hour)},
+			{Address: "user@example.com", Verified: true, CreatedAt: now.Add(-time.Hour)},
+		},
+		Phones: []*Phone{{Number: "+1234567890", Carrier: "att", CreatedAt: now}},
+		Devices: []*Device{
+			{ID: "d1", Token: "token-old", Platform: "ios", UpdatedAt: now.Add(-time.Hour)},
+			{ID: "d2", Token: "token-new", Platform: "android", UpdatedAt: now},
+		},
+	}
+	s := NewService(repo, &fakeSender{})
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		channel string
+		meta    map[string]string
+		key     string
+		want    string
+	}{
+		{"first verified email", "email", map[string]string{"subject": "Hola"}, "to", "user@example.com"},
+		{"explicit email kept", "email", map[string]string{"to": "other@example.com"}, "to", "other@example.com"},
+		{"oldest phone", "sms", nil, "carrier", "att"},
+		{"latest device", "push", nil, "token", "token-new"},
+		{"named device", "push", map[string]string{"device_id": "d1"}, "token", "token-old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filled, err := s.FillAddress(ctx, "usr_123", tt.channel, tt.meta)
+			if err != nil {
+				t.Fatalf("FillAddress: %v", err)
+			}
+			if filled[tt.key] != tt.want {
+				t.Fatalf("expected %s=%q, got %v", tt.key, tt.want, filled)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package notification
+
+import "time"
+
+// Attempt records a single try to deliver a notification through one of its channels
+type Attempt struct {
+	NotificationID    string    `json:"notification_id"`
+	UserID            string    `json:"-"`
+	Retry             int       `json:"retry"` // 0 for the first try, then one per SQS redelivery
+	Channel           string    `json:"channel"`
+	Step              int       `json:"step"` // 0 for the first channel, then one per fallback
+	Provider          string    `json:"provider"`
+	ProviderMessageID string    `json:"provider_message_id,omitempty"`
+	StartedAt         time.Time `json:"started_at"`
+	EndedAt           time.Time `json:"ended_at"`
+	Error             string    `json:"error,omitempty"`
+}
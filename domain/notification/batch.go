@@ -0,0 +1,290 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+var (
+	ErrBatchNotFound   = errors.New("batch not found")
+	ErrInvalidBatch    = errors.New("invalid batch")
+	ErrBatchesDisabled = errors.New("batches are not enabled")
+)
+
+const (
+	// maxBatchSize bounds the notifications of a batch, they are created within one request
+	maxBatchSize = 1000
+	// batchChunkSize is how many notifications are stored per BatchStore.CreateMany
+	batchChunkSize = 25
+)
+
+// Batch is a set of notifications created by a single request for many users
+// The notifications belong to their recipients, the batch to the sender
+type Batch struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Total     int       `json:"total"`
+	CreatedAt time.Time `json:"created_at"`
+	// Counts is how many notifications of the batch are in each status,
+	// the ones that could not be stored count as failed
+	Counts map[Status]int `json:"counts"`
+	// Unstored is how many notifications could not be stored, so Total matches the counts
+	Unstored int `json:"-"`
+	// Errors are the notifications that could not be stored or queued,
+	// only in the response of the request creating the batch
+	Errors []BatchError `json:"errors,omitempty"`
+}
+
+// BatchError is why a notification of a batch could not be stored or queued
+type BatchError struct {
+	Index  int    `json:"index"` // position of the notification in the request
+	UserID string `json:"user_id"`
+	Error  string `json:"error"`
+}
+
+func (b *Batch) fail(index int, userID, reason string) {
+	b.Errors = append(b.Errors, BatchError{Index: index, UserID: userID, Error: reason})
+}
+
+// BatchRequest creates a different notification for each item
+type BatchRequest struct {
+	Notifications []BatchItem `json:"notifications" binding:"required,min=1,max=1000,dive"`
+}
+
+// BatchItem is a notification of a BatchRequest and the user it is for
+type BatchItem struct {
+	UserID string `json:"user_id" binding:"required"`
+	CreateRequest
+}
+
+// BroadcastRequest sends the same notification to every user in UserIDs,
+// the channel, meta and template are shared and each address comes from the user's contacts
+type BroadcastRequest struct {
+	UserIDs []string `json:"user_ids" binding:"required,min=1,max=1000,dive,required"`
+	CreateRequest
+}
+
+// BatchStore stores batches and the notifications they fan out to
+type BatchStore interface {
+	CreateBatch(ctx context.Context, b *Batch) error
+	// GetBatch returns the batch without its counts
+	GetBatch(ctx context.Context, id string) (*Batch, error)
+	// CreateMany stores up to 25 notifications at once
+	CreateMany(ctx context.Context, notifications []*Notification) error
+	// CountBatch returns how many notifications of the batch are in each status
+	CountBatch(ctx context.Context, id string) (map[Status]int, error)
+	// AddUnstored records that count notifications of the batch could not be stored
+	AddUnstored(ctx context.Context, id string, count int) error
+}
+
+// BatchQueue publishes many messages per request
+type BatchQueue interface {
+	// PublishBatch returns the error of every message that was not published, by notification ID
+	PublishBatch(ctx context.Context, messages []*DispatchMessage) map[string]error
+}
+
+// EnableBatches makes CreateBatch and Broadcast fan out through store and queue
+// Batches are published directly, they do not go through the outbox
+func (s *Service) EnableBatches(store BatchStore, queue BatchQueue) {
+	s.batches = store
+	s.batchQueue = queue
+}
+
+// batchEntry is one notification to fan out and its recipient
+type batchEntry struct {
+	index  int // position in the request
+	userID string
+	req    CreateRequest
+}
+
+// CreateBatch creates the notification of every item for the user of the item
+func (s *Service) CreateBatch(ctx context.Context, req BatchRequest) (*Batch, error) {
+	entries := make([]batchEntry, len(req.Notifications))
+	for i, item := range req.Notifications {
+		entries[i] = batchEntry{index: i, userID: item.UserID, req: item.CreateRequest}
+	}
+	return s.fanOut(ctx, entries)
+}
+
+// Broadcast creates the same notification for every user of the request,
+// a user listed twice gets it once
+func (s *Service) Broadcast(ctx context.Context, req BroadcastRequest) (*Batch, error) {
+	var entries []batchEntry
+	seen := make(map[string]bool, len(req.UserIDs))
+	for i, userID := range req.UserIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		entries = append(entries, batchEntry{index: i, userID: userID, req: req.CreateRequest})
+	}
+	return s.fanOut(ctx, entries)
+}
+
+// GetBatch returns a batch of the authenticated user with its progress
+func (s *Service) GetBatch(ctx context.Context, id string) (*Batch, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if s.batches == nil {
+		return nil, ErrBatchesDisabled
+	}
+	batch, err := s.batches.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if batch.UserID != userID {
+		return nil, ErrBatchNotFound
+	}
+	counts, err := s.batches.CountBatch(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count batch: %w", err)
+	}
+	if batch.Unstored > 0 {
+		counts[StatusFailed] += batch.Unstored
+	}
+	batch.Counts = counts
+	return batch, nil
+}
+
+// fanOut stores the batch and then its notifications in chunks, publishing each chunk
+// once it is stored and marking its notifications as queued. Requests are checked before
+// anything is stored, so an invalid one rejects the whole batch, while a recipient that
+// cannot receive it (e.g. no address) gets a failed notification with the reason
+// A chunk that cannot be stored or published does not stop the rest, since retrying the
+// request would send the earlier chunks again. Its notifications count as failed and are
+// listed in Errors, it only fails when none of the notifications could be stored
+func (s *Service) fanOut(ctx context.Context, entries []batchEntry) (*Batch, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+	if s.batches == nil {
+		return nil, ErrBatchesDisabled
+	}
+	if len(entries) == 0 || len(entries) > maxBatchSize {
+		return nil, fmt.Errorf("%w: a batch has between 1 and %d notifications", ErrInvalidBatch, maxBatchSize)
+	}
+
+	now := time.Now()
+	schedules := make([]*time.Time, len(entries))
+	for i, entry := range entries {
+		scheduledAt, err := s.checkRequest(principal, entry.req, now)
+		if err != nil {
+			return nil, fmt.Errorf("notification %d: %w", i, err)
+		}
+		schedules[i] = scheduledAt
+	}
+
+	batch := &Batch{
+		ID:        generateID(),
+		UserID:    principal.UserID,
+		Total:     len(entries),
+		CreatedAt: now,
+		Counts:    make(map[Status]int),
+	}
+	if err := s.batches.CreateBatch(ctx, batch); err != nil {
+		return nil, fmt.Errorf("failed to create batch: %w", err)
+	}
+
+	var storeErr error
+	for start := 0; start < len(entries); start += batchChunkSize {
+		end := min(start+batchChunkSize, len(entries))
+		notifications := s.buildChunk(ctx, principal.UserID, entries[start:end], schedules[start:end], now)
+		for _, n := range notifications {
+			n.BatchID = batch.ID
+		}
+		if err := s.batches.CreateMany(ctx, notifications); err != nil {
+			storeErr = err
+			batch.Unstored += len(notifications)
+			for i, n := range notifications {
+				batch.fail(entries[start+i].index, n.UserID, "failed to store: "+err.Error())
+			}
+			continue
+		}
+		failed := s.publishChunk(ctx, notifications)
+		for i, n := range notifications {
+			if publishErr, ok := failed[n.ID]; ok {
+				batch.fail(entries[start+i].index, n.UserID, "failed to enqueue: "+publishErr.Error())
+			}
+			batch.Counts[n.Status]++
+		}
+	}
+	if batch.Unstored == 0 {
+		return batch, nil
+	}
+	if batch.Unstored == batch.Total {
+		return nil, fmt.Errorf("failed to create the notifications of batch %s: %w", batch.ID, storeErr)
+	}
+	if err := s.batches.AddUnstored(ctx, batch.ID, batch.Unstored); err != nil {
+		log.Printf("Failed to record the unstored notifications of batch %s: %v", batch.ID, err)
+	}
+	return batch, nil
+}
+
+// buildChunk builds the notifications senderID requested for entries concurrently,
+// since each one reads the contacts and preferences of its recipient
+func (s *Service) buildChunk(ctx context.Context, senderID string, entries []batchEntry, schedules []*time.Time, now time.Time) []*Notification {
+	notifications := make([]*Notification, len(entries))
+	var wg sync.WaitGroup
+	for i, entry := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := s.build(ctx, senderID, entry.userID, entry.req, schedules[i], now)
+			if err != nil {
+				n = newNotification(entry.userID, entry.req, now)
+				n.Status = StatusFailed
+				n.FailedAt = &now
+				n.FailureReason = err.Error()
+			}
+			notifications[i] = n
+		}()
+	}
+	wg.Wait()
+	return notifications
+}
+
+// publishChunk publishes the pending notifications and marks them as queued, the ones the
+// queue rejects are marked as failed and returned with their error by notification ID
+func (s *Service) publishChunk(ctx context.Context, notifications []*Notification) map[string]error {
+	var messages []*DispatchMessage
+	for _, n := range notifications {
+		if n.Status == StatusPending {
+			message := n.dispatchMessage()
+			messages = append(messages, &message)
+		}
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	failed := s.batchQueue.PublishBatch(ctx, messages)
+	var wg sync.WaitGroup
+	for _, n := range notifications {
+		if n.Status != StatusPending {
+			continue
+		}
+		status, reason := StatusQueued, ""
+		if publishErr, ok := failed[n.ID]; ok {
+			status, reason = StatusFailed, "failed to enqueue: "+publishErr.Error()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// The dispatcher may have already moved it forward, then it is left as it is
+			err := s.transition(ctx, n, status, reason)
+			if err != nil && !errors.Is(err, ErrInvalidTransition) && !errors.Is(err, ErrStatusChanged) {
+				log.Printf("Failed to mark notification %s as %s: %v", n.ID, status, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return failed
+}
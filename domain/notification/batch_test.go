@@ -0,0 +1,315 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type fakeBatchStore struct {
+	batches    map[string]*Batch
+	repo       *fakeRepository
+	writes     []int        // size of every CreateMany
+	failWrites map[int]bool // CreateMany calls that fail, by position
+}
+
+func newFakeBatchStore(repo *fakeRepository) *fakeBatchStore {
+	return &fakeBatchStore{batches: map[string]*Batch{}, repo: repo}
+}
+
+func (s *fakeBatchStore) CreateBatch(ctx context.Context, b *Batch) error {
+	stored := *b
+	s.batches[b.ID] = &stored
+	return nil
+}
+
+func (s *fakeBatchStore) GetBatch(ctx context.Context, id string) (*Batch, error) {
+	b, ok := s.batches[id]
+	if !ok {
+		return nil, ErrBatchNotFound
+	}
+	stored := *b
+	return &stored, nil
+}
+
+func (s *fakeBatchStore) CreateMany(ctx context.Context, notifications []*Notification) error {
+	s.writes = append(s.writes, len(notifications))
+	if s.failWrites[len(s.writes)-1] {
+		return errors.New("throttled")
+	}
+	for _, n := range notifications {
+		s.repo.notifications[n.ID] = n
+	}
+	return nil
+}
+
+func (s *fakeBatchStore) CountBatch(ctx context.Context, id string) (map[Status]int, error) {
+	counts := map[Status]int{}
+	for _, n := range s.repo.notifications {
+		if n.BatchID == id {
+			counts[n.Status]++
+		}
+	}
+	return counts, nil
+}
+
+func (s *fakeBatchStore) AddUnstored(ctx context.Context, id string, count int) error {
+	s.batches[id].Unstored += count
+	return nil
+}
+
+// fakeBatchQueue rejects the messages of the users in failUsers
+type fakeBatchQueue struct {
+	published []*DispatchMessage
+	failUsers map[string]bool
+}
+
+func (q *fakeBatchQueue) PublishBatch(ctx context.Context, messages []*DispatchMessage) map[string]error {
+	failed := map[string]error{}
+	for _, m := range messages {
+		if q.failUsers[m.UserID] {
+			failed[m.NotificationID] = errors.New("throttled")
+			continue
+		}
+		q.published = append(q.published, m)
+	}
+	return failed
+}
+
+// addressBook knows the email address of some users
+type addressBook map[string]string
+
+func (b addressBook) FillAddress(ctx context.Context, userID, channelName string, meta map[string]string) (map[string]string, error) {
+	filled := map[string]string{}
+	for k, v := range meta {
+		filled[k] = v
+	}
+	if address, ok := b[userID]; ok {
+		filled["to"] = address
+	}
+	return filled, nil
+}
+
+// addressedChannel requires meta["to"]
+type addressedChannel struct {
+	stubChannel
+}
+
+func (c *addressedChannel) Validate(meta map[string]string) error {
+	if meta["to"] == "" {
+		errs := &ValidationError{}
+		errs.Add("to", "to is required")
+		return errs
+	}
+	return nil
+}
+
+func newBatchService(book addressBook) (*Service, *fakeRepository, *fakeBatchStore, *fakeBatchQueue) {
+	repo := newFakeRepository()
+	store := newFakeBatchStore(repo)
+	queue := &fakeBatchQueue{failUsers: map[string]bool{}}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry(&addressedChannel{stubChannel{name: "email"}}))
+	s.EnableContactBook(book)
+	s.EnableBatches(store, queue)
+	return s, repo, store, queue
+}
+
+func TestBroadcast_FansOutInChunks(t *testing.T) {
+	book := addressBook{}
+	var userIDs []string
+	for i := 0; i < 30; i++ {
+		userID := fmt.Sprintf("usr_%d", i)
+		book[userID] = userID + "@example.com"
+		userIDs = append(userIDs, userID)
+	}
+	userIDs = append(userIDs, "usr_0") // listed twice, sent once
+	s, repo, store, queue := newBatchService(book)
+
+	batch, err := s.Broadcast(asUser("usr_ops"), BroadcastRequest{
+		UserIDs: userIDs,
+		CreateRequest: CreateRequest{
+			Title:       "Maintenance",
+			Content:     "Tonight at 22:00",
+			ChannelName: "email",
+			Meta:        map[string]string{"template": "titled"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	if batch.Total != 30 || batch.Counts[StatusQueued] != 30 {
+		t.Fatalf("expected 30 queued notifications, got total %d counts %v", batch.Total, batch.Counts)
+	}
+	if len(store.writes) != 2 || store.writes[0] != 25 || store.writes[1] != 5 {
+		t.Fatalf("expected chunks of 25 and 5, got %v", store.writes)
+	}
+	if len(queue.published) != 30 {
+		t.Fatalf("expected 30 messages to be published, got %d", len(queue.published))
+	}
+	for _, n := range repo.notifications {
+		if n.BatchID != batch.ID || n.Meta["to"] != book[n.UserID] || n.Meta["template"] != "titled" || n.Status != StatusQueued {
+			t.Fatalf("expected notification of the batch addressed to its user, got %+v", n)
+		}
+	}
+	if _, ok := store.batches[batch.ID]; !ok || store.batches[batch.ID].UserID != "usr_ops" {
+		t.Fatalf("expected batch to be stored for the sender, got %v", store.batches)
+	}
+}
+
+func TestBroadcast_RecipientWithoutAddressFails(t *testing.T) {
+	s, repo, _, queue := newBatchService(addressBook{"usr_1": "one@example.com"})
+
+	batch, err := s.Broadcast(asUser("usr_ops"), BroadcastRequest{
+		UserIDs:       []string{"usr_1", "usr_2"},
+		CreateRequest: CreateRequest{Title: "Hola", Content: "Mundo", ChannelName: "email"},
+	})
+	if err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	if batch.Counts[StatusQueued] != 1 || batch.Counts[StatusFailed] != 1 {
+		t.Fatalf("expected one queued and one failed, got %v", batch.Counts)
+	}
+	if len(queue.published) != 1 || queue.published[0].UserID != "usr_1" {
+		t.Fatalf("expected only usr_1 to be published, got %v", queue.published)
+	}
+	for _, n := range repo.notifications {
+		if n.UserID == "usr_2" && (n.Status != StatusFailed || n.FailureReason == "") {
+			t.Fatalf("expected usr_2 to fail with a reason, got %s %q", n.Status, n.FailureReason)
+		}
+	}
+}
+
+func TestCreateBatch_InvalidItemStoresNothing(t *testing.T) {
+	s, repo, store, _ := newBatchService(addressBook{"usr_1": "one@example.com"})
+
+	_, err := s.CreateBatch(asUser("usr_ops"), BatchRequest{Notifications: []BatchItem{
+		{UserID: "usr_1", CreateRequest: CreateRequest{Title: "a", Content: "b", ChannelName: "email"}},
+		{UserID: "usr_1", CreateRequest: CreateRequest{Title: "a", Content: "b", ChannelName: "fax"}},
+	}})
+	if !errors.Is(err, ErrInvalidChannel) || !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrUnknownChannel, got %v", err)
+	}
+	if len(store.batches) != 0 || len(repo.notifications) != 0 {
+		t.Fatal("expected nothing to be stored")
+	}
+}
+
+func TestCreateBatch_PublishFailureMarksFailed(t *testing.T) {
+	s, repo, _, queue := newBatchService(addressBook{"usr_1": "one@example.com", "usr_2": "two@example.com"})
+	queue.failUsers["usr_2"] = true
+
+	batch, err := s.CreateBatch(asUser("usr_ops"), BatchRequest{Notifications: []BatchItem{
+		{UserID: "usr_1", CreateRequest: CreateRequest{Title: "Hola", Content: "uno", ChannelName: "email"}},
+		{UserID: "usr_2", CreateRequest: CreateRequest{Title: "Hola", Content: "dos", ChannelName: "email"}},
+	}})
+	if err != nil {
+		t.Fatalf("CreateBatch: %v", err)
+	}
+
+	got, err := s.GetBatch(asUser("usr_ops"), batch.ID)
+	if err != nil {
+		t.Fatalf("GetBatch: %v", err)
+	}
+	if got.Total != 2 || got.Counts[StatusQueued] != 1 || got.Counts[StatusFailed] != 1 {
+		t.Fatalf("expected one queued and one failed, got total %d counts %v", got.Total, got.Counts)
+	}
+	if len(batch.Errors) != 1 || batch.Errors[0].Index != 1 || batch.Errors[0].UserID != "usr_2" {
+		t.Fatalf("expected the enqueue failure to be reported, got %+v", batch.Errors)
+	}
+	for _, n := range repo.notifications {
+		if n.UserID == "usr_2" && n.FailureReason != "failed to enqueue: throttled" {
+			t.Fatalf("expected enqueue failure reason, got %q", n.FailureReason)
+		}
+	}
+}
+
+func TestBroadcast_ChunkStoreFailureIsReported(t *testing.T) {
+	book := addressBook{}
+	var userIDs []string
+	for i := 0; i < 30; i++ {
+		userID := fmt.Sprintf("usr_%d", i)
+		book[userID] = userID + "@example.com"
+		userIDs = append(userIDs, userID)
+	}
+	s, _, store, queue := newBatchService(book)
+	store.failWrites = map[int]bool{1: true}
+
+	batch, err := s.Broadcast(asUser("usr_ops"), BroadcastRequest{
+		UserIDs:       userIDs,
+		CreateRequest: CreateRequest{Title: "Hola", Content: "Mundo", ChannelName: "email"},
+	})
+	if err != nil {
+		t.Fatalf("expected the published chunk to be reported, not an error, got %v", err)
+	}
+	if len(queue.published) != 25 || batch.Counts[StatusQueued] != 25 {
+		t.Fatalf("expected the first chunk to be queued, got %d published and %v", len(queue.published), batch.Counts)
+	}
+	if len(batch.Errors) != 5 || batch.Errors[0].Index != 25 || batch.Errors[0].UserID != "usr_25" {
+		t.Fatalf("expected the unstored chunk to be reported, got %+v", batch.Errors)
+	}
+
+	got, err := s.GetBatch(asUser("usr_ops"), batch.ID)
+	if err != nil {
+		t.Fatalf("GetBatch: %v", err)
+	}
+	if got.Counts[StatusQueued] != 25 || got.Counts[StatusFailed] != 5 {
+		t.Fatalf("expected the progress to add up to the total, got %v", got.Counts)
+	}
+}
+
+func TestCreateBatch_NothingStoredFails(t *testing.T) {
+	s, _, store, queue := newBatchService(addressBook{"usr_1": "one@example.com"})
+	store.failWrites = map[int]bool{0: true}
+
+	_, err := s.CreateBatch(asUser("usr_ops"), BatchRequest{Notifications: []BatchItem{
+		{UserID: "usr_1", CreateRequest: CreateRequest{Title: "Hola", Content: "uno", ChannelName: "email"}},
+	}})
+	if err == nil {
+		t.Fatal("expected an error when nothing was stored")
+	}
+	if len(queue.published) != 0 {
+		t.Fatal("expected nothing to be published")
+	}
+}
+
+func TestBroadcast_SenderOwnsTheResources(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeBatchQueue{}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry(&ownedChannel{stubChannel{name: "email"}}))
+	s.EnableBatches(newFakeBatchStore(repo), queue)
+
+	batch, err := s.Broadcast(asUser("usr_ops"), BroadcastRequest{
+		UserIDs:       []string{"usr_1", "usr_2"},
+		CreateRequest: CreateRequest{Title: "Hola", Content: "Mundo", ChannelName: "email", Meta: map[string]string{"key": "usr_ops/report.pdf"}},
+	})
+	if err != nil {
+		t.Fatalf("Broadcast: %v", err)
+	}
+	if batch.Counts[StatusQueued] != 2 {
+		t.Fatalf("expected the key of the sender to be accepted for every recipient, got %v", batch.Counts)
+	}
+	for _, m := range queue.published {
+		if m.SenderID != "usr_ops" || m.ToMessage().Owner() != "usr_ops" {
+			t.Fatalf("expected the sender to own the message, got %+v", m)
+		}
+	}
+}
+
+func TestGetBatch_OtherUserIsNotFound(t *testing.T) {
+	s, _, store, _ := newBatchService(addressBook{})
+	store.batches["b1"] = &Batch{ID: "b1", UserID: "usr_ops", Total: 1}
+
+	if _, err := s.GetBatch(asUser("usr_other"), "b1"); !errors.Is(err, ErrBatchNotFound) {
+		t.Fatalf("expected ErrBatchNotFound, got %v", err)
+	}
+}
+
+func TestBroadcast_BatchesDisabled(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry(&stubChannel{name: "email"}))
+
+	_, err := s.Broadcast(asUser("usr_ops"), BroadcastRequest{UserIDs: []string{"usr_1"}, CreateRequest: CreateRequest{ChannelName: "email"}})
+	if !errors.Is(err, ErrBatchesDisabled) {
+		t.Fatalf("expected ErrBatchesDisabled, got %v", err)
+	}
+}
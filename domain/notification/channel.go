@@ -0,0 +1,121 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var ErrUnknownChannel = errors.New("unknown channel")
+
+// Channel is the contract every delivery channel (email, sms, push) implements
+type Channel interface {
+	Name() string
+	Validate(meta map[string]string) error
+	Prepare(ctx context.Context, msg *Message) error
+	Send(ctx context.Context, msg Message) (Receipt, error)
+}
+
+// Receipt identifies who handled a send and how they refer to the message
+type Receipt struct {
+	Provider          string
+	ProviderMessageID string
+}
+
+// ChannelRegistry maps channel names to their implementations
+// It also implements ChannelValidator by delegating to the named channel
+type ChannelRegistry struct {
+	channels map[string]Channel
+}
+
+// NewChannelRegistry creates a registry with the given channels
+func NewChannelRegistry(channels ...Channel) *ChannelRegistry {
+	r := &ChannelRegistry{channels: make(map[string]Channel, len(channels))}
+	for _, c := range channels {
+		r.Register(c)
+	}
+	return r
+}
+
+// Register adds a channel, replacing any channel with the same name
+func (r *ChannelRegistry) Register(c Channel) {
+	r.channels[c.Name()] = c
+}
+
+// Get returns the channel registered under name
+func (r *ChannelRegistry) Get(name string) (Channel, error) {
+	c, ok := r.channels[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownChannel, name)
+	}
+	return c, nil
+}
+
+// Validate validates the metadata with the channel registered under channelName
+func (r *ChannelRegistry) Validate(channelName string, meta map[string]string) error {
+	c, err := r.Get(channelName)
+	if err != nil {
+		return err
+	}
+	return c.Validate(meta)
+}
+
+// OwnerValidator is implemented by channels whose meta references stored resources,
+// ValidateOwner rejects meta referencing resources that userID does not own
+type OwnerValidator interface {
+	ValidateOwner(userID string, meta map[string]string) error
+}
+
+// ValidateOwner applies the ownership rules of the channel registered under channelName
+// Channels without rules accept any meta
+func (r *ChannelRegistry) ValidateOwner(channelName, userID string, meta map[string]string) error {
+	c, err := r.Get(channelName)
+	if err != nil {
+		return err
+	}
+	if owner, ok := c.(OwnerValidator); ok {
+		return owner.ValidateOwner(userID, meta)
+	}
+	return nil
+}
+
+// Close closes the channels that keep connections open, the ones implementing io.Closer
+// They open new connections on their next Send
+func (r *ChannelRegistry) Close() error {
+	var errs []error
+	for name, c := range r.channels {
+		if closer, ok := c.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("failed to close channel %s: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// MetaRedactor is implemented by channels whose meta holds personal data,
+// RedactMeta returns a copy of meta that is safe to show in API responses
+type MetaRedactor interface {
+	RedactMeta(meta map[string]string) map[string]string
+}
+
+// RedactMeta applies the redaction policy of the channel registered under channelName
+// Channels without a policy get a plain copy, unknown channels get no meta at all
+func (r *ChannelRegistry) RedactMeta(channelName string, meta map[string]string) map[string]string {
+	if meta == nil {
+		return nil
+	}
+	c, err := r.Get(channelName)
+	if err != nil {
+		return nil
+	}
+	if redactor, ok := c.(MetaRedactor); ok {
+		return redactor.RedactMeta(meta)
+	}
+	redacted := make(map[string]string, len(meta))
+	for k, v := range meta {
+		redacted[k] = v
+	}
+	return redacted
+}
@@ -0,0 +1,113 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type stubChannel struct {
+	name        string
+	validateErr error
+}
+
+func (c *stubChannel) Name() string                                    { return c.name }
+func (c *stubChannel) Validate(meta map[string]string) error           { return c.validateErr }
+func (c *stubChannel) Prepare(ctx context.Context, msg *Message) error { return nil }
+func (c *stubChannel) Send(ctx context.Context, msg Message) (Receipt, error) {
+	return Receipt{}, nil
+}
+
+// ownedChannel accepts the meta whose "key" is under the prefix of the user
+type ownedChannel struct{ stubChannel }
+
+func (c *ownedChannel) ValidateOwner(userID string, meta map[string]string) error {
+	if !strings.HasPrefix(meta["key"], userID+"/") {
+		errs := &ValidationError{}
+		errs.Add("key", "not owned")
+		return errs
+	}
+	return nil
+}
+
+func TestChannelRegistry_Get(t *testing.T) {
+	email := &stubChannel{name: "email"}
+	r := NewChannelRegistry(email)
+
+	got, err := r.Get("email")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got != email {
+		t.Fatalf("expected registered channel, got %v", got)
+	}
+}
+
+func TestChannelRegistry_GetUnknown(t *testing.T) {
+	r := NewChannelRegistry(&stubChannel{name: "email"})
+
+	if _, err := r.Get("fax"); !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrUnknownChannel, got %v", err)
+	}
+}
+
+func TestChannelRegistry_RegisterNewChannel(t *testing.T) {
+	r := NewChannelRegistry()
+	r.Register(&stubChannel{name: "webhook"})
+
+	if err := r.Validate("webhook", nil); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestChannelRegistry_ValidateDelegates(t *testing.T) {
+	validateErr := errors.New("to field is required")
+	r := NewChannelRegistry(&stubChannel{name: "email", validateErr: validateErr})
+
+	if err := r.Validate("email", map[string]string{}); !errors.Is(err, validateErr) {
+		t.Fatalf("expected channel validation error, got %v", err)
+	}
+}
+
+func TestChannelRegistry_RedactMeta(t *testing.T) {
+	r := NewChannelRegistry(&stubChannel{name: "webhook"})
+	meta := map[string]string{"url": "https://example.com/hook"}
+
+	copied := r.RedactMeta("webhook", meta)
+	if copied["url"] != meta["url"] {
+		t.Fatalf("expected meta without a policy to be copied, got %v", copied)
+	}
+	copied["url"] = "changed"
+	if meta["url"] != "https://example.com/hook" {
+		t.Fatal("expected the copy not to share the original map")
+	}
+	if got := r.RedactMeta("fax", meta); got != nil {
+		t.Fatalf("expected no meta for an unknown channel, got %v", got)
+	}
+}
+
+type closingChannel struct {
+	stubChannel
+	closeErr error
+	closes   int
+}
+
+func (c *closingChannel) Close() error {
+	c.closes++
+	return c.closeErr
+}
+
+func TestChannelRegistry_CloseClosesClosers(t *testing.T) {
+	email := &closingChannel{stubChannel: stubChannel{name: "email"}}
+	sms := &closingChannel{stubChannel: stubChannel{name: "sms"}, closeErr: errors.New("connection reset")}
+	r := NewChannelRegistry(email, sms, &stubChannel{name: "push"})
+
+	err := r.Close()
+	if err == nil || !strings.Contains(err.Error(), "sms") {
+		t.Fatalf("expected the sms close error, got %v", err)
+	}
+	if email.closes != 1 || sms.closes != 1 {
+		t.Fatalf("expected every closer to be closed once, got email %d sms %d", email.closes, sms.closes)
+	}
+}
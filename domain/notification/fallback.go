@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// maxFallbacks bounds how many channels are tried after the first one
+const maxFallbacks = 4
+
+// ChannelStep is one channel tried while delivering a notification
+type ChannelStep struct {
+	Channel string    `json:"channel"`
+	At      time.Time `json:"at"`
+	Error   string    `json:"error,omitempty"` // empty for the channel that delivered it
+}
+
+// Channels returns the channels of the notification in the order they are tried
+func (n *Notification) Channels() []string {
+	return append([]string{n.ChannelName}, n.Fallback...)
+}
+
+// Channels returns the channels of the message in the order they are tried
+func (m *DispatchMessage) Channels() []string {
+	return append([]string{m.ChannelName}, m.Fallback...)
+}
+
+// checkChain rejects unknown and repeated channels and chains that are too long
+func checkChain(validator ChannelValidator, channels []string) error {
+	if len(channels) > maxFallbacks+1 {
+		errs := &ValidationError{}
+		errs.Add("fallback", fmt.Sprintf("at most %d fallback channels are allowed", maxFallbacks))
+		return errs
+	}
+	for i, channel := range channels {
+		if slices.Contains(channels[:i], channel) {
+			errs := &ValidationError{}
+			errs.Add("fallback", "channel "+channel+" is repeated")
+			return errs
+		}
+		// Only the unknown channel error matters here, meta is validated by validateChain
+		if err := validator.Validate(channel, nil); errors.Is(err, ErrUnknownChannel) {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateChain validates meta of the notification of userID against every channel of the chain
+// Unknown and repeated channels are always rejected, invalid meta only when no
+// channel can use it, since the dispatcher skips the channels it does not suit
+func validateChain(validator ChannelValidator, channels []string, userID string, meta map[string]string) error {
+	if err := checkChain(validator, channels); err != nil {
+		return err
+	}
+	var firstErr error
+	for _, channel := range channels {
+		err := validator.Validate(channel, meta)
+		if err == nil {
+			err = validator.ValidateOwner(channel, userID, meta)
+		}
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
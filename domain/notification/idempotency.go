@@ -0,0 +1,122 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+var ErrIdempotencyKeyReused = errors.New("idempotency key already used with a different request")
+
+// idempotencyLockTimeout outlasts the API timeout, a reservation still in progress
+// after it was abandoned by a request that crashed and can be taken over
+const idempotencyLockTimeout = time.Minute
+
+// IdempotencyRecord remembers the response of a create made with an Idempotency-Key
+// Records are scoped to the caller, two users can use the same key
+type IdempotencyRecord struct {
+	Key         string
+	UserID      string
+	RequestHash string
+	Response    *Notification // nil while the first request is still in progress
+	CreatedAt   time.Time
+	LockedUntil time.Time // when a reservation still in progress is considered abandoned
+}
+
+// Abandoned reports whether the record is a reservation whose request never completed
+func (r *IdempotencyRecord) Abandoned(now time.Time) bool {
+	return r.Response == nil && !now.Before(r.LockedUntil)
+}
+
+// IdempotencyStore persists idempotency records, expiring them after a while
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey stores record unless the caller already used its key,
+	// in that case it returns the existing record and ErrDuplicateNotification
+	// An abandoned reservation is replaced as if it did not exist
+	ReserveIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores record.Response so replays can return it
+	CompleteIdempotencyKey(ctx context.Context, record *IdempotencyRecord) error
+	// ReleaseIdempotencyKey removes the reservation of a failed request so it can be retried
+	ReleaseIdempotencyKey(ctx context.Context, record *IdempotencyRecord) error
+}
+
+// EnableIdempotency makes CreateIdempotent deduplicate creates by their key
+func (s *Service) EnableIdempotency(store IdempotencyStore) {
+	s.idempotency = store
+}
+
+// CreateIdempotent creates a notification once per caller and key
+// Replaying the same request returns the notification of the first one, reusing the key
+// with another request fails with ErrIdempotencyKeyReused and a replay that arrives while
+// the first request is in progress fails with ErrDuplicateNotification
+// Without a key, or when idempotency is not enabled, it behaves like Create
+func (s *Service) CreateIdempotent(ctx context.Context, key string, req CreateRequest) (*Notification, error) {
+	if key == "" || s.idempotency == nil {
+		return s.Create(ctx, req)
+	}
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hashRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	record := &IdempotencyRecord{
+		Key:         key,
+		UserID:      userID,
+		RequestHash: hash,
+		CreatedAt:   now,
+		LockedUntil: now.Add(idempotencyLockTimeout),
+	}
+
+	existing, err := s.idempotency.ReserveIdempotencyKey(ctx, record)
+	if errors.Is(err, ErrDuplicateNotification) {
+		switch {
+		case existing.RequestHash != hash:
+			return nil, ErrIdempotencyKeyReused
+		case existing.Response == nil:
+			return nil, fmt.Errorf("%w: a request with the same idempotency key is in progress", ErrDuplicateNotification)
+		}
+		return existing.Response, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	created, err := s.Create(ctx, req)
+	if err != nil {
+		if releaseErr := s.idempotency.ReleaseIdempotencyKey(ctx, record); releaseErr != nil {
+			return nil, errors.Join(err, fmt.Errorf("failed to release idempotency key: %w", releaseErr))
+		}
+		return nil, err
+	}
+
+	// The notification exists at this point, failing would only make the client retry
+	// into a 409 until the reservation is abandoned, so a failed completion is only logged
+	record.Response = created
+	if err := s.idempotency.CompleteIdempotencyKey(ctx, record); err != nil {
+		log.Printf("Failed to complete idempotency key %s of notification %s: %v", key, created.ID, err)
+	}
+
+	return created, nil
+}
+
+// hashRequest fingerprints a create request, map keys are sorted by encoding/json
+func hashRequest(req CreateRequest) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash request: %w", err)
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:]), nil
+}
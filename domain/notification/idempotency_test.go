@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type fakeIdempotencyStore struct {
+	records map[string]*IdempotencyRecord
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{records: map[string]*IdempotencyRecord{}}
+}
+
+func (s *fakeIdempotencyStore) ReserveIdempotencyKey(ctx context.Context, record *IdempotencyRecord) (*IdempotencyRecord, error) {
+	id := record.UserID + "/" + record.Key
+	if existing, ok := s.records[id]; ok && !existing.Abandoned(record.CreatedAt) {
+		return existing, ErrDuplicateNotification
+	}
+	reserved := *record
+	s.records[id] = &reserved
+	return nil, nil
+}
+
+func (s *fakeIdempotencyStore) CompleteIdempotencyKey(ctx context.Context, record *IdempotencyRecord) error {
+	s.records[record.UserID+"/"+record.Key].Response = record.Response
+	return nil
+}
+
+func (s *fakeIdempotencyStore) ReleaseIdempotencyKey(ctx context.Context, record *IdempotencyRecord) error {
+	delete(s.records, record.UserID+"/"+record.Key)
+	return nil
+}
+
+func newIdempotentService(repo *fakeRepository, store *fakeIdempotencyStore) *Service {
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry(&stubChannel{name: "email"}))
+	s.EnableIdempotency(store)
+	return s
+}
+
+func TestCreateIdempotent_ReplayReturnsFirstResponse(t *testing.T) {
+	repo := newFakeRepository()
+	s := newIdempotentService(repo, newFakeIdempotencyStore())
+	req := CreateRequest{ChannelName: "email", Title: "Hola", Content: "Mundo"}
+
+	first, err := s.CreateIdempotent(asUser("u1"), "key-1", req)
+	if err != nil {
+		t.Fatalf("CreateIdempotent: %v", err)
+	}
+	replay, err := s.CreateIdempotent(asUser("u1"), "key-1", req)
+	if err != nil {
+		t.Fatalf("CreateIdempotent replay: %v", err)
+	}
+
+	if replay.ID != first.ID {
+		t.Fatalf("expected replay to return %s, got %s", first.ID, replay.ID)
+	}
+	if len(repo.notifications) != 1 {
+		t.Fatalf("expected 1 notification, got %d", len(repo.notifications))
+	}
+}
+
+func TestCreateIdempotent_ScopedToUser(t *testing.T) {
+	repo := newFakeRepository()
+	s := newIdempotentService(repo, newFakeIdempotencyStore())
+
+	for _, userID := range []string{"u1", "u2"} {
+		req := CreateRequest{ChannelName: "email", Title: "Hola", Content: "Mundo"}
+		if _, err := s.CreateIdempotent(asUser(userID), "key-1", req); err != nil {
+			t.Fatalf("CreateIdempotent %s: %v", userID, err)
+		}
+	}
+
+	if len(repo.notifications) != 2 {
+		t.Fatalf("expected one notification per user, got %d", len(repo.notifications))
+	}
+}
+
+func TestCreateIdempotent_DifferentPayload(t *testing.T) {
+	s := newIdempotentService(newFakeRepository(), newFakeIdempotencyStore())
+	req := CreateRequest{ChannelName: "email", Title: "Hola", Content: "Mundo"}
+	if _, err := s.CreateIdempotent(asUser("u1"), "key-1", req); err != nil {
+		t.Fatalf("CreateIdempotent: %v", err)
+	}
+
+	req.Content = "Otro"
+	if _, err := s.CreateIdempotent(asUser("u1"), "key-1", req); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Fatalf("expected ErrIdempotencyKeyReused, got %v", err)
+	}
+}
+
+func TestCreateIdempotent_InProgress(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	s := newIdempotentService(newFakeRepository(), store)
+	req := CreateRequest{ChannelName: "email", Title: "Hola", Content: "Mundo"}
+	hash, err := hashRequest(req)
+	if err != nil {
+		t.Fatalf("hashRequest: %v", err)
+	}
+	store.records["u1/key-1"] = &IdempotencyRecord{Key: "key-1", UserID: "u1", RequestHash: hash, LockedUntil: time.Now().Add(time.Minute)}
+
+	if _, err := s.CreateIdempotent(asUser("u1"), "key-1", req); !errors.Is(err, ErrDuplicateNotification) {
+		t.Fatalf("expected ErrDuplicateNotification, got %v", err)
+	}
+}
+
+func TestCreateIdempotent_TakesOverAbandonedReservation(t *testing.T) {
+	repo := newFakeRepository()
+	store := newFakeIdempotencyStore()
+	s := newIdempotentService(repo, store)
+	req := CreateRequest{ChannelName: "email", Title: "Hola", Content: "Mundo"}
+	hash, err := hashRequest(req)
+	if err != nil {
+		t.Fatalf("hashRequest: %v", err)
+	}
+	// The first request crashed between the reservation and its completion
+	createdAt := time.Now().Add(-2 * idempotencyLockTimeout)
+	store.records["u1/key-1"] = &IdempotencyRecord{Key: "key-1", UserID: "u1", RequestHash: hash, CreatedAt: createdAt, LockedUntil: createdAt.Add(idempotencyLockTimeout)}
+
+	created, err := s.CreateIdempotent(asUser("u1"), "key-1", req)
+	if err != nil {
+		t.Fatalf("expected the retry to take over the reservation, got %v", err)
+	}
+
+	if len(repo.notifications) != 1 || store.records["u1/key-1"].Response.ID != created.ID {
+		t.Fatalf("expected the key to be completed with %s, got %+v", created.ID, store.records["u1/key-1"])
+	}
+}
+
+func TestCreateIdempotent_FailureReleasesKey(t *testing.T) {
+	store := newFakeIdempotencyStore()
+	s := newIdempotentService(newFakeRepository(), store)
+	req := CreateRequest{ChannelName: "fax", Title: "Hola", Content: "Mundo"}
+
+	if _, err := s.CreateIdempotent(asUser("u1"), "key-1", req); !errors.Is(err, ErrInvalidChannel) {
+		t.Fatalf("expected ErrInvalidChannel, got %v", err)
+	}
+	if len(store.records) != 0 {
+		t.Fatalf("expected the key to be released, got %v", store.records)
+	}
+}
@@ -0,0 +1,31 @@
+package notification
+
+// Message is what a channel receives to deliver a notification
+type Message struct {
+	NotificationID string
+	UserID         string
+	SenderID       string // see Notification.Owner
+	Title          string
+	Content        string
+	Meta           map[string]string
+}
+
+// Owner is who the resources referenced by meta belong to, see Notification.Owner
+func (m Message) Owner() string {
+	if m.SenderID != "" {
+		return m.SenderID
+	}
+	return m.UserID
+}
+
+// ToMessage converts a queued DispatchMessage into a channel Message
+func (m *DispatchMessage) ToMessage() Message {
+	return Message{
+		NotificationID: m.NotificationID,
+		UserID:         m.UserID,
+		SenderID:       m.SenderID,
+		Title:          m.Title,
+		Content:        m.Content,
+		Meta:           m.Meta,
+	}
+}
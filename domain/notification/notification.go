@@ -2,22 +2,77 @@ package notification
 
 import "time"
 
+const (
+	PriorityNormal = "normal"
+	// PriorityUrgent notifications are sent during the user's quiet hours
+	PriorityUrgent = "urgent"
+)
+
 type Notification struct {
 	ID          string
 	UserID      string
 	Title       string
 	Content     string
 	ChannelName string
+	Fallback    []string          // channels tried in order when ChannelName cannot deliver it
+	Category    string            // optional, matched against the user's opt-outs
+	Priority    string            // PriorityNormal or PriorityUrgent
+	Meta        map[string]string // redacted per channel before leaving the API
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+
+	// Set for scheduled notifications, ScheduledAt is in UTC
+	ScheduledAt *time.Time
+	TimeZone    string
+	// RetryOffset counts the SQS deliveries of the dispatches before a deferral,
+	// attempts of the next dispatch are numbered after them
+	RetryOffset int
+	// Set once the dispatcher held it back for quiet hours, it is no longer the owner's to edit
+	DeferredUntil *time.Time
+
+	// Delivery lifecycle, see status.go
+	Status        Status
+	QueuedAt      *time.Time
+	SendingAt     *time.Time
+	DeliveredAt   *time.Time
+	FailedAt      *time.Time
+	CancelledAt   *time.Time
+	SuppressedAt  *time.Time
+	FailureReason string
+	// Set when the user's preferences blocked the notification, it was never sent
+	SuppressionReason string
+
+	// Set for notifications with fallbacks, see fallback.go
+	DeliveredChannel string
+	ChannelPath      []ChannelStep
+
+	// Set for notifications created by a batch, see batch.go
+	BatchID string
+	// Set when another user, the sender of a batch or topic, created it, see Owner
+	SenderID string
+	// Set for notifications published to a topic, see topic.go
+	Topic string
+}
+
+// Owner is who the resources referenced by meta (e.g. attachments by key) belong to,
+// the sender when another user created it and otherwise its recipient
+func (n *Notification) Owner() string {
+	if n.SenderID != "" {
+		return n.SenderID
+	}
+	return n.UserID
 }
 
 type CreateRequest struct {
-	UserID      string            `json:"user_id"`
 	Title       string            `json:"title" binding:"required"`
 	Content     string            `json:"content" binding:"required"`
-	ChannelName string            `json:"channel_name" binding:"required,oneof=email sms push"`
+	ChannelName string            `json:"channel_name" binding:"required"`
+	Fallback    []string          `json:"fallback" binding:"omitempty,dive,required"`       // optional, e.g. ["sms", "email"]
+	Category    string            `json:"category" binding:"omitempty,max=64"`              // optional, e.g. "marketing"
+	Priority    string            `json:"priority" binding:"omitempty,oneof=normal urgent"` // optional, defaults to normal
 	Meta        map[string]string `json:"meta"`
+	SendAt      string            `json:"send_at"`   // RFC3339, optional, schedules the notification
+	TimeZone    string            `json:"time_zone"` // IANA name, optional, used when send_at has no offset
 }
 
 type UpdateRequest struct {
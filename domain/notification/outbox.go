@@ -0,0 +1,25 @@
+package notification
+
+import (
+	"context"
+	"time"
+)
+
+// OutboxRecord is a dispatch that was stored together with its notification
+// and still has to be published to the Queue by the relay
+type OutboxRecord struct {
+	NotificationID string
+	UserID         string
+	Message        DispatchMessage
+	CreatedAt      time.Time
+}
+
+// Outbox stores notifications and their dispatch records atomically
+// so a notification is never persisted without something that will publish it
+type Outbox interface {
+	CreateWithDispatch(ctx context.Context, n *Notification, message *DispatchMessage) error
+	// ListPending returns up to limit records that were not dispatched yet, oldest first
+	ListPending(ctx context.Context, limit int) ([]*OutboxRecord, error)
+	// MarkDispatched is idempotent, marking an already dispatched record is not an error
+	MarkDispatched(ctx context.Context, record *OutboxRecord) error
+}
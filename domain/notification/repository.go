@@ -1,6 +1,9 @@
 package notification
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Repository define el contrato (interface) que debe cumplir cualquier implementación
 // Esto permite cambiar DynamoDB por otra DB sin tocar el dominio
@@ -8,6 +11,17 @@ type Repository interface {
 	Create(ctx context.Context, n *Notification) error
 	GetByID(ctx context.Context, id string) (*Notification, error)
 	List(ctx context.Context, query ListQuery) (*ListResponse, error)
-	Update(ctx context.Context, id string, updates map[string]interface{}) error
+	// Update fails with ErrStatusChanged if the stored status is no longer n.Status
+	Update(ctx context.Context, n *Notification, updates map[string]interface{}) error
 	Delete(ctx context.Context, id string) error
+	// ListDue returns scheduled notifications whose send time is not after now
+	ListDue(ctx context.Context, now time.Time) ([]*Notification, error)
+	// UpdateStatus applies the change only if the stored status is still n.Status,
+	// otherwise it returns ErrInvalidTransition
+	UpdateStatus(ctx context.Context, n *Notification, change StatusChange) error
+	CreateAttempt(ctx context.Context, a *Attempt) error
+	// ListAttempts returns the attempts of n ordered by retry
+	ListAttempts(ctx context.Context, n *Notification) ([]*Attempt, error)
+	// AppendChannelPath adds steps to the path of n and, when not empty, sets the delivered channel
+	AppendChannelPath(ctx context.Context, n *Notification, deliveredChannel string, steps []ChannelStep) error
 }
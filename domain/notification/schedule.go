@@ -0,0 +1,48 @@
+package notification
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// localLayout is accepted for send_at when a time zone is given and send_at has no offset
+const localLayout = "2006-01-02T15:04:05"
+
+// parseSchedule resolves send_at and time_zone into the UTC instant to send at
+// It returns nil when the notification has to be sent right away
+func parseSchedule(sendAt, timeZone string, now time.Time) (*time.Time, error) {
+	errs := &ValidationError{}
+	if sendAt == "" {
+		if timeZone != "" {
+			errs.Add("send_at", "send_at is required when time_zone is set")
+		}
+		return nil, errs.ErrOrNil()
+	}
+
+	loc := time.UTC
+	if timeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timeZone); err != nil {
+			errs.Add("time_zone", "time_zone must be a valid IANA time zone")
+			return nil, errs
+		}
+	}
+
+	at, err := time.Parse(time.RFC3339, sendAt)
+	if err != nil && timeZone != "" {
+		at, err = time.ParseInLocation(localLayout, sendAt, loc)
+	}
+	if err != nil {
+		errs.Add("send_at", "send_at must be an RFC3339 timestamp")
+		return nil, errs
+	}
+	if !at.After(now) {
+		errs.Add("send_at", "send_at must be in the future")
+		return nil, errs
+	}
+
+	at = at.UTC().Truncate(time.Second)
+	return &at, nil
+}
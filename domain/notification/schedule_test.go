@@ -0,0 +1,69 @@
+package notification
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	now := time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		sendAt   string
+		timeZone string
+		want     time.Time
+	}{
+		{"utc", "2024-11-03T15:30:00Z", "", time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)},
+		{"offset", "2024-11-03T15:30:00-03:00", "", time.Date(2024, 11, 3, 18, 30, 0, 0, time.UTC)},
+		{"local time in zone", "2024-11-03T15:30:00", "America/Argentina/Buenos_Aires", time.Date(2024, 11, 3, 18, 30, 0, 0, time.UTC)},
+		{"offset wins over zone", "2024-11-03T15:30:00Z", "America/Argentina/Buenos_Aires", time.Date(2024, 11, 3, 15, 30, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSchedule(tt.sendAt, tt.timeZone, now)
+			if err != nil {
+				t.Fatalf("parseSchedule: %v", err)
+			}
+			if !got.Equal(tt.want) || got.Location() != time.UTC {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseSchedule_Immediate(t *testing.T) {
+	got, err := parseSchedule("", "", time.Now())
+	if err != nil || got != nil {
+		t.Fatalf("expected immediate send, got %v %v", got, err)
+	}
+}
+
+func TestParseSchedule_Invalid(t *testing.T) {
+	now := time.Date(2024, 11, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		sendAt   string
+		timeZone string
+		field    string
+	}{
+		{"not a timestamp", "tomorrow", "", "send_at"},
+		{"local time without zone", "2024-11-03T15:30:00", "", "send_at"},
+		{"in the past", "2024-11-03T11:00:00Z", "", "send_at"},
+		{"unknown zone", "2024-11-03T15:30:00", "Mars/Olympus", "time_zone"},
+		{"zone without send_at", "", "UTC", "send_at"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSchedule(tt.sendAt, tt.timeZone, now)
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) || validationErr.Fields[0].Field != tt.field {
+				t.Fatalf("expected validation error on %s, got %v", tt.field, err)
+			}
+		})
+	}
+}
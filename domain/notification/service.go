@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"serverless-notification/domain/auth"
+
 	"github.com/google/uuid"
 )
 
@@ -13,18 +17,47 @@ var (
 	ErrNotificationNotFound  = errors.New("notification not found")
 	ErrInvalidChannel        = errors.New("invalid channel")
 	ErrDuplicateNotification = errors.New("notification already exists")
+	ErrOutboxDisabled        = errors.New("outbox is not enabled")
+	ErrAlreadyDispatched     = errors.New("notification already dispatched")
+	ErrNotificationCancelled = errors.New("notification was cancelled")
+	ErrChannelNotAllowed     = errors.New("channel not allowed for this api key")
+
+	// errNotQueued is returned by store when the notification was stored but not published
+	errNotQueued = errors.New("failed to enqueue")
 )
 
 // ChannelValidator validates channel metadata (email, sms, push)
+// ValidateOwner checks the resources the metadata references belong to userID
 type ChannelValidator interface {
 	Validate(channelName string, meta map[string]string) error
+	ValidateOwner(channelName, userID string, meta map[string]string) error
 }
 
 // Service contains the business logic for notifications
 type Service struct {
-	repo      Repository
-	queue     Queue
-	validator ChannelValidator
+	repo        Repository
+	queue       Queue
+	validator   ChannelValidator
+	outbox      Outbox            // optional, see EnableOutbox
+	idempotency IdempotencyStore  // optional, see EnableIdempotency
+	contacts    ContactBook       // optional, see EnableContactBook
+	preferences PreferenceChecker // optional, see EnablePreferences
+	batches     BatchStore        // optional, see EnableBatches
+	batchQueue  BatchQueue
+	topics      TopicSubscribers // optional, see EnableTopics
+}
+
+// PreferenceChecker tells whether a user opted out of a notification
+type PreferenceChecker interface {
+	// SuppressionReason returns "" when the user accepts the notification
+	SuppressionReason(ctx context.Context, userID, channelName, category string) (string, error)
+	// QuietUntil returns when the user's quiet hours end if now is inside them
+	QuietUntil(ctx context.Context, userID, channelName string, now time.Time) (time.Time, bool, error)
+}
+
+// ContactBook fills in the channel address of a user when meta leaves it out
+type ContactBook interface {
+	FillAddress(ctx context.Context, userID, channelName string, meta map[string]string) (map[string]string, error)
 }
 
 // NewService creates a new instance of the service
@@ -36,51 +69,372 @@ func NewService(repo Repository, queue Queue, validator ChannelValidator) *Servi
 	}
 }
 
-// Create creates a new notification and queues it for processing
+// EnableContactBook makes Create address notifications from the user's contacts
+// when meta has no address for the channel
+func (s *Service) EnableContactBook(contacts ContactBook) {
+	s.contacts = contacts
+}
+
+// EnablePreferences makes Create and DispatchDue suppress the notifications
+// the user opted out of instead of sending them, and DeferForQuietHours
+// hold non-urgent notifications during the user's quiet hours
+func (s *Service) EnablePreferences(preferences PreferenceChecker) {
+	s.preferences = preferences
+}
+
+// EnableOutbox makes Create store the dispatch in the outbox instead of publishing it,
+// a relay then publishes it with RelayOutbox
+func (s *Service) EnableOutbox(outbox Outbox) {
+	s.outbox = outbox
+}
+
+// Create creates a new notification of the authenticated user and queues it for processing
+// Callers authenticated with an API key can only use the key's allowed channels
 func (s *Service) Create(ctx context.Context, req CreateRequest) (*Notification, error) {
-	if err := s.validator.Validate(req.ChannelName, req.Meta); err != nil {
-		return nil, fmt.Errorf("%w: %v", ErrInvalidChannel, err)
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
 	}
-
 	now := time.Now()
+	scheduledAt, err := s.checkRequest(principal, req, now)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := s.build(ctx, principal.UserID, principal.UserID, req, scheduledAt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store(ctx, notification); err != nil {
+		return nil, err
+	}
+	return notification, nil
+}
+
+// store persists a built notification and, when it is pending, queues it for processing
+func (s *Service) store(ctx context.Context, notification *Notification) error {
+	// Suppressed notifications are stored so the sender can see why nothing was sent,
+	// scheduled ones are published by DispatchDue once they are due
+	if notification.Status != StatusPending {
+		if err := s.repo.Create(ctx, notification); err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+		return nil
+	}
+
+	message := notification.dispatchMessage()
+
+	if s.outbox != nil {
+		if err := s.outbox.CreateWithDispatch(ctx, notification, &message); err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+		return nil
+	}
+
+	if err := s.repo.Create(ctx, notification); err != nil {
+		return fmt.Errorf("failed to create notification: %w", err)
+	}
+
+	if err := s.queue.Publish(ctx, &message); err != nil {
+		// Without the outbox the notification stays pending and is never sent
+		return fmt.Errorf("%w: %w", errNotQueued, err)
+	}
+
+	// The dispatcher may have already moved it forward, in which case the
+	// transition is rejected and the notification is left as it is
+	if err := s.transition(ctx, notification, StatusQueued, ""); err != nil && !errors.Is(err, ErrInvalidTransition) {
+		return fmt.Errorf("failed to mark as queued: %w", err)
+	}
+	return nil
+}
+
+// checkRequest checks the parts of req that do not depend on its recipient
+// and returns when it is scheduled, nil to send it now
+func (s *Service) checkRequest(principal auth.Principal, req CreateRequest, now time.Time) (*time.Time, error) {
+	channels := append([]string{req.ChannelName}, req.Fallback...)
+	for _, channel := range channels {
+		if !principal.CanUseChannel(channel) {
+			return nil, fmt.Errorf("%w: %s", ErrChannelNotAllowed, channel)
+		}
+	}
+	if err := checkChain(s.validator, channels); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidChannel, err)
+	}
+	scheduledAt, err := parseSchedule(req.SendAt, req.TimeZone, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchedule, err)
+	}
+	return scheduledAt, nil
+}
+
+// build returns the notification senderID requested for userID, for a request that passed checkRequest,
+// not stored yet. It is addressed from the user's contacts and is pending, scheduled or, when the
+// user opted out, suppressed
+func (s *Service) build(ctx context.Context, senderID, userID string, req CreateRequest, scheduledAt *time.Time, now time.Time) (*Notification, error) {
+	notification := newNotification(userID, req, now)
+	if senderID != userID {
+		notification.SenderID = senderID
+	}
+	if s.contacts != nil {
+		for _, channel := range notification.Channels() {
+			meta, err := s.contacts.FillAddress(ctx, userID, channel, notification.Meta)
+			if err != nil {
+				return nil, err
+			}
+			notification.Meta = meta
+		}
+	}
+	if err := validateChain(s.validator, notification.Channels(), notification.Owner(), notification.Meta); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidChannel, err)
+	}
+
+	reason, err := s.applyPreferences(ctx, notification)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check preferences: %w", err)
+	}
+	if reason != "" {
+		notification.Status = StatusSuppressed
+		notification.SuppressedAt = &now
+		notification.SuppressionReason = reason
+		return notification, nil
+	}
+
+	if scheduledAt != nil {
+		notification.Status = StatusScheduled
+		notification.ScheduledAt = scheduledAt
+		notification.TimeZone = req.TimeZone
+	}
+	return notification, nil
+}
+
+// newNotification returns a pending notification of userID with the fields of req
+func newNotification(userID string, req CreateRequest, now time.Time) *Notification {
 	notification := &Notification{
 		ID:          generateID(),
-		UserID:      req.UserID,
+		UserID:      userID,
 		Title:       req.Title,
 		Content:     req.Content,
 		ChannelName: req.ChannelName,
+		Fallback:    req.Fallback,
+		Category:    strings.ToLower(req.Category),
+		Priority:    req.Priority,
+		Meta:        req.Meta,
 		CreatedAt:   now,
 		UpdatedAt:   now,
+		Status:      StatusPending,
 	}
+	if notification.Priority == "" {
+		notification.Priority = PriorityNormal
+	}
+	return notification
+}
 
-	if err := s.repo.Create(ctx, notification); err != nil {
-		return nil, fmt.Errorf("failed to create notification: %w", err)
+// RelayOutbox publishes a pending outbox record and marks it as dispatched
+// Publishing is deduplicated by notification ID, so relaying a record twice is safe
+func (s *Service) RelayOutbox(ctx context.Context, record *OutboxRecord) error {
+	if s.outbox == nil {
+		return ErrOutboxDisabled
+	}
+	if err := s.queue.Publish(ctx, &record.Message); err != nil {
+		return fmt.Errorf("failed to enqueue: %w", err)
+	}
+	if err := s.outbox.MarkDispatched(ctx, record); err != nil {
+		return fmt.Errorf("failed to mark as dispatched: %w", err)
+	}
+
+	notification, err := s.repo.GetByID(ctx, record.NotificationID)
+	if err != nil {
+		return fmt.Errorf("failed to get notification: %w", err)
 	}
+	if err := s.transition(ctx, notification, StatusQueued, ""); err != nil && !errors.Is(err, ErrInvalidTransition) {
+		return fmt.Errorf("failed to mark as queued: %w", err)
+	}
+	return nil
+}
+
+// RelayPending relays up to limit pending outbox records and returns how many were relayed
+// Used by the local poller, in AWS the relay is driven by DynamoDB Streams
+func (s *Service) RelayPending(ctx context.Context, limit int) (int, error) {
+	if s.outbox == nil {
+		return 0, ErrOutboxDisabled
+	}
+	records, err := s.outbox.ListPending(ctx, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list outbox: %w", err)
+	}
+	for i, record := range records {
+		if err := s.RelayOutbox(ctx, record); err != nil {
+			return i, fmt.Errorf("failed to relay %s: %w", record.NotificationID, err)
+		}
+	}
+	return len(records), nil
+}
 
-	message := DispatchMessage{
-		NotificationID: notification.ID,
-		UserID:         notification.UserID,
-		ChannelName:    req.ChannelName,
-		Title:          req.Title,
-		Content:        req.Content,
-		Meta:           req.Meta,
+// DispatchDue publishes every scheduled notification that is due at now
+// and returns how many were published. Called periodically by the scheduler
+func (s *Service) DispatchDue(ctx context.Context, now time.Time) (int, error) {
+	due, err := s.repo.ListDue(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list due notifications: %w", err)
 	}
 
-	if err := s.queue.Publish(ctx, &message); err != nil {
-		// TODO: Implement compensation (delete from DB?)
-		return nil, fmt.Errorf("failed to enqueue: %w", err)
+	var errs []error
+	published := 0
+	for _, notification := range due {
+		// Preferences may have changed since the notification was scheduled
+		reason, err := s.applyPreferences(ctx, notification)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to check preferences of %s: %w", notification.ID, err))
+			continue
+		}
+		if reason != "" {
+			if err := s.transition(ctx, notification, StatusSuppressed, reason); err != nil && !errors.Is(err, ErrInvalidTransition) {
+				errs = append(errs, fmt.Errorf("failed to mark %s as suppressed: %w", notification.ID, err))
+			}
+			continue
+		}
+
+		// Queued before it is published, the dispatcher may pick the message right away
+		// and only sends queued notifications. One cancelled in the meantime is not published
+		dueAt := now
+		if notification.ScheduledAt != nil {
+			dueAt = *notification.ScheduledAt
+		}
+		if err := s.transition(ctx, notification, StatusQueued, ""); err != nil {
+			if !errors.Is(err, ErrInvalidTransition) {
+				errs = append(errs, fmt.Errorf("failed to mark %s as queued: %w", notification.ID, err))
+			}
+			continue
+		}
+		message := notification.dispatchMessage()
+		if err := s.queue.Publish(ctx, &message); err != nil {
+			errs = append(errs, fmt.Errorf("failed to enqueue %s: %w", notification.ID, err))
+			// Back to scheduled so the next run publishes it again
+			change := StatusChange{To: StatusScheduled, At: time.Now(), ScheduledAt: dueAt, RetryOffset: notification.RetryOffset}
+			if err := s.repo.UpdateStatus(ctx, notification, change); err != nil && !errors.Is(err, ErrInvalidTransition) {
+				errs = append(errs, fmt.Errorf("failed to reschedule %s: %w", notification.ID, err))
+			}
+			continue
+		}
+		published++
+	}
+	return published, errors.Join(errs...)
+}
+
+// DeferForQuietHours moves a dispatched notification back to scheduled when
+// channelName, the channel of the chain it is about to go through, is inside the
+// quiet hours of its user, so DispatchDue publishes it again once they end.
+// retry is the number the attempts of the next publish start from
+// It returns when it will be sent, nil when it can be sent now
+// Urgent notifications are never deferred
+func (s *Service) DeferForQuietHours(ctx context.Context, message *DispatchMessage, channelName string, retry int, now time.Time) (*time.Time, error) {
+	if s.preferences == nil || message.Priority == PriorityUrgent {
+		return nil, nil
+	}
+	until, quiet, err := s.preferences.QuietUntil(ctx, message.UserID, channelName, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check quiet hours: %w", err)
+	}
+	if !quiet {
+		return nil, nil
 	}
 
+	notification, err := s.repo.GetByID(ctx, message.NotificationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+	if !notification.Status.CanTransitionTo(StatusScheduled) {
+		// Cancelled or already sent, the dispatcher skips it
+		return nil, nil
+	}
+	change := StatusChange{To: StatusScheduled, At: now, ScheduledAt: until.UTC(), RetryOffset: retry, Deferred: true}
+	if err := s.repo.UpdateStatus(ctx, notification, change); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to mark as scheduled: %w", err)
+	}
+	change.apply(notification)
+	return notification.ScheduledAt, nil
+}
+
+// applyPreferences drops from n the channels its owner opted out of, callers persist
+// the shorter chain or not. When every channel is blocked it returns why and leaves n as it is
+func (s *Service) applyPreferences(ctx context.Context, n *Notification) (string, error) {
+	if s.preferences == nil {
+		return "", nil
+	}
+	var allowed []string
+	var firstReason string
+	for _, channel := range n.Channels() {
+		reason, err := s.preferences.SuppressionReason(ctx, n.UserID, channel, n.Category)
+		if err != nil {
+			return "", err
+		}
+		if reason == "" {
+			allowed = append(allowed, channel)
+		} else if firstReason == "" {
+			firstReason = reason
+		}
+	}
+	if len(allowed) == 0 {
+		return firstReason, nil
+	}
+	n.ChannelName = allowed[0]
+	n.Fallback = allowed[1:]
+	if len(n.Fallback) == 0 {
+		n.Fallback = nil
+	}
+	return "", nil
+}
+
+// Cancel cancels a notification that was not sent yet, the dispatcher skips it
+// Cancelling it again returns it as it is, one already sent fails with ErrAlreadyDispatched
+func (s *Service) Cancel(ctx context.Context, id string) (*Notification, error) {
+	notification, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notification.Status == StatusCancelled {
+		return notification, nil
+	}
+	if err := s.transition(ctx, notification, StatusCancelled, ""); err != nil {
+		if errors.Is(err, ErrInvalidTransition) {
+			return nil, ErrAlreadyDispatched
+		}
+		return nil, err
+	}
 	return notification, nil
 }
 
-// GetByID gets a notification by ID
+// GetByID gets a notification of the authenticated user by ID
 func (s *Service) GetByID(ctx context.Context, id string) (*Notification, error) {
-	return s.repo.GetByID(ctx, id)
+	return s.getOwned(ctx, id)
+}
+
+// getOwned gets a notification by ID, notifications of other users are not found
+func (s *Service) getOwned(ctx context.Context, id string) (*Notification, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	notification, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if notification.UserID != userID {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, nil
 }
 
-// List lists notifications with filters
+// List lists the notifications of the authenticated user
 func (s *Service) List(ctx context.Context, query ListQuery) ([]*Notification, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	query.UserID = userID
 	response, err := s.repo.List(ctx, query)
 	if err != nil {
 		return nil, err
@@ -89,19 +443,25 @@ func (s *Service) List(ctx context.Context, query ListQuery) ([]*Notification, e
 }
 
 // Update updates a notification
-// Currently unused because notifications are sent immediately
-// Will be used in the future for scheduled notifications
-func (s *Service) Update(ctx context.Context, id string, req UpdateRequest) error {
-	// 1. Verify that it exists
-	notification, err := s.repo.GetByID(ctx, id)
+// Only scheduled notifications can be updated, the rest were cancelled or already dispatched,
+// including the ones the dispatcher deferred for quiet hours
+func (s *Service) Update(ctx context.Context, id string, req UpdateRequest) (*Notification, error) {
+	// 1. Verify that it exists and is still scheduled
+	notification, err := s.getOwned(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	switch {
+	case notification.Status == StatusCancelled:
+		return nil, ErrNotificationCancelled
+	case notification.IsDispatched():
+		return nil, ErrAlreadyDispatched
 	}
 
-	// 2. Validate metadata if provided
+	// 2. Validate metadata if provided, the owner now edits it so it references their resources
 	if req.Meta != nil {
-		if err := s.validator.Validate(notification.ChannelName, req.Meta); err != nil {
-			return fmt.Errorf("%w: %v", ErrInvalidChannel, err)
+		if err := validateChain(s.validator, notification.Channels(), notification.UserID, req.Meta); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidChannel, err)
 		}
 	}
 
@@ -109,31 +469,160 @@ func (s *Service) Update(ctx context.Context, id string, req UpdateRequest) erro
 	updates := make(map[string]interface{})
 	if req.Title != "" {
 		updates["title"] = req.Title
+		notification.Title = req.Title
 	}
 	if req.Content != "" {
 		updates["content"] = req.Content
+		notification.Content = req.Content
+	}
+	if req.Meta != nil {
+		updates["meta"] = req.Meta
+		notification.Meta = req.Meta
+		if notification.SenderID != "" {
+			updates["sender_id"] = ""
+			notification.SenderID = ""
+		}
 	}
-	updates["updated_at"] = time.Now()
 
-	// 4. Update
-	return s.repo.Update(ctx, id, updates)
+	// 4. Update, failing if the scheduler dispatched it in the meantime
+	if err := s.repo.Update(ctx, notification, updates); err != nil {
+		if errors.Is(err, ErrStatusChanged) {
+			return nil, ErrAlreadyDispatched
+		}
+		return nil, err
+	}
+	notification.UpdatedAt = time.Now()
+	return notification, nil
 }
 
 // Delete deletes a notification (soft delete)
-// Currently unused because notifications are sent immediately
-// Will be used in the future for scheduled notifications
+// A scheduled notification is cancelled first, dispatched ones cannot be deleted,
+// including the ones the dispatcher deferred for quiet hours
 func (s *Service) Delete(ctx context.Context, id string) error {
+	notification, err := s.getOwned(ctx, id)
+	if err != nil {
+		return err
+	}
+	if notification.IsDispatched() {
+		return ErrAlreadyDispatched
+	}
+	if notification.Status == StatusScheduled {
+		if err := s.transition(ctx, notification, StatusCancelled, ""); err != nil {
+			if errors.Is(err, ErrInvalidTransition) {
+				return ErrAlreadyDispatched
+			}
+			return err
+		}
+	}
 	return s.repo.Delete(ctx, id)
 }
 
+// UpdateStatus moves a notification to a new delivery status
+// reason is only stored when the status is failed
+// Returns ErrInvalidTransition if the current status cannot move to status
+func (s *Service) UpdateStatus(ctx context.Context, id string, status Status, reason string) error {
+	notification, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.transition(ctx, notification, status, reason)
+}
+
+// MarkSending moves a notification to sending before the dispatcher sends it
+// A redelivered message also takes over a notification left in sending, the dispatcher
+// stopped before finishing it. Any other notification that is not due returns ErrInvalidTransition
+func (s *Service) MarkSending(ctx context.Context, id string, redelivered bool) error {
+	notification, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if notification.Status == StatusSending && !redelivered {
+		return fmt.Errorf("%w: already sending", ErrInvalidTransition)
+	}
+	return s.transition(ctx, notification, StatusSending, "")
+}
+
+func (s *Service) transition(ctx context.Context, notification *Notification, status Status, reason string) error {
+	if !notification.Status.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, notification.Status, status)
+	}
+	change := StatusChange{To: status, At: time.Now()}
+	if status == StatusFailed || status == StatusSuppressed {
+		change.Reason = reason
+	}
+	if err := s.repo.UpdateStatus(ctx, notification, change); err != nil {
+		return err
+	}
+	change.apply(notification)
+	return nil
+}
+
+// RecordChannelPath appends the channels the dispatcher tried to the notification,
+// deliveredChannel is the one that accepted it or "" when all of them failed
+func (s *Service) RecordChannelPath(ctx context.Context, id, deliveredChannel string, steps []ChannelStep) error {
+	notification, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get notification: %w", err)
+	}
+	if err := s.repo.AppendChannelPath(ctx, notification, deliveredChannel, steps); err != nil {
+		return fmt.Errorf("failed to record channel path: %w", err)
+	}
+	return nil
+}
+
+// RecordAttempt stores the result of a delivery attempt
+func (s *Service) RecordAttempt(ctx context.Context, attempt *Attempt) error {
+	return s.repo.CreateAttempt(ctx, attempt)
+}
+
+// ListAttempts returns every delivery attempt of a notification
+func (s *Service) ListAttempts(ctx context.Context, id string) ([]*Attempt, error) {
+	notification, err := s.getOwned(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListAttempts(ctx, notification)
+}
+
 // DispatchMessage is the message that is sent to SQS
 type DispatchMessage struct {
 	NotificationID string            `json:"notification_id"`
 	UserID         string            `json:"user_id"`
+	SenderID       string            `json:"sender_id,omitempty"`
 	ChannelName    string            `json:"channel_name"`
 	Title          string            `json:"title"`
 	Content        string            `json:"content"`
 	Meta           map[string]string `json:"meta"`
+	Priority       string            `json:"priority,omitempty"`
+	Fallback       []string          `json:"fallback,omitempty"`
+	ScheduledAt    *time.Time        `json:"scheduled_at,omitempty"`
+	RetryOffset    int               `json:"retry_offset,omitempty"`
+}
+
+// DeduplicationID identifies a publish of the notification to the queue
+// A notification deferred for quiet hours is published again with a later ScheduledAt,
+// so the queue does not drop it as a duplicate of the first publish
+func (m *DispatchMessage) DeduplicationID() string {
+	if m.ScheduledAt == nil {
+		return m.NotificationID
+	}
+	return m.NotificationID + "#" + strconv.FormatInt(m.ScheduledAt.Unix(), 10)
+}
+
+func (n *Notification) dispatchMessage() DispatchMessage {
+	return DispatchMessage{
+		NotificationID: n.ID,
+		UserID:         n.UserID,
+		SenderID:       n.SenderID,
+		ChannelName:    n.ChannelName,
+		Title:          n.Title,
+		Content:        n.Content,
+		Meta:           n.Meta,
+		Priority:       n.Priority,
+		Fallback:       n.Fallback,
+		ScheduledAt:    n.ScheduledAt,
+		RetryOffset:    n.RetryOffset,
+	}
 }
 
 // generateID generates a unique ID
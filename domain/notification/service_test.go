@@ -0,0 +1,848 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+// asUser returns a context authenticated as userID
+func asUser(userID string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: userID})
+}
+
+type fakeRepository struct {
+	notifications map[string]*Notification
+	attempts      map[string][]*Attempt
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{notifications: map[string]*Notification{}, attempts: map[string][]*Attempt{}}
+}
+
+func (r *fakeRepository) Create(ctx context.Context, n *Notification) error {
+	r.notifications[n.ID] = n
+	return nil
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, id string) (*Notification, error) {
+	n, ok := r.notifications[id]
+	if !ok {
+		return nil, ErrNotificationNotFound
+	}
+	return n, nil
+}
+
+func (r *fakeRepository) List(ctx context.Context, query ListQuery) (*ListResponse, error) {
+	return &ListResponse{}, nil
+}
+
+func (r *fakeRepository) Update(ctx context.Context, n *Notification, updates map[string]interface{}) error {
+	stored, ok := r.notifications[n.ID]
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	if stored.Status != n.Status {
+		return ErrStatusChanged
+	}
+	updated := *stored
+	if title, ok := updates["title"].(string); ok {
+		updated.Title = title
+	}
+	if content, ok := updates["content"].(string); ok {
+		updated.Content = content
+	}
+	if meta, ok := updates["meta"].(map[string]string); ok {
+		updated.Meta = meta
+	}
+	r.notifications[n.ID] = &updated
+	return nil
+}
+
+func (r *fakeRepository) UpdateStatus(ctx context.Context, n *Notification, change StatusChange) error {
+	stored, ok := r.notifications[n.ID]
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	if stored.Status != n.Status {
+		return ErrInvalidTransition
+	}
+	updated := *stored
+	change.apply(&updated)
+	r.notifications[n.ID] = &updated
+	return nil
+}
+
+func (r *fakeRepository) CreateAttempt(ctx context.Context, a *Attempt) error {
+	r.attempts[a.NotificationID] = append(r.attempts[a.NotificationID], a)
+	return nil
+}
+
+func (r *fakeRepository) ListAttempts(ctx context.Context, n *Notification) ([]*Attempt, error) {
+	return r.attempts[n.ID], nil
+}
+
+func (r *fakeRepository) ListDue(ctx context.Context, now time.Time) ([]*Notification, error) {
+	var due []*Notification
+	for _, n := range r.notifications {
+		if n.Status == StatusScheduled && !n.ScheduledAt.After(now) {
+			due = append(due, n)
+		}
+	}
+	return due, nil
+}
+
+func (r *fakeRepository) AppendChannelPath(ctx context.Context, n *Notification, deliveredChannel string, steps []ChannelStep) error {
+	stored, ok := r.notifications[n.ID]
+	if !ok {
+		return ErrNotificationNotFound
+	}
+	stored.ChannelPath = append(stored.ChannelPath, steps...)
+	if deliveredChannel != "" {
+		stored.DeliveredChannel = deliveredChannel
+	}
+	return nil
+}
+
+func (r *fakeRepository) Delete(ctx context.Context, id string) error {
+	delete(r.notifications, id)
+	return nil
+}
+
+type fakeQueue struct {
+	published []*DispatchMessage
+	// onPublish runs before a message is published, its error fails the publish
+	onPublish func(message *DispatchMessage) error
+}
+
+func (q *fakeQueue) Publish(ctx context.Context, message *DispatchMessage) error {
+	if q.onPublish != nil {
+		if err := q.onPublish(message); err != nil {
+			return err
+		}
+	}
+	q.published = append(q.published, message)
+	return nil
+}
+
+func TestCreate_OK(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{
+		Title:       "Hola",
+		Content:     "Mundo",
+		ChannelName: "email",
+		Meta:        map[string]string{"to": "user@example.com"},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, ok := repo.notifications[n.ID]; !ok {
+		t.Fatalf("expected notification %s to be stored", n.ID)
+	}
+	if len(queue.published) != 1 || queue.published[0].NotificationID != n.ID {
+		t.Fatalf("expected notification %s to be published, got %v", n.ID, queue.published)
+	}
+	if n.Status != StatusQueued || n.QueuedAt == nil {
+		t.Fatalf("expected notification to be queued, got %s", n.Status)
+	}
+}
+
+func TestCreate_InvalidMeta(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	validationErr := &ValidationError{}
+	validationErr.Add("to", "to field with valid email is required")
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email", validateErr: validationErr}))
+
+	_, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", Meta: map[string]string{}})
+	if !errors.Is(err, ErrInvalidChannel) {
+		t.Fatalf("expected ErrInvalidChannel, got %v", err)
+	}
+	var got *ValidationError
+	if !errors.As(err, &got) || len(got.Fields) != 1 || got.Fields[0].Field != "to" {
+		t.Fatalf("expected validation error on field 'to', got %v", err)
+	}
+	if len(repo.notifications) != 0 || len(queue.published) != 0 {
+		t.Fatal("expected nothing to be stored or published")
+	}
+}
+
+func TestCreate_ForeignResourceIsRejected(t *testing.T) {
+	repo := newFakeRepository()
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry(&ownedChannel{stubChannel{name: "email"}}))
+
+	_, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", Meta: map[string]string{"key": "usr_999/invoice.pdf"}})
+
+	var got *ValidationError
+	if !errors.Is(err, ErrInvalidChannel) || !errors.As(err, &got) || got.Fields[0].Field != "key" {
+		t.Fatalf("expected a validation error on field 'key', got %v", err)
+	}
+	if len(repo.notifications) != 0 {
+		t.Fatal("expected nothing to be stored")
+	}
+	if _, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", Meta: map[string]string{"key": "usr_123/invoice.pdf"}}); err != nil {
+		t.Fatalf("expected the own resource to be accepted, got %v", err)
+	}
+}
+
+func TestCreate_UnknownChannel(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry())
+
+	_, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "fax"})
+	if !errors.Is(err, ErrInvalidChannel) || !errors.Is(err, ErrUnknownChannel) {
+		t.Fatalf("expected ErrInvalidChannel wrapping ErrUnknownChannel, got %v", err)
+	}
+}
+
+func TestUpdateStatus_Lifecycle(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", UserID: "usr_123", Status: StatusQueued}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	if err := s.UpdateStatus(context.Background(), "n1", StatusSending, ""); err != nil {
+		t.Fatalf("UpdateStatus sending: %v", err)
+	}
+	if err := s.UpdateStatus(context.Background(), "n1", StatusFailed, "smtp timeout"); err != nil {
+		t.Fatalf("UpdateStatus failed: %v", err)
+	}
+
+	n := repo.notifications["n1"]
+	if n.Status != StatusFailed || n.FailureReason != "smtp timeout" {
+		t.Fatalf("expected failed with reason, got %s %q", n.Status, n.FailureReason)
+	}
+	if n.SendingAt == nil || n.FailedAt == nil {
+		t.Fatal("expected sending_at and failed_at to be set")
+	}
+}
+
+func TestUpdateStatus_InvalidTransition(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", Status: StatusDelivered}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	err := s.UpdateStatus(context.Background(), "n1", StatusCancelled, "")
+	if !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected ErrInvalidTransition, got %v", err)
+	}
+}
+
+func TestMarkSending_OnlyRedeliveryTakesOverSending(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", Status: StatusSending}
+	repo.notifications["n2"] = &Notification{ID: "n2", Status: StatusDelivered}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	if err := s.MarkSending(context.Background(), "n1", false); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected a first receive to skip a sending notification, got %v", err)
+	}
+	if err := s.MarkSending(context.Background(), "n1", true); err != nil {
+		t.Fatalf("expected a redelivery to take over, got %v", err)
+	}
+	if err := s.MarkSending(context.Background(), "n2", true); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("expected a delivered notification to be skipped, got %v", err)
+	}
+}
+
+func TestListAttempts_NotFound(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry())
+
+	if _, err := s.ListAttempts(asUser("usr_123"), "missing"); !errors.Is(err, ErrNotificationNotFound) {
+		t.Fatalf("expected ErrNotificationNotFound, got %v", err)
+	}
+}
+
+type fakeOutbox struct {
+	repo       *fakeRepository
+	pending    []*OutboxRecord
+	dispatched []string
+}
+
+func (o *fakeOutbox) CreateWithDispatch(ctx context.Context, n *Notification, message *DispatchMessage) error {
+	o.repo.notifications[n.ID] = n
+	o.pending = append(o.pending, &OutboxRecord{NotificationID: n.ID, UserID: n.UserID, Message: *message})
+	return nil
+}
+
+func (o *fakeOutbox) ListPending(ctx context.Context, limit int) ([]*OutboxRecord, error) {
+	if len(o.pending) > limit {
+		return o.pending[:limit], nil
+	}
+	return o.pending, nil
+}
+
+func (o *fakeOutbox) MarkDispatched(ctx context.Context, record *OutboxRecord) error {
+	o.dispatched = append(o.dispatched, record.NotificationID)
+	return nil
+}
+
+func TestCreate_OutboxDefersPublish(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	outbox := &fakeOutbox{repo: repo}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+	s.EnableOutbox(outbox)
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(queue.published) != 0 {
+		t.Fatalf("expected nothing published before relay, got %d", len(queue.published))
+	}
+	if n.Status != StatusPending || len(outbox.pending) != 1 {
+		t.Fatalf("expected pending notification with an outbox record, got %s and %d", n.Status, len(outbox.pending))
+	}
+
+	relayed, err := s.RelayPending(context.Background(), 10)
+	if err != nil {
+		t.Fatalf("RelayPending: %v", err)
+	}
+	if relayed != 1 || len(queue.published) != 1 || queue.published[0].NotificationID != n.ID {
+		t.Fatalf("expected notification %s to be published, got %v", n.ID, queue.published)
+	}
+	if len(outbox.dispatched) != 1 || outbox.dispatched[0] != n.ID {
+		t.Fatalf("expected outbox record to be marked dispatched, got %v", outbox.dispatched)
+	}
+	if repo.notifications[n.ID].Status != StatusQueued {
+		t.Fatalf("expected notification to be queued, got %s", repo.notifications[n.ID].Status)
+	}
+}
+
+func TestRelayPending_OutboxDisabled(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry())
+
+	if _, err := s.RelayPending(context.Background(), 10); !errors.Is(err, ErrOutboxDisabled) {
+		t.Fatalf("expected ErrOutboxDisabled, got %v", err)
+	}
+}
+
+func TestCreate_ScheduledIsNotPublished(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+	sendAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{
+		ChannelName: "email",
+		Meta:        map[string]string{"to": "user@example.com"},
+		SendAt:      sendAt.Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n.Status != StatusScheduled || n.ScheduledAt == nil || !n.ScheduledAt.Equal(sendAt) {
+		t.Fatalf("expected scheduled at %v, got %s %v", sendAt, n.Status, n.ScheduledAt)
+	}
+	if len(queue.published) != 0 {
+		t.Fatalf("expected nothing published, got %d", len(queue.published))
+	}
+
+	// Not due yet
+	published, err := s.DispatchDue(context.Background(), time.Now())
+	if err != nil || published != 0 {
+		t.Fatalf("expected nothing due, got %d %v", published, err)
+	}
+
+	published, err = s.DispatchDue(context.Background(), sendAt)
+	if err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+	if published != 1 || queue.published[0].NotificationID != n.ID || queue.published[0].Meta["to"] != "user@example.com" {
+		t.Fatalf("expected notification %s to be published with its meta, got %v", n.ID, queue.published)
+	}
+	if repo.notifications[n.ID].Status != StatusQueued {
+		t.Fatalf("expected queued, got %s", repo.notifications[n.ID].Status)
+	}
+}
+
+func TestDispatchDue_QueuedBeforePublished(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+	sendAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", Meta: map[string]string{"to": "user@example.com"}, SendAt: sendAt.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// The dispatcher consumes the message as soon as it is published
+	queue.onPublish = func(message *DispatchMessage) error {
+		stored := repo.notifications[message.NotificationID]
+		if !stored.Status.CanTransitionTo(StatusSending) {
+			t.Fatalf("expected the dispatcher to be able to send, status %s", stored.Status)
+		}
+		return repo.UpdateStatus(context.Background(), stored, StatusChange{To: StatusSending, At: time.Now()})
+	}
+
+	if _, err := s.DispatchDue(context.Background(), sendAt); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	if got := repo.notifications[n.ID].Status; got != StatusSending {
+		t.Fatalf("expected the dispatcher's status to be kept, got %s", got)
+	}
+}
+
+func TestDispatchDue_PublishFailureReschedules(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{onPublish: func(message *DispatchMessage) error { return errors.New("sqs unavailable") }}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+	sendAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", Meta: map[string]string{"to": "user@example.com"}, SendAt: sendAt.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if published, err := s.DispatchDue(context.Background(), sendAt); err == nil || published != 0 {
+		t.Fatalf("expected the publish to fail, got %d %v", published, err)
+	}
+	if got := repo.notifications[n.ID]; got.Status != StatusScheduled || !got.ScheduledAt.Equal(sendAt) {
+		t.Fatalf("expected the notification to be scheduled again, got %s at %v", got.Status, got.ScheduledAt)
+	}
+
+	queue.onPublish = nil
+	if published, err := s.DispatchDue(context.Background(), sendAt); err != nil || published != 1 {
+		t.Fatalf("expected the next run to publish it, got %d %v", published, err)
+	}
+}
+
+func TestCancel_Scheduled(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", SendAt: time.Now().Add(time.Hour).Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cancelled, err := s.Cancel(asUser("usr_123"), n.ID)
+	if err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if cancelled.Status != StatusCancelled || cancelled.CancelledAt == nil {
+		t.Fatalf("expected cancelled, got %s", cancelled.Status)
+	}
+
+	published, err := s.DispatchDue(context.Background(), time.Now().Add(2*time.Hour))
+	if err != nil || published != 0 {
+		t.Fatalf("expected cancelled notification not to be published, got %d %v", published, err)
+	}
+}
+
+func TestCreate_InvalidSchedule(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry(&stubChannel{name: "email"}))
+
+	_, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", SendAt: "tomorrow"})
+	if !errors.Is(err, ErrInvalidSchedule) {
+		t.Fatalf("expected ErrInvalidSchedule, got %v", err)
+	}
+}
+
+func TestUpdate_ScheduledPersistsMeta(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "email"}))
+	n, err := s.Create(asUser("usr_123"), CreateRequest{
+		ChannelName: "email",
+		Title:       "Hola",
+		Meta:        map[string]string{"to": "old@example.com"},
+		SendAt:      time.Now().Add(time.Hour).Format(time.RFC3339),
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	updated, err := s.Update(asUser("usr_123"), n.ID, UpdateRequest{Title: "Chau", Meta: map[string]string{"to": "new@example.com"}})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Title != "Chau" || updated.Meta["to"] != "new@example.com" {
+		t.Fatalf("unexpected updated notification: %+v", updated)
+	}
+
+	if _, err := s.DispatchDue(context.Background(), time.Now().Add(2*time.Hour)); err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+	if queue.published[0].Title != "Chau" || queue.published[0].Meta["to"] != "new@example.com" {
+		t.Fatalf("expected the updated notification to be published, got %+v", queue.published[0])
+	}
+}
+
+func TestUpdate_CancelledIsRejected(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", UserID: "usr_123", Title: "Hola", Status: StatusCancelled}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	if _, err := s.Update(asUser("usr_123"), "n1", UpdateRequest{Title: "Chau"}); !errors.Is(err, ErrNotificationCancelled) {
+		t.Fatalf("expected ErrNotificationCancelled, got %v", err)
+	}
+	if repo.notifications["n1"].Title != "Hola" {
+		t.Fatalf("expected the title not to change, got %q", repo.notifications["n1"].Title)
+	}
+}
+
+func TestUpdate_DispatchedIsRejected(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", UserID: "usr_123", Status: StatusQueued}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	if _, err := s.Update(asUser("usr_123"), "n1", UpdateRequest{Title: "Chau"}); !errors.Is(err, ErrAlreadyDispatched) {
+		t.Fatalf("expected ErrAlreadyDispatched, got %v", err)
+	}
+}
+
+func TestDelete_ScheduledIsCancelled(t *testing.T) {
+	repo := newFakeRepository()
+	scheduledAt := time.Now().Add(time.Hour)
+	repo.notifications["n1"] = &Notification{ID: "n1", UserID: "usr_123", Status: StatusScheduled, ScheduledAt: &scheduledAt}
+	repo.notifications["n2"] = &Notification{ID: "n2", UserID: "usr_123", Status: StatusDelivered}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	if err := s.Delete(asUser("usr_123"), "n1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := repo.notifications["n1"]; ok {
+		t.Fatal("expected n1 to be deleted")
+	}
+	if err := s.Delete(asUser("usr_123"), "n2"); !errors.Is(err, ErrAlreadyDispatched) {
+		t.Fatalf("expected ErrAlreadyDispatched, got %v", err)
+	}
+}
+
+func TestGetByID_OtherUserIsNotFound(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", UserID: "usr_123", Status: StatusDelivered}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+
+	if _, err := s.GetByID(asUser("usr_123"), "n1"); err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if _, err := s.GetByID(asUser("usr_456"), "n1"); !errors.Is(err, ErrNotificationNotFound) {
+		t.Fatalf("expected ErrNotificationNotFound for another user, got %v", err)
+	}
+	if _, err := s.GetByID(context.Background(), "n1"); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Fatalf("expected ErrUnauthenticated without a caller, got %v", err)
+	}
+}
+
+func TestCreate_ChannelNotAllowedForKey(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry(&stubChannel{name: "email"}, &stubChannel{name: "sms"}))
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: "usr_123", KeyID: "k1", AllowedChannels: []string{"email"}})
+
+	if _, err := s.Create(ctx, CreateRequest{ChannelName: "sms"}); !errors.Is(err, ErrChannelNotAllowed) {
+		t.Fatalf("expected ErrChannelNotAllowed, got %v", err)
+	}
+	if _, err := s.Create(ctx, CreateRequest{ChannelName: "email"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+}
+
+type fakeContactBook struct {
+	to string
+}
+
+func (b *fakeContactBook) FillAddress(ctx context.Context, userID, channelName string, meta map[string]string) (map[string]string, error) {
+	filled := map[string]string{"to": b.to}
+	for k, v := range meta {
+		filled[k] = v
+	}
+	return filled, nil
+}
+
+func TestCreate_FillsAddressFromContactBook(t *testing.T) {
+	repo := newFakeRepository()
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry(&stubChannel{name: "email"}))
+	s.EnableContactBook(&fakeContactBook{to: "user@example.com"})
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "email", Meta: map[string]string{"subject": "Hola"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n.Meta["to"] != "user@example.com" || n.Meta["subject"] != "Hola" {
+		t.Fatalf("expected address to be filled from the contact book, got %v", n.Meta)
+	}
+}
+
+// fakePreferences blocks every notification of a category and
+// is quiet until quietUntil when set
+type fakePreferences struct {
+	blocked    string
+	quietUntil time.Time
+}
+
+func (p *fakePreferences) QuietUntil(ctx context.Context, userID, channelName string, now time.Time) (time.Time, bool, error) {
+	return p.quietUntil, now.Before(p.quietUntil), nil
+}
+
+func (p *fakePreferences) SuppressionReason(ctx context.Context, userID, channelName, category string) (string, error) {
+	if p.blocked != "" && category == p.blocked {
+		return "user opted out of " + category, nil
+	}
+	return "", nil
+}
+
+func TestCreate_OptedOutIsSuppressed(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "sms"}))
+	s.EnablePreferences(&fakePreferences{blocked: "marketing"})
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "sms", Category: "Marketing"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n.Status != StatusSuppressed || n.SuppressedAt == nil || n.SuppressionReason != "user opted out of marketing" {
+		t.Fatalf("expected suppressed with a reason, got %s %q", n.Status, n.SuppressionReason)
+	}
+	if _, ok := repo.notifications[n.ID]; !ok {
+		t.Fatal("expected the suppressed notification to be stored")
+	}
+	if len(queue.published) != 0 {
+		t.Fatalf("expected nothing published, got %d", len(queue.published))
+	}
+
+	if _, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "sms", Category: "billing"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(queue.published) != 1 {
+		t.Fatalf("expected other categories to be published, got %d", len(queue.published))
+	}
+}
+
+func TestDispatchDue_SuppressesAfterOptOut(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "sms"}))
+	sendAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "sms", Category: "marketing", SendAt: sendAt.Format(time.RFC3339)})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	// The user opts out while the notification is scheduled
+	s.EnablePreferences(&fakePreferences{blocked: "marketing"})
+	published, err := s.DispatchDue(context.Background(), sendAt)
+	if err != nil {
+		t.Fatalf("DispatchDue: %v", err)
+	}
+
+	if published != 0 || len(queue.published) != 0 {
+		t.Fatalf("expected nothing published, got %d", published)
+	}
+	if got := repo.notifications[n.ID]; got.Status != StatusSuppressed || got.SuppressionReason == "" {
+		t.Fatalf("expected suppressed with a reason, got %s %q", got.Status, got.SuppressionReason)
+	}
+}
+
+func TestDeferForQuietHours_ReschedulesUntilWindowEnds(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "push"}))
+	now := time.Date(2024, 11, 3, 3, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)
+	s.EnablePreferences(&fakePreferences{quietUntil: until})
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "push"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if n.Priority != PriorityNormal || queue.published[0].Priority != PriorityNormal {
+		t.Fatalf("expected normal priority by default, got %q", n.Priority)
+	}
+
+	deferredTo, err := s.DeferForQuietHours(context.Background(), queue.published[0], "push", 0, now)
+	if err != nil {
+		t.Fatalf("DeferForQuietHours: %v", err)
+	}
+	if deferredTo == nil || !deferredTo.Equal(until) {
+		t.Fatalf("expected deferral to %v, got %v", until, deferredTo)
+	}
+	if got := repo.notifications[n.ID]; got.Status != StatusScheduled || !got.ScheduledAt.Equal(until) {
+		t.Fatalf("expected scheduled at %v, got %s %v", until, got.Status, got.ScheduledAt)
+	}
+
+	published, err := s.DispatchDue(context.Background(), until)
+	if err != nil || published != 1 {
+		t.Fatalf("expected the notification to be published when quiet hours end, got %d %v", published, err)
+	}
+}
+
+func TestDeferForQuietHours_ShortWindowIsPublishedAgain(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "push"}))
+	now := time.Date(2024, 11, 3, 6, 58, 0, 0, time.UTC)
+	// Shorter than the 5 minutes SQS remembers deduplication IDs for
+	until := now.Add(2 * time.Minute)
+	s.EnablePreferences(&fakePreferences{quietUntil: until})
+	if _, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "push"}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if _, err := s.DeferForQuietHours(context.Background(), queue.published[0], "push", 2, now); err != nil {
+		t.Fatalf("DeferForQuietHours: %v", err)
+	}
+	if published, err := s.DispatchDue(context.Background(), until); err != nil || published != 1 {
+		t.Fatalf("expected the notification to be published again, got %d %v", published, err)
+	}
+
+	first, again := queue.published[0], queue.published[1]
+	if first.DeduplicationID() == again.DeduplicationID() {
+		t.Fatalf("expected a new deduplication ID, both are %s", first.DeduplicationID())
+	}
+	if again.RetryOffset != 2 {
+		t.Fatalf("expected the retries to continue from 2, got %d", again.RetryOffset)
+	}
+}
+
+func TestDeferForQuietHours_FallbackDeferralIsNotEditable(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "push"}, &stubChannel{name: "email"}))
+	now := time.Date(2024, 11, 3, 3, 0, 0, 0, time.UTC)
+	until := time.Date(2024, 11, 3, 7, 0, 0, 0, time.UTC)
+	s.EnablePreferences(&fakePreferences{quietUntil: until})
+	ctx := asUser("usr_123")
+	n, err := s.Create(ctx, CreateRequest{ChannelName: "push", Fallback: []string{"email"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := s.MarkSending(ctx, n.ID, false); err != nil {
+		t.Fatalf("MarkSending: %v", err)
+	}
+
+	// push failed and the chain falls back to email inside the quiet hours
+	deferredTo, err := s.DeferForQuietHours(ctx, queue.published[0], "email", 1, now)
+	if err != nil {
+		t.Fatalf("DeferForQuietHours: %v", err)
+	}
+	got := repo.notifications[n.ID]
+	if deferredTo == nil || got.Status != StatusScheduled || got.DeferredUntil == nil || !got.DeferredUntil.Equal(until) {
+		t.Fatalf("expected a deferral to %v, got %s %v", until, got.Status, got.DeferredUntil)
+	}
+
+	if _, err := s.Update(ctx, n.ID, UpdateRequest{Title: "changed"}); !errors.Is(err, ErrAlreadyDispatched) {
+		t.Fatalf("expected ErrAlreadyDispatched on update, got %v", err)
+	}
+	if err := s.Delete(ctx, n.ID); !errors.Is(err, ErrAlreadyDispatched) {
+		t.Fatalf("expected ErrAlreadyDispatched on delete, got %v", err)
+	}
+}
+
+func TestDeferForQuietHours_UrgentIsNotDeferred(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "push"}))
+	now := time.Date(2024, 11, 3, 3, 0, 0, 0, time.UTC)
+	s.EnablePreferences(&fakePreferences{quietUntil: now.Add(4 * time.Hour)})
+
+	if _, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "push", Priority: PriorityUrgent}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	deferredTo, err := s.DeferForQuietHours(context.Background(), queue.published[0], "push", 0, now)
+	if err != nil {
+		t.Fatalf("DeferForQuietHours: %v", err)
+	}
+	if deferredTo != nil {
+		t.Fatalf("expected urgent notification to be sent now, got deferral to %v", deferredTo)
+	}
+}
+
+func TestCreate_FallbackChain(t *testing.T) {
+	invalid := &ValidationError{}
+	invalid.Add("token", "token is required")
+	registry := NewChannelRegistry(&stubChannel{name: "push", validateErr: invalid}, &stubChannel{name: "email"}, &stubChannel{name: "sms", validateErr: invalid})
+
+	tests := []struct {
+		name     string
+		channel  string
+		fallback []string
+		wantErr  error
+	}{
+		{"a later channel can use the meta", "push", []string{"email"}, nil},
+		{"no channel can use the meta", "push", []string{"sms"}, ErrInvalidChannel},
+		{"unknown fallback", "email", []string{"fax"}, ErrUnknownChannel},
+		{"repeated channel", "email", []string{"push", "email"}, ErrInvalidChannel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := &fakeQueue{}
+			s := NewService(newFakeRepository(), queue, registry)
+
+			_, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: tt.channel, Fallback: tt.fallback})
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("Create: %v", err)
+				}
+				if got := queue.published[0].Channels(); len(got) != 2 || got[0] != tt.channel || got[1] != tt.fallback[0] {
+					t.Fatalf("expected the chain to be published, got %v", got)
+				}
+				return
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestCreate_FallbackNotAllowedForKey(t *testing.T) {
+	s := NewService(newFakeRepository(), &fakeQueue{}, NewChannelRegistry(&stubChannel{name: "email"}, &stubChannel{name: "sms"}))
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{UserID: "usr_123", KeyID: "k1", AllowedChannels: []string{"email"}})
+
+	if _, err := s.Create(ctx, CreateRequest{ChannelName: "email", Fallback: []string{"sms"}}); !errors.Is(err, ErrChannelNotAllowed) {
+		t.Fatalf("expected ErrChannelNotAllowed, got %v", err)
+	}
+}
+
+func TestCreate_OptedOutChannelIsDroppedFromChain(t *testing.T) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&stubChannel{name: "sms"}, &stubChannel{name: "email"}))
+	s.EnablePreferences(&channelPreferences{blocked: "sms"})
+
+	n, err := s.Create(asUser("usr_123"), CreateRequest{ChannelName: "sms", Fallback: []string{"email"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if n.Status == StatusSuppressed || n.ChannelName != "email" || len(n.Fallback) != 0 {
+		t.Fatalf("expected email only, got %s %s %v", n.Status, n.ChannelName, n.Fallback)
+	}
+	if queue.published[0].ChannelName != "email" {
+		t.Fatalf("expected email to be published, got %s", queue.published[0].ChannelName)
+	}
+}
+
+// channelPreferences blocks every notification sent through a channel
+type channelPreferences struct {
+	fakePreferences
+	blocked string
+}
+
+func (p *channelPreferences) SuppressionReason(ctx context.Context, userID, channelName, category string) (string, error) {
+	if channelName == p.blocked {
+		return "user opted out of " + channelName, nil
+	}
+	return "", nil
+}
+
+func TestRecordChannelPath(t *testing.T) {
+	repo := newFakeRepository()
+	repo.notifications["n1"] = &Notification{ID: "n1", ChannelName: "push", Fallback: []string{"email"}}
+	s := NewService(repo, &fakeQueue{}, NewChannelRegistry())
+	steps := []ChannelStep{{Channel: "push", Error: "bad token"}, {Channel: "email"}}
+
+	if err := s.RecordChannelPath(context.Background(), "n1", "email", steps); err != nil {
+		t.Fatalf("RecordChannelPath: %v", err)
+	}
+
+	if n := repo.notifications["n1"]; n.DeliveredChannel != "email" || len(n.ChannelPath) != 2 {
+		t.Fatalf("expected the path to be recorded, got %q %+v", n.DeliveredChannel, n.ChannelPath)
+	}
+}
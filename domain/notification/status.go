@@ -0,0 +1,108 @@
+package notification
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrStatusChanged     = errors.New("notification status changed")
+)
+
+// Status is the delivery state of a notification
+type Status string
+
+const (
+	StatusScheduled Status = "scheduled"
+	StatusPending   Status = "pending"
+	StatusQueued    Status = "queued"
+	StatusSending   Status = "sending"
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+	StatusCancelled Status = "cancelled"
+	// StatusSuppressed is set instead of sending when the user opted out
+	StatusSuppressed Status = "suppressed"
+)
+
+// transitions lists the statuses reachable from each status
+// pending can go straight to sending because the dispatcher may pick the message
+// before Create marks it as queued, and failed goes back to sending on SQS retries
+// sending goes back to sending when the dispatcher stopped midway and SQS redelivered it, see MarkSending
+// The dispatcher moves pending, queued and failed back to scheduled during quiet hours,
+// and sending when the chain falls back to a channel inside them
+var transitions = map[Status][]Status{
+	StatusScheduled: {StatusQueued, StatusCancelled, StatusSuppressed},
+	StatusPending:   {StatusQueued, StatusSending, StatusFailed, StatusCancelled, StatusScheduled},
+	StatusQueued:    {StatusSending, StatusCancelled, StatusScheduled},
+	StatusSending:   {StatusSending, StatusDelivered, StatusFailed, StatusScheduled},
+	StatusFailed:    {StatusSending, StatusScheduled},
+}
+
+// CanTransitionTo reports whether the status can move to next
+func (s Status) CanTransitionTo(next Status) bool {
+	for _, allowed := range transitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether no further transitions are possible
+func (s Status) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// IsDispatched reports whether the notification left the scheduler's hands,
+// after that it can no longer be updated or deleted
+// One deferred for quiet hours is scheduled again but was already dispatched
+func (n *Notification) IsDispatched() bool {
+	if n.DeferredUntil != nil {
+		return n.Status != StatusCancelled
+	}
+	return n.Status != StatusScheduled && n.Status != StatusCancelled
+}
+
+// StatusChange describes a transition to apply to a notification
+type StatusChange struct {
+	To     Status
+	At     time.Time
+	Reason string // only for failed and suppressed
+	// ScheduledAt is when a notification moved back to scheduled is due again
+	// and RetryOffset where the numbering of its attempts continues
+	ScheduledAt time.Time
+	RetryOffset int
+	// Deferred marks the move back to scheduled as a hold for quiet hours
+	Deferred bool
+}
+
+// apply updates the in-memory notification with the change
+func (c StatusChange) apply(n *Notification) {
+	n.Status = c.To
+	n.UpdatedAt = c.At
+	at := c.At
+	switch c.To {
+	case StatusScheduled:
+		scheduledAt := c.ScheduledAt
+		n.ScheduledAt = &scheduledAt
+		n.RetryOffset = c.RetryOffset
+		if c.Deferred {
+			n.DeferredUntil = &scheduledAt
+		}
+	case StatusQueued:
+		n.QueuedAt = &at
+	case StatusSending:
+		n.SendingAt = &at
+	case StatusDelivered:
+		n.DeliveredAt = &at
+	case StatusFailed:
+		n.FailedAt = &at
+		n.FailureReason = c.Reason
+	case StatusCancelled:
+		n.CancelledAt = &at
+	case StatusSuppressed:
+		n.SuppressedAt = &at
+		n.SuppressionReason = c.Reason
+	}
+}
@@ -0,0 +1,47 @@
+package notification
+
+import "testing"
+
+func TestStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from Status
+		to   Status
+		want bool
+	}{
+		{StatusScheduled, StatusQueued, true},
+		{StatusScheduled, StatusCancelled, true},
+		{StatusScheduled, StatusSending, false},
+		{StatusScheduled, StatusSuppressed, true},
+		{StatusQueued, StatusSuppressed, false},
+		{StatusQueued, StatusScheduled, true},
+		{StatusSending, StatusScheduled, true},
+		{StatusPending, StatusQueued, true},
+		{StatusPending, StatusSending, true},
+		{StatusQueued, StatusSending, true},
+		{StatusQueued, StatusCancelled, true},
+		{StatusSending, StatusDelivered, true},
+		{StatusSending, StatusFailed, true},
+		{StatusFailed, StatusSending, true},
+		{StatusQueued, StatusDelivered, false},
+		{StatusSending, StatusCancelled, false},
+		{StatusDelivered, StatusSending, false},
+		{StatusCancelled, StatusSending, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.from)+"->"+string(tt.to), func(t *testing.T) {
+			if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStatus_IsFinal(t *testing.T) {
+	if !StatusDelivered.IsFinal() || !StatusCancelled.IsFinal() || !StatusSuppressed.IsFinal() {
+		t.Fatal("expected delivered, cancelled and suppressed to be final")
+	}
+	if StatusFailed.IsFinal() || StatusQueued.IsFinal() {
+		t.Fatal("expected failed and queued not to be final")
+	}
+}
@@ -0,0 +1,20 @@
+package notification
+
+import (
+	"context"
+	"time"
+)
+
+// Template is a stored email template, Text is required and HTML is an optional alternative
+// Both are Go templates rendering the Message
+type Template struct {
+	Name      string
+	Text      string
+	HTML      string
+	UpdatedAt time.Time
+}
+
+// TemplateStore lists the stored templates, the email channel loads them at startup
+type TemplateStore interface {
+	ListTemplates(ctx context.Context) ([]*Template, error)
+}
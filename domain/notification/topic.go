@@ -0,0 +1,91 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+var ErrTopicsDisabled = errors.New("topics are not enabled")
+
+// TopicSubscribers lists who receives what is published to a topic
+type TopicSubscribers interface {
+	// Subscribers returns the users subscribed to topicID, failing if the caller cannot publish to it
+	Subscribers(ctx context.Context, topicID string) ([]string, error)
+}
+
+// Publication summarizes what publishing to a topic created
+type Publication struct {
+	TopicID string `json:"topic_id"`
+	Total   int    `json:"total"`
+	// Counts is how many of the notifications are in each status,
+	// the ones that could not be stored count as failed
+	Counts map[Status]int `json:"counts"`
+	// Errors are the notifications that could not be stored or queued
+	Errors []BatchError `json:"errors,omitempty"`
+}
+
+// EnableTopics makes PublishTopic send to the subscribers of topics
+func (s *Service) EnableTopics(topics TopicSubscribers) {
+	s.topics = topics
+}
+
+// PublishTopic creates the notification of req for every subscriber of a topic and
+// stores and queues each one like Create does. A subscriber that cannot receive it
+// (e.g. no address) gets a failed notification with the reason
+// A notification that cannot be stored or queued does not stop the rest, since retrying
+// would send to the earlier subscribers again. It is reported in Errors, and it only
+// fails when none of the notifications could be published
+func (s *Service) PublishTopic(ctx context.Context, topicID string, req CreateRequest) (*Publication, error) {
+	principal, ok := auth.FromContext(ctx)
+	if !ok {
+		return nil, auth.ErrUnauthenticated
+	}
+	if s.topics == nil {
+		return nil, ErrTopicsDisabled
+	}
+	now := time.Now()
+	scheduledAt, err := s.checkRequest(principal, req, now)
+	if err != nil {
+		return nil, err
+	}
+	subscribers, err := s.topics.Subscribers(ctx, topicID)
+	if err != nil {
+		return nil, err
+	}
+
+	publication := &Publication{TopicID: topicID, Total: len(subscribers), Counts: make(map[Status]int)}
+	var errs []error
+	for start := 0; start < len(subscribers); start += batchChunkSize {
+		end := min(start+batchChunkSize, len(subscribers))
+		entries := make([]batchEntry, 0, end-start)
+		schedules := make([]*time.Time, 0, end-start)
+		for i, userID := range subscribers[start:end] {
+			entries = append(entries, batchEntry{index: start + i, userID: userID, req: req})
+			schedules = append(schedules, scheduledAt)
+		}
+		for i, n := range s.buildChunk(ctx, principal.UserID, entries, schedules, now) {
+			n.Topic = topicID
+			if err := s.store(ctx, n); err != nil {
+				errs = append(errs, err)
+				publication.Errors = append(publication.Errors, BatchError{Index: entries[i].index, UserID: n.UserID, Error: err.Error()})
+				// A stored notification that was not published is failed rather than left pending
+				if errors.Is(err, errNotQueued) {
+					if markErr := s.transition(ctx, n, StatusFailed, err.Error()); markErr != nil {
+						log.Printf("Failed to mark notification %s as failed: %v", n.ID, markErr)
+					}
+				}
+				publication.Counts[StatusFailed]++
+				continue
+			}
+			publication.Counts[n.Status]++
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(subscribers) {
+		return nil, errors.Join(errs...)
+	}
+	return publication, nil
+}
@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"serverless-notification/domain/auth"
+)
+
+// fakeTopics lets only owner publish to its topics
+type fakeTopics struct {
+	owner       string
+	subscribers map[string][]string
+}
+
+func (f *fakeTopics) Subscribers(ctx context.Context, topicID string) ([]string, error) {
+	if userID, _ := auth.UserID(ctx); userID != f.owner {
+		return nil, auth.ErrForbidden
+	}
+	return f.subscribers[topicID], nil
+}
+
+func newTopicService(book addressBook, topics *fakeTopics) (*Service, *fakeRepository, *fakeQueue) {
+	repo := newFakeRepository()
+	queue := &fakeQueue{}
+	s := NewService(repo, queue, NewChannelRegistry(&addressedChannel{stubChannel{name: "email"}}))
+	s.EnableContactBook(book)
+	s.EnableTopics(topics)
+	return s, repo, queue
+}
+
+func TestPublishTopic_SendsToEverySubscriber(t *testing.T) {
+	topics := &fakeTopics{owner: "usr_ops", subscribers: map[string][]string{"order-updates": {"usr_1", "usr_2", "usr_3"}}}
+	s, repo, queue := newTopicService(addressBook{"usr_1": "one@example.com", "usr_2": "two@example.com"}, topics)
+
+	publication, err := s.PublishTopic(asUser("usr_ops"), "order-updates", CreateRequest{Title: "Shipped", Content: "On its way", ChannelName: "email"})
+	if err != nil {
+		t.Fatalf("PublishTopic: %v", err)
+	}
+	if publication.Total != 3 || publication.Counts[StatusQueued] != 2 || publication.Counts[StatusFailed] != 1 {
+		t.Fatalf("expected 2 queued and 1 failed, got total %d counts %v", publication.Total, publication.Counts)
+	}
+	if len(queue.published) != 2 {
+		t.Fatalf("expected a message per addressable subscriber, got %d", len(queue.published))
+	}
+	for _, n := range repo.notifications {
+		if n.Topic != "order-updates" {
+			t.Fatalf("expected notification of the topic, got %q", n.Topic)
+		}
+		if n.UserID == "usr_3" && n.Status != StatusFailed {
+			t.Fatalf("expected usr_3 without address to fail, got %s", n.Status)
+		}
+	}
+}
+
+func TestPublishTopic_EnqueueFailureIsReported(t *testing.T) {
+	topics := &fakeTopics{owner: "usr_ops", subscribers: map[string][]string{"order-updates": {"usr_1", "usr_2"}}}
+	s, repo, queue := newTopicService(addressBook{"usr_1": "one@example.com", "usr_2": "two@example.com"}, topics)
+	queue.onPublish = func(message *DispatchMessage) error {
+		if message.UserID == "usr_2" {
+			return errors.New("throttled")
+		}
+		return nil
+	}
+
+	publication, err := s.PublishTopic(asUser("usr_ops"), "order-updates", CreateRequest{Title: "Shipped", Content: "On its way", ChannelName: "email"})
+	if err != nil {
+		t.Fatalf("expected the failure to be reported, not returned, got %v", err)
+	}
+	if publication.Counts[StatusQueued] != 1 || publication.Counts[StatusFailed] != 1 {
+		t.Fatalf("expected 1 queued and 1 failed, got %v", publication.Counts)
+	}
+	if len(publication.Errors) != 1 || publication.Errors[0].Index != 1 || publication.Errors[0].UserID != "usr_2" {
+		t.Fatalf("expected usr_2 to be reported, got %+v", publication.Errors)
+	}
+	for _, n := range repo.notifications {
+		if n.UserID == "usr_2" && n.Status != StatusFailed {
+			t.Fatalf("expected the unpublished notification to be failed, got %s", n.Status)
+		}
+	}
+
+	queue.onPublish = func(message *DispatchMessage) error { return errors.New("throttled") }
+	if _, err := s.PublishTopic(asUser("usr_ops"), "order-updates", CreateRequest{Title: "Shipped", Content: "On its way", ChannelName: "email"}); err == nil {
+		t.Fatal("expected an error when nothing was published")
+	}
+}
+
+func TestPublishTopic_NotOwner(t *testing.T) {
+	topics := &fakeTopics{owner: "usr_ops", subscribers: map[string][]string{"order-updates": {"usr_1"}}}
+	s, repo, _ := newTopicService(addressBook{"usr_1": "one@example.com"}, topics)
+
+	_, err := s.PublishTopic(asUser("usr_1"), "order-updates", CreateRequest{Title: "a", Content: "b", ChannelName: "email"})
+	if !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("expected ErrForbidden, got %v", err)
+	}
+	if len(repo.notifications) != 0 {
+		t.Fatal("expected nothing to be stored")
+	}
+}
@@ -0,0 +1,110 @@
+package preference
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Preferences are the delivery settings of a user
+type Preferences struct {
+	OptOuts    []OptOut    `json:"opt_outs"`
+	QuietHours *QuietHours `json:"quiet_hours,omitempty"`
+	UpdatedAt  *time.Time  `json:"updated_at,omitempty"` // nil until the user saves them
+}
+
+// OptOut blocks the notifications of a channel, a category or a category on one channel
+// An empty field matches any value, so {"channel":"sms"} blocks every SMS
+type OptOut struct {
+	Channel  string `json:"channel,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+// QuietHours is a daily window, in the user's time zone, during which
+// non-urgent notifications are deferred until the window ends
+// Start after End spans midnight, e.g. 22:00 to 07:00
+type QuietHours struct {
+	Start    string   `json:"start"`              // HH:MM
+	End      string   `json:"end"`                // HH:MM
+	TimeZone string   `json:"time_zone"`          // IANA name
+	Channels []string `json:"channels,omitempty"` // empty applies to every channel
+}
+
+// UpdateRequest replaces every preference, leaving a field out clears it
+type UpdateRequest struct {
+	OptOuts    []OptOut    `json:"opt_outs"`
+	QuietHours *QuietHours `json:"quiet_hours"`
+}
+
+// Blocking returns the first opt-out matching a notification of category sent through channel
+func (p *Preferences) Blocking(channel, category string) (OptOut, bool) {
+	for _, o := range p.OptOuts {
+		if o.Channel != "" && o.Channel != channel {
+			continue
+		}
+		// Notifications without a category are only blocked by channel-wide opt-outs
+		if o.Category != "" && o.Category != category {
+			continue
+		}
+		return o, true
+	}
+	return OptOut{}, false
+}
+
+// String describes the opt-out, it is stored as the suppression reason
+func (o OptOut) String() string {
+	switch {
+	case o.Channel != "" && o.Category != "":
+		return "user opted out of " + o.Category + " on " + o.Channel
+	case o.Channel != "":
+		return "user opted out of " + o.Channel
+	default:
+		return "user opted out of " + o.Category
+	}
+}
+
+// Until returns when the window containing now ends, false when now is outside
+// the window or channel is not covered
+func (q *QuietHours) Until(channel string, now time.Time) (time.Time, bool) {
+	if len(q.Channels) > 0 && !slices.Contains(q.Channels, channel) {
+		return time.Time{}, false
+	}
+	loc, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start, err := minuteOfDay(q.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	end, err := minuteOfDay(q.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	endDay := local
+	switch {
+	case start < end && minute >= start && minute < end:
+		// same-day window, ends today
+	case start > end && minute < end:
+		// past midnight, ends today
+	case start > end && minute >= start:
+		// before midnight, ends tomorrow
+		endDay = local.AddDate(0, 0, 1)
+	default:
+		return time.Time{}, false
+	}
+	y, m, d := endDay.Date()
+	return time.Date(y, m, d, end/60, end%60, 0, 0, loc), true
+}
+
+// minuteOfDay parses an HH:MM clock time
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,42 @@
+package preference
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHours_Until(t *testing.T) {
+	buenosAires, err := time.LoadLocation("America/Argentina/Buenos_Aires")
+	if err != nil {
+		t.Fatalf("LoadLocation: %v", err)
+	}
+	overnight := &QuietHours{Start: "22:00", End: "07:00", TimeZone: "America/Argentina/Buenos_Aires"}
+	afternoon := &QuietHours{Start: "13:00", End: "15:30", TimeZone: "America/Argentina/Buenos_Aires", Channels: []string{"sms"}}
+
+	tests := []struct {
+		name    string
+		q       *QuietHours
+		channel string
+		now     time.Time
+		want    time.Time // zero when not quiet
+	}{
+		{"before midnight ends tomorrow", overnight, "push", time.Date(2024, 11, 2, 23, 0, 0, 0, buenosAires), time.Date(2024, 11, 3, 7, 0, 0, 0, buenosAires)},
+		{"after midnight ends today", overnight, "push", time.Date(2024, 11, 3, 3, 0, 0, 0, buenosAires), time.Date(2024, 11, 3, 7, 0, 0, 0, buenosAires)},
+		{"end is not quiet", overnight, "push", time.Date(2024, 11, 3, 7, 0, 0, 0, buenosAires), time.Time{}},
+		{"daytime", overnight, "push", time.Date(2024, 11, 3, 12, 0, 0, 0, buenosAires), time.Time{}},
+		{"same-day window", afternoon, "sms", time.Date(2024, 11, 3, 14, 0, 0, 0, buenosAires), time.Date(2024, 11, 3, 15, 30, 0, 0, buenosAires)},
+		{"channel not covered", afternoon, "email", time.Date(2024, 11, 3, 14, 0, 0, 0, buenosAires), time.Time{}},
+		{"now in another zone", overnight, "push", time.Date(2024, 11, 3, 5, 0, 0, 0, time.UTC), time.Date(2024, 11, 3, 7, 0, 0, 0, buenosAires)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, quiet := tt.q.Until(tt.channel, tt.now)
+			if quiet != !tt.want.IsZero() {
+				t.Fatalf("expected quiet=%v, got %v", !tt.want.IsZero(), quiet)
+			}
+			if quiet && !got.Equal(tt.want) {
+				t.Fatalf("expected until %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
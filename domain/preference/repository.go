@@ -0,0 +1,10 @@
+package preference
+
+import "context"
+
+// Repository defines the contract for preference persistence
+type Repository interface {
+	// GetPreferences returns empty preferences for a user who never saved any
+	GetPreferences(ctx context.Context, userID string) (*Preferences, error)
+	PutPreferences(ctx context.Context, userID string, p *Preferences) error
+}
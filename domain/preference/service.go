@@ -0,0 +1,144 @@
+package preference
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+var ErrInvalidPreferences = errors.New("invalid preferences")
+
+// maxOptOuts keeps the preferences item small, it is read on every send
+const maxOptOuts = 100
+
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+
+// Service contains the business logic for the preferences of the authenticated user
+type Service struct {
+	repo Repository
+}
+
+// NewService creates a new instance of the service
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Get returns the preferences of the authenticated user
+func (s *Service) Get(ctx context.Context) (*Preferences, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetPreferences(ctx, userID)
+}
+
+// Put replaces the preferences of the authenticated user
+// Channels and categories are lowercased and duplicate opt-outs are dropped
+func (s *Service) Put(ctx context.Context, req UpdateRequest) (*Preferences, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.OptOuts) > maxOptOuts {
+		return nil, fmt.Errorf("%w: at most %d opt-outs are allowed", ErrInvalidPreferences, maxOptOuts)
+	}
+
+	optOuts := make([]OptOut, 0, len(req.OptOuts))
+	seen := map[OptOut]bool{}
+	for _, o := range req.OptOuts {
+		o.Channel = strings.ToLower(strings.TrimSpace(o.Channel))
+		o.Category = strings.ToLower(strings.TrimSpace(o.Category))
+		if o.Channel == "" && o.Category == "" {
+			return nil, fmt.Errorf("%w: an opt-out needs a channel or a category", ErrInvalidPreferences)
+		}
+		for _, name := range []string{o.Channel, o.Category} {
+			if name != "" && !namePattern.MatchString(name) {
+				return nil, fmt.Errorf("%w: %q must be lowercase letters, digits, - or _", ErrInvalidPreferences, name)
+			}
+		}
+		if !seen[o] {
+			seen[o] = true
+			optOuts = append(optOuts, o)
+		}
+	}
+
+	quietHours, err := validateQuietHours(req.QuietHours)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	p := &Preferences{OptOuts: optOuts, QuietHours: quietHours, UpdatedAt: &now}
+	if err := s.repo.PutPreferences(ctx, userID, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SuppressionReason returns why a notification of category sent through channelName
+// must not reach userID, or "" when the user accepts it
+func (s *Service) SuppressionReason(ctx context.Context, userID, channelName, category string) (string, error) {
+	p, err := s.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if o, blocked := p.Blocking(channelName, category); blocked {
+		return o.String(), nil
+	}
+	return "", nil
+}
+
+// QuietUntil returns when the quiet hours of userID covering channelName end,
+// false when a notification can be sent at now
+func (s *Service) QuietUntil(ctx context.Context, userID, channelName string, now time.Time) (time.Time, bool, error) {
+	p, err := s.repo.GetPreferences(ctx, userID)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	if p.QuietHours == nil {
+		return time.Time{}, false, nil
+	}
+	until, quiet := p.QuietHours.Until(channelName, now)
+	return until, quiet, nil
+}
+
+func validateQuietHours(q *QuietHours) (*QuietHours, error) {
+	if q == nil {
+		return nil, nil
+	}
+	start, err := minuteOfDay(q.Start)
+	if err != nil {
+		return nil, fmt.Errorf("%w: quiet_hours.start %w", ErrInvalidPreferences, err)
+	}
+	end, err := minuteOfDay(q.End)
+	if err != nil {
+		return nil, fmt.Errorf("%w: quiet_hours.end %w", ErrInvalidPreferences, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("%w: quiet_hours.start and end must differ", ErrInvalidPreferences)
+	}
+	if q.TimeZone == "" {
+		return nil, fmt.Errorf("%w: quiet_hours.time_zone is required", ErrInvalidPreferences)
+	}
+	if _, err := time.LoadLocation(q.TimeZone); err != nil {
+		return nil, fmt.Errorf("%w: quiet_hours.time_zone must be a valid IANA time zone", ErrInvalidPreferences)
+	}
+
+	validated := &QuietHours{Start: q.Start, End: q.End, TimeZone: q.TimeZone}
+	for _, channel := range q.Channels {
+		channel = strings.ToLower(strings.TrimSpace(channel))
+		if !namePattern.MatchString(channel) {
+			return nil, fmt.Errorf("%w: %q is not a channel name", ErrInvalidPreferences, channel)
+		}
+		if !slices.Contains(validated.Channels, channel) {
+			validated.Channels = append(validated.Channels, channel)
+		}
+	}
+	return validated, nil
+}
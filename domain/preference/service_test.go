@@ -0,0 +1,131 @@
+package preference
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"serverless-notification/domain/auth"
+)
+
+type fakeRepository struct {
+	preferences map[string]*Preferences
+}
+
+func (r *fakeRepository) GetPreferences(ctx context.Context, userID string) (*Preferences, error) {
+	p, ok := r.preferences[userID]
+	if !ok {
+		return &Preferences{OptOuts: []OptOut{}}, nil
+	}
+	return p, nil
+}
+
+func (r *fakeRepository) PutPreferences(ctx context.Context, userID string, p *Preferences) error {
+	r.preferences[userID] = p
+	return nil
+}
+
+func asUser(userID string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: userID})
+}
+
+func TestPut_NormalizesAndDeduplicates(t *testing.T) {
+	s := NewService(&fakeRepository{preferences: map[string]*Preferences{}})
+
+	p, err := s.Put(asUser("usr_123"), UpdateRequest{OptOuts: []OptOut{
+		{Channel: "SMS", Category: "marketing"},
+		{Channel: "sms", Category: " Marketing "},
+		{Channel: "push"},
+	}})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if len(p.OptOuts) != 2 || p.OptOuts[0] != (OptOut{Channel: "sms", Category: "marketing"}) {
+		t.Fatalf("unexpected opt-outs: %v", p.OptOuts)
+	}
+	if p.UpdatedAt == nil {
+		t.Fatal("expected updated_at to be set")
+	}
+}
+
+func TestPut_RejectsInvalidOptOuts(t *testing.T) {
+	s := NewService(&fakeRepository{preferences: map[string]*Preferences{}})
+
+	tests := []struct {
+		name   string
+		optOut OptOut
+	}{
+		{"empty", OptOut{}},
+		{"invalid category", OptOut{Category: "news letters"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Put(asUser("usr_123"), UpdateRequest{OptOuts: []OptOut{tt.optOut}})
+			if !errors.Is(err, ErrInvalidPreferences) {
+				t.Fatalf("expected ErrInvalidPreferences, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSuppressionReason(t *testing.T) {
+	repo := &fakeRepository{preferences: map[string]*Preferences{
+		"usr_123": {OptOuts: []OptOut{{Channel: "sms", Category: "marketing"}, {Channel: "push"}}},
+	}}
+	s := NewService(repo)
+	ctx := context.Background()
+
+	tests := []struct {
+		channel  string
+		category string
+		want     string
+	}{
+		{"sms", "marketing", "user opted out of marketing on sms"},
+		{"sms", "billing", ""},
+		{"sms", "", ""},
+		{"email", "marketing", ""},
+		{"push", "", "user opted out of push"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.channel+"/"+tt.category, func(t *testing.T) {
+			got, err := s.SuppressionReason(ctx, "usr_123", tt.channel, tt.category)
+			if err != nil {
+				t.Fatalf("SuppressionReason: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPut_ValidatesQuietHours(t *testing.T) {
+	s := NewService(&fakeRepository{preferences: map[string]*Preferences{}})
+
+	tests := []struct {
+		name string
+		q    QuietHours
+	}{
+		{"bad clock", QuietHours{Start: "10pm", End: "07:00", TimeZone: "UTC"}},
+		{"empty window", QuietHours{Start: "07:00", End: "07:00", TimeZone: "UTC"}},
+		{"missing time zone", QuietHours{Start: "22:00", End: "07:00"}},
+		{"unknown time zone", QuietHours{Start: "22:00", End: "07:00", TimeZone: "Mars/Olympus"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.Put(asUser("usr_123"), UpdateRequest{QuietHours: &tt.q})
+			if !errors.Is(err, ErrInvalidPreferences) {
+				t.Fatalf("expected ErrInvalidPreferences, got %v", err)
+			}
+		})
+	}
+
+	p, err := s.Put(asUser("usr_123"), UpdateRequest{QuietHours: &QuietHours{Start: "22:00", End: "07:00", TimeZone: "UTC", Channels: []string{"SMS", "sms"}}})
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if len(p.QuietHours.Channels) != 1 || p.QuietHours.Channels[0] != "sms" {
+		t.Fatalf("expected normalized channels, got %v", p.QuietHours.Channels)
+	}
+}
@@ -0,0 +1,18 @@
+package topic
+
+import "context"
+
+// Repository defines the contract for topic persistence
+// DeleteTopic also removes its subscriptions
+type Repository interface {
+	// CreateTopic fails with ErrTopicExists if the ID is taken
+	CreateTopic(ctx context.Context, t *Topic) error
+	GetTopic(ctx context.Context, id string) (*Topic, error)
+	ListTopics(ctx context.Context) ([]*Topic, error)
+	DeleteTopic(ctx context.Context, id string) error
+	// PutSubscription subscribes the user, subscribing twice is not an error
+	PutSubscription(ctx context.Context, s *Subscription) error
+	// DeleteSubscription fails with ErrNotSubscribed if the user was not subscribed
+	DeleteSubscription(ctx context.Context, topicID, userID string) error
+	ListSubscribers(ctx context.Context, topicID string) ([]string, error)
+}
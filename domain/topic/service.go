@@ -0,0 +1,120 @@
+package topic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"serverless-notification/domain/auth"
+)
+
+var (
+	ErrTopicNotFound = errors.New("topic not found")
+	ErrTopicExists   = errors.New("topic already exists")
+	ErrInvalidTopic  = errors.New("invalid topic")
+	ErrNotSubscribed = errors.New("not subscribed to topic")
+)
+
+var idPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Service contains the business logic for topics and their subscriptions
+type Service struct {
+	repo Repository
+}
+
+// NewService creates a new instance of the service
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Create creates a topic owned by the authenticated user
+func (s *Service) Create(ctx context.Context, req CreateRequest) (*Topic, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := strings.ToLower(strings.TrimSpace(req.ID))
+	if !idPattern.MatchString(id) {
+		return nil, fmt.Errorf("%w: id must be up to 64 lowercase letters, digits or -", ErrInvalidTopic)
+	}
+	topic := &Topic{
+		ID:          id,
+		Name:        strings.TrimSpace(req.Name),
+		Description: strings.TrimSpace(req.Description),
+		OwnerID:     userID,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.repo.CreateTopic(ctx, topic); err != nil {
+		return nil, err
+	}
+	return topic, nil
+}
+
+// List returns every topic, any user can subscribe to them
+func (s *Service) List(ctx context.Context) ([]*Topic, error) {
+	if _, err := auth.UserID(ctx); err != nil {
+		return nil, err
+	}
+	return s.repo.ListTopics(ctx)
+}
+
+// Delete deletes a topic of the authenticated user and its subscriptions
+func (s *Service) Delete(ctx context.Context, id string) error {
+	if _, err := s.getOwned(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.DeleteTopic(ctx, id)
+}
+
+// Subscribe subscribes the authenticated user to a topic
+func (s *Service) Subscribe(ctx context.Context, id string) (*Subscription, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.repo.GetTopic(ctx, id); err != nil {
+		return nil, err
+	}
+	subscription := &Subscription{TopicID: id, UserID: userID, CreatedAt: time.Now()}
+	if err := s.repo.PutSubscription(ctx, subscription); err != nil {
+		return nil, err
+	}
+	return subscription, nil
+}
+
+// Unsubscribe removes the subscription of the authenticated user to a topic
+func (s *Service) Unsubscribe(ctx context.Context, id string) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return err
+	}
+	return s.repo.DeleteSubscription(ctx, id, userID)
+}
+
+// Subscribers returns the users subscribed to a topic of the authenticated user,
+// only the owner of a topic can publish to it
+func (s *Service) Subscribers(ctx context.Context, id string) ([]string, error) {
+	if _, err := s.getOwned(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListSubscribers(ctx, id)
+}
+
+// getOwned gets a topic by ID, failing with auth.ErrForbidden if another user owns it
+func (s *Service) getOwned(ctx context.Context, id string) (*Topic, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	topic, err := s.repo.GetTopic(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if topic.OwnerID != userID {
+		return nil, fmt.Errorf("%w: topic %s belongs to another user", auth.ErrForbidden, id)
+	}
+	return topic, nil
+}
@@ -0,0 +1,147 @@
+package topic
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"serverless-notification/domain/auth"
+)
+
+type fakeRepository struct {
+	topics        map[string]*Topic
+	subscriptions map[string]map[string]bool // topic ID -> user IDs
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{topics: map[string]*Topic{}, subscriptions: map[string]map[string]bool{}}
+}
+
+func (r *fakeRepository) CreateTopic(ctx context.Context, t *Topic) error {
+	if _, ok := r.topics[t.ID]; ok {
+		return ErrTopicExists
+	}
+	r.topics[t.ID] = t
+	return nil
+}
+
+func (r *fakeRepository) GetTopic(ctx context.Context, id string) (*Topic, error) {
+	t, ok := r.topics[id]
+	if !ok {
+		return nil, ErrTopicNotFound
+	}
+	return t, nil
+}
+
+func (r *fakeRepository) ListTopics(ctx context.Context) ([]*Topic, error) {
+	var topics []*Topic
+	for _, t := range r.topics {
+		topics = append(topics, t)
+	}
+	return topics, nil
+}
+
+func (r *fakeRepository) DeleteTopic(ctx context.Context, id string) error {
+	delete(r.topics, id)
+	delete(r.subscriptions, id)
+	return nil
+}
+
+func (r *fakeRepository) PutSubscription(ctx context.Context, s *Subscription) error {
+	if r.subscriptions[s.TopicID] == nil {
+		r.subscriptions[s.TopicID] = map[string]bool{}
+	}
+	r.subscriptions[s.TopicID][s.UserID] = true
+	return nil
+}
+
+func (r *fakeRepository) DeleteSubscription(ctx context.Context, topicID, userID string) error {
+	if !r.subscriptions[topicID][userID] {
+		return ErrNotSubscribed
+	}
+	delete(r.subscriptions[topicID], userID)
+	return nil
+}
+
+func (r *fakeRepository) ListSubscribers(ctx context.Context, topicID string) ([]string, error) {
+	var userIDs []string
+	for userID := range r.subscriptions[topicID] {
+		userIDs = append(userIDs, userID)
+	}
+	return userIDs, nil
+}
+
+func asUser(userID string) context.Context {
+	return auth.WithPrincipal(context.Background(), auth.Principal{UserID: userID})
+}
+
+func TestCreate_NormalizesID(t *testing.T) {
+	repo := newFakeRepository()
+	s := NewService(repo)
+
+	topic, err := s.Create(asUser("usr_ops"), CreateRequest{ID: " Order-Updates ", Name: "Order updates"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if topic.ID != "order-updates" || topic.OwnerID != "usr_ops" {
+		t.Fatalf("expected order-updates owned by usr_ops, got %+v", topic)
+	}
+	if _, ok := repo.topics["order-updates"]; !ok {
+		t.Fatal("expected topic to be stored")
+	}
+}
+
+func TestCreate_InvalidID(t *testing.T) {
+	s := NewService(newFakeRepository())
+
+	for _, id := range []string{"", "-updates", "order updates", "order_updates", "órdenes"} {
+		if _, err := s.Create(asUser("usr_ops"), CreateRequest{ID: id, Name: "x"}); !errors.Is(err, ErrInvalidTopic) {
+			t.Errorf("%q: expected ErrInvalidTopic, got %v", id, err)
+		}
+	}
+}
+
+func TestSubscribe_UnknownTopic(t *testing.T) {
+	s := NewService(newFakeRepository())
+
+	if _, err := s.Subscribe(asUser("usr_1"), "status-page"); !errors.Is(err, ErrTopicNotFound) {
+		t.Fatalf("expected ErrTopicNotFound, got %v", err)
+	}
+}
+
+func TestSubscribeThenUnsubscribe(t *testing.T) {
+	repo := newFakeRepository()
+	repo.topics["status-page"] = &Topic{ID: "status-page", OwnerID: "usr_ops"}
+	s := NewService(repo)
+
+	if _, err := s.Subscribe(asUser("usr_1"), "status-page"); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	subscribers, err := s.Subscribers(asUser("usr_ops"), "status-page")
+	if err != nil || len(subscribers) != 1 || subscribers[0] != "usr_1" {
+		t.Fatalf("expected usr_1 to be subscribed, got %v %v", subscribers, err)
+	}
+
+	if err := s.Unsubscribe(asUser("usr_1"), "status-page"); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	if err := s.Unsubscribe(asUser("usr_1"), "status-page"); !errors.Is(err, ErrNotSubscribed) {
+		t.Fatalf("expected ErrNotSubscribed, got %v", err)
+	}
+}
+
+func TestOwnerOnly(t *testing.T) {
+	repo := newFakeRepository()
+	repo.topics["status-page"] = &Topic{ID: "status-page", OwnerID: "usr_ops"}
+	s := NewService(repo)
+
+	if _, err := s.Subscribers(asUser("usr_1"), "status-page"); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("Subscribers: expected ErrForbidden, got %v", err)
+	}
+	if err := s.Delete(asUser("usr_1"), "status-page"); !errors.Is(err, auth.ErrForbidden) {
+		t.Fatalf("Delete: expected ErrForbidden, got %v", err)
+	}
+	if _, ok := repo.topics["status-page"]; !ok {
+		t.Fatal("expected topic to be kept")
+	}
+}
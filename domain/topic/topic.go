@@ -0,0 +1,25 @@
+package topic
+
+import "time"
+
+// Topic is a named audience, what is published to it goes to every subscriber
+type Topic struct {
+	ID          string    `json:"id"` // chosen by the creator, e.g. "order-updates"
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	OwnerID     string    `json:"owner_id"` // the only user that can publish to it or delete it
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Subscription is a user subscribed to a topic
+type Subscription struct {
+	TopicID   string    `json:"topic_id"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateRequest struct {
+	ID          string `json:"id" binding:"required"`
+	Name        string `json:"name" binding:"required,max=128"`
+	Description string `json:"description" binding:"max=1024"`
+}
@@ -0,0 +1,11 @@
+package user
+
+import "context"
+
+// Repository defines the contract for user persistence
+type Repository interface {
+	// Create stores a user, failing with ErrEmailTaken if another user has the same email
+	Create(ctx context.Context, u *User) error
+	GetByID(ctx context.Context, id string) (*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+}
@@ -0,0 +1,85 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrEmailTaken         = errors.New("email already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrPasswordTooLong    = fmt.Errorf("password must be at most %d bytes", maxPasswordBytes)
+)
+
+// maxPasswordBytes is the longest password bcrypt hashes, it rejects longer ones
+const maxPasswordBytes = 72
+
+// dummyHash is compared against when the email is unknown,
+// so login takes the same time whether the user exists or not
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+
+// Service contains the business logic for users
+type Service struct {
+	repo Repository
+}
+
+// NewService creates a new instance of the service
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Signup creates a user with a bcrypt hash of its password
+// Passwords are bounded in bytes, a short one with multibyte characters can be too long
+func (s *Service) Signup(ctx context.Context, req SignupRequest) (*User, error) {
+	if len(req.Password) > maxPasswordBytes {
+		return nil, ErrPasswordTooLong
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &User{
+		ID:           "usr_" + uuid.New().String(),
+		Email:        NormalizeEmail(req.Email),
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now(),
+	}
+	if err := s.repo.Create(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login returns the user with the given credentials, or ErrInvalidCredentials
+func (s *Service) Login(ctx context.Context, req LoginRequest) (*User, error) {
+	user, err := s.repo.GetByEmail(ctx, NormalizeEmail(req.Email))
+	if errors.Is(err, ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+	return user, nil
+}
+
+// GetByID gets a user by ID
+func (s *Service) GetByID(ctx context.Context, id string) (*User, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// NormalizeEmail is the form emails are stored and looked up in
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
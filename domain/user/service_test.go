@@ -0,0 +1,120 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type fakeRepository struct {
+	users map[string]*User
+}
+
+func newFakeRepository() *fakeRepository {
+	return &fakeRepository{users: map[string]*User{}}
+}
+
+func (r *fakeRepository) Create(ctx context.Context, u *User) error {
+	for _, existing := range r.users {
+		if existing.Email == u.Email {
+			return ErrEmailTaken
+		}
+	}
+	r.users[u.ID] = u
+	return nil
+}
+
+func (r *fakeRepository) GetByID(ctx context.Context, id string) (*User, error) {
+	u, ok := r.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+	return u, nil
+}
+
+func (r *fakeRepository) GetByEmail(ctx context.Context, email string) (*User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, ErrUserNotFound
+}
+
+func TestSignup_HashesPassword(t *testing.T) {
+	s := NewService(newFakeRepository())
+
+	u, err := s.Signup(context.Background(), SignupRequest{Email: " John@Example.com ", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Signup: %v", err)
+	}
+
+	if u.ID == "" || u.Email != "john@example.com" {
+		t.Fatalf("unexpected user: %+v", u)
+	}
+	if u.PasswordHash == "" || u.PasswordHash == "correct horse" {
+		t.Fatalf("expected a bcrypt hash, got %q", u.PasswordHash)
+	}
+}
+
+func TestSignup_MultibytePasswordTooLong(t *testing.T) {
+	s := NewService(newFakeRepository())
+	// 40 characters, 80 bytes
+	password := strings.Repeat("ñ", 40)
+
+	if _, err := s.Signup(context.Background(), SignupRequest{Email: "john@example.com", Password: password}); !errors.Is(err, ErrPasswordTooLong) {
+		t.Fatalf("expected ErrPasswordTooLong, got %v", err)
+	}
+}
+
+func TestSignup_DuplicateEmail(t *testing.T) {
+	s := NewService(newFakeRepository())
+	if _, err := s.Signup(context.Background(), SignupRequest{Email: "john@example.com", Password: "correct horse"}); err != nil {
+		t.Fatalf("Signup: %v", err)
+	}
+
+	_, err := s.Signup(context.Background(), SignupRequest{Email: "JOHN@example.com", Password: "another one"})
+	if !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("expected ErrEmailTaken, got %v", err)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	s := NewService(newFakeRepository())
+	created, err := s.Signup(context.Background(), SignupRequest{Email: "john@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Signup: %v", err)
+	}
+
+	u, err := s.Login(context.Background(), LoginRequest{Email: "John@example.com", Password: "correct horse"})
+	if err != nil {
+		t.Fatalf("Login: %v", err)
+	}
+	if u.ID != created.ID {
+		t.Fatalf("expected %s, got %s", created.ID, u.ID)
+	}
+}
+
+func TestLogin_InvalidCredentials(t *testing.T) {
+	s := NewService(newFakeRepository())
+	if _, err := s.Signup(context.Background(), SignupRequest{Email: "john@example.com", Password: "correct horse"}); err != nil {
+		t.Fatalf("Signup: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		req  LoginRequest
+	}{
+		{"wrong password", LoginRequest{Email: "john@example.com", Password: "battery staple"}},
+		{"unknown email", LoginRequest{Email: "jane@example.com", Password: "correct horse"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Login(context.Background(), tt.req); !errors.Is(err, ErrInvalidCredentials) {
+				t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package user
+
+import "time"
+
+type User struct {
+	ID           string    `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	Role         string    `json:"role,omitempty"` // set by an operator, see auth.ScopesForRole
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type SignupRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8,max=72"` // max counts characters, Signup bounds the bytes
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}